@@ -9,3 +9,8 @@ var TemplateFS embed.FS
 
 //go:embed static
 var StaticFS embed.FS
+
+// OpenAPISpec is the OpenAPI 3 document describing the HTTP endpoints
+//
+//go:embed openapi.json
+var OpenAPISpec []byte
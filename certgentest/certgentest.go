@@ -0,0 +1,156 @@
+// Package certgentest provides ready-made TLS material for Go tests: a CA, server and client
+// certificates, matching mutual TLS configurations and httptest servers wired up with them.
+// It also provides a local OpenID Connect provider stand-in issuing signed tokens.
+package certgentest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pvormste/certgen/certificate"
+)
+
+// DefaultValidity is how long certificates issued by the PKI are valid unless overridden
+const DefaultValidity = 24 * time.Hour
+
+// PKI is a test CA with a server and a client certificate it issued
+type PKI struct {
+	CA *certificate.Issued
+	// Server is valid for localhost, 127.0.0.1 and ::1
+	Server *certificate.Issued
+	Client *certificate.Issued
+}
+
+// Option configures a PKI created by New
+type Option func(*config)
+
+type config struct {
+	caOptions     []certificate.CAOption
+	serverOptions []certificate.CertOption
+	clientOptions []certificate.CertOption
+}
+
+// WithCAOptions adds options for the CA certificate
+func WithCAOptions(opts ...certificate.CAOption) Option {
+	return func(c *config) { c.caOptions = append(c.caOptions, opts...) }
+}
+
+// WithServerOptions adds options for the server certificate, e.g. further DNS names
+func WithServerOptions(opts ...certificate.CertOption) Option {
+	return func(c *config) { c.serverOptions = append(c.serverOptions, opts...) }
+}
+
+// WithClientOptions adds options for the client certificate, e.g. its Common Name
+func WithClientOptions(opts ...certificate.CertOption) Option {
+	return func(c *config) { c.clientOptions = append(c.clientOptions, opts...) }
+}
+
+// New creates a CA, a server and a client certificate, failing the test on errors
+func New(t testing.TB, opts ...Option) *PKI {
+	t.Helper()
+
+	cfg := config{
+		caOptions: []certificate.CAOption{
+			certificate.WithCommonName("certgentest CA"),
+			certificate.WithValidity(DefaultValidity),
+		},
+		serverOptions: []certificate.CertOption{
+			certificate.WithDNSNames("localhost"),
+			certificate.WithIPAddresses("127.0.0.1", "::1"),
+		},
+		clientOptions: []certificate.CertOption{
+			certificate.WithType(certificate.CertTypeClient),
+			certificate.WithCommonName("certgentest client"),
+		},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	ca, err := certificate.NewCA(cfg.caOptions...)
+	if err != nil {
+		t.Fatalf("certgentest: failed to create CA: %v", err)
+	}
+
+	p := &PKI{CA: ca}
+	p.Server = p.Issue(t, cfg.serverOptions...)
+	p.Client = p.Issue(t, cfg.clientOptions...)
+	return p
+}
+
+// Issue issues another certificate signed by the CA, failing the test on errors. Without
+// options it is a server certificate valid for DefaultValidity.
+func (p *PKI) Issue(t testing.TB, opts ...certificate.CertOption) *certificate.Issued {
+	t.Helper()
+
+	opts = append([]certificate.CertOption{certificate.WithValidity(DefaultValidity)}, opts...)
+	issued, err := certificate.NewCert(p.CA, opts...)
+	if err != nil {
+		t.Fatalf("certgentest: failed to issue certificate: %v", err)
+	}
+	return issued
+}
+
+// CertPool returns a pool containing only the CA certificate
+func (p *PKI) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(p.CA.Certificate)
+	return pool
+}
+
+// TLSConfigs returns matching mutual TLS configurations for the server and client certificates
+func (p *PKI) TLSConfigs() (server, client *tls.Config) {
+	return p.ServerTLSConfig(p.Server), p.ClientTLSConfig(p.Client)
+}
+
+// ServerTLSConfig returns a server configuration presenting cert that requires client
+// certificates issued by the CA
+func (p *PKI) ServerTLSConfig(cert *certificate.Issued) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert.TLSCertificate()},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    p.CertPool(),
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+// ClientTLSConfig returns a client configuration trusting the CA and presenting cert, or no
+// client certificate when cert is nil
+func (p *PKI) ClientTLSConfig(cert *certificate.Issued) *tls.Config {
+	config := &tls.Config{
+		RootCAs:    p.CertPool(),
+		MinVersion: tls.VersionTLS12,
+	}
+	if cert != nil {
+		config.Certificates = []tls.Certificate{cert.TLSCertificate()}
+	}
+	return config
+}
+
+// NewServer starts an httptest server for handler that requires client certificates and is
+// closed when the test ends. The server's Client presents the PKI's client certificate.
+func (p *PKI) NewServer(t testing.TB, handler http.Handler) *httptest.Server {
+	t.Helper()
+
+	serverConfig, clientConfig := p.TLSConfigs()
+	return p.NewServerWith(t, handler, serverConfig, clientConfig)
+}
+
+// NewServerWith is NewServer with the given configurations, e.g. one from ServerTLSConfig
+// with a freshly issued certificate or with ClientAuth relaxed
+func (p *PKI) NewServerWith(t testing.TB, handler http.Handler, serverConfig, clientConfig *tls.Config) *httptest.Server {
+	t.Helper()
+
+	ts := httptest.NewUnstartedServer(handler)
+	ts.TLS = serverConfig
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+
+	// StartTLS only trusts the server certificate, use the CA and client certificate instead
+	ts.Client().Transport.(*http.Transport).TLSClientConfig = clientConfig
+	return ts
+}
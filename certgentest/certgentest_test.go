@@ -0,0 +1,83 @@
+package certgentest
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/pvormste/certgen/certificate"
+)
+
+func TestNewServer(t *testing.T) {
+	p := New(t)
+
+	ts := p.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+
+	resp, err := ts.Client().Get(ts.URL)
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "certgentest client" {
+		t.Errorf("Server saw client %q, want %q", body, "certgentest client")
+	}
+}
+
+func TestNewServerRequiresClientCertificate(t *testing.T) {
+	p := New(t)
+	ts := p.NewServer(t, http.NotFoundHandler())
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: p.ClientTLSConfig(nil)}}
+	if resp, err := client.Get(ts.URL); err == nil {
+		resp.Body.Close()
+		t.Fatal("Expected the handshake to fail without a client certificate")
+	}
+}
+
+func TestIssue(t *testing.T) {
+	p := New(t, WithServerOptions(certificate.WithDNSNames("api.test")))
+
+	other := p.Issue(t, certificate.WithType(certificate.CertTypeClient), certificate.WithCommonName("bob"))
+	serverConfig := p.ServerTLSConfig(p.Server)
+	clientConfig := p.ClientTLSConfig(other)
+	clientConfig.ServerName = "api.test"
+
+	ts := p.NewServerWith(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}), serverConfig, clientConfig)
+
+	resp, err := ts.Client().Get(ts.URL)
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "bob" {
+		t.Errorf("Server saw client %q, want %q", body, "bob")
+	}
+}
+
+func TestTLSConfigs(t *testing.T) {
+	p := New(t)
+	serverConfig, clientConfig := p.TLSConfigs()
+	clientConfig.ServerName = "localhost"
+
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+	errs := make(chan error, 1)
+	go func() { errs <- tls.Server(serverConn, serverConfig).Handshake() }()
+
+	if err := tls.Client(clientConn, clientConfig).Handshake(); err != nil {
+		t.Fatalf("client handshake error = %v", err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("server handshake error = %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package certgentest
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pvormste/certgen/certificate"
+)
+
+// OIDCProvider is a local stand-in for an OpenID Connect provider. It serves the discovery
+// document and the JWKS, and issues ES384 signed tokens with any claims.
+type OIDCProvider struct {
+	// Issuer is the provider URL, the iss claim of its tokens
+	Issuer string
+
+	key  *ecdsa.PrivateKey
+	kid  string
+	jwks *certificate.JWKS
+}
+
+// NewOIDCProvider starts an OIDC provider that is stopped when the test ends
+func NewOIDCProvider(t testing.TB) *OIDCProvider {
+	t.Helper()
+
+	ca, err := certificate.NewCA(certificate.WithCommonName("certgentest OIDC"))
+	if err != nil {
+		t.Fatalf("certgentest: failed to create OIDC CA: %v", err)
+	}
+	signer, err := certificate.NewCert(ca, certificate.WithCommonName("certgentest OIDC signer"), certificate.WithType(certificate.CertTypeClient))
+	if err != nil {
+		t.Fatalf("certgentest: failed to create OIDC signing key: %v", err)
+	}
+	jwks, err := signer.PublicJWKS(ca.CertPEM)
+	if err != nil {
+		t.Fatalf("certgentest: failed to create OIDC JWKS: %v", err)
+	}
+
+	p := &OIDCProvider{key: signer.Signer.(*ecdsa.PrivateKey), kid: jwks.Keys[0].Kid, jwks: jwks}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{
+			"issuer":                                p.Issuer,
+			"jwks_uri":                              p.Issuer + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"ES384"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, p.jwks)
+	})
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	p.Issuer = ts.URL
+
+	return p
+}
+
+// Token returns a signed JWT with the claims. iss, iat and exp (one hour from now) are added
+// unless set.
+func (p *OIDCProvider) Token(t testing.TB, claims map[string]any) string {
+	t.Helper()
+
+	all := map[string]any{
+		"iss": p.Issuer,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for name, value := range claims {
+		all[name] = value
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": "ES384", "typ": "JWT", "kid": p.kid})
+	if err != nil {
+		t.Fatalf("certgentest: failed to encode token header: %v", err)
+	}
+	payload, err := json.Marshal(all)
+	if err != nil {
+		t.Fatalf("certgentest: failed to encode token claims: %v", err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha512.Sum384([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, p.key, digest[:])
+	if err != nil {
+		t.Fatalf("certgentest: failed to sign token: %v", err)
+	}
+	// JWS encodes ECDSA signatures as the fixed size concatenation r || s
+	signature := make([]byte, 96)
+	r.FillBytes(signature[:48])
+	s.FillBytes(signature[48:])
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,227 @@
+package certificate
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"fmt"
+	"time"
+)
+
+// Defect identifies a deliberate flaw in a generated certificate, used to build
+// fixtures for negative TLS client tests
+type Defect string
+
+const (
+	// DefectExpired produces a certificate whose validity ended yesterday
+	DefectExpired Defect = "expired"
+	// DefectNotYetValid produces a certificate whose validity starts tomorrow
+	DefectNotYetValid Defect = "not-yet-valid"
+	// DefectWrongCA produces a certificate signed by an impostor CA with the same name as the real CA
+	DefectWrongCA Defect = "wrong-ca"
+	// DefectHostnameMismatch produces a certificate for names other than the requested ones
+	DefectHostnameMismatch Defect = "hostname-mismatch"
+	// DefectMissingEKU produces a certificate without the server/client authentication usage it needs
+	DefectMissingEKU Defect = "missing-eku"
+	// DefectCAAsLeaf produces a leaf certificate that is itself marked as a CA
+	DefectCAAsLeaf Defect = "ca-as-leaf"
+	// DefectBadSignature produces a certificate whose signature does not verify
+	DefectBadSignature Defect = "bad-signature"
+)
+
+// Defects lists all supported defects in a stable order
+var Defects = []Defect{
+	DefectExpired,
+	DefectNotYetValid,
+	DefectWrongCA,
+	DefectHostnameMismatch,
+	DefectMissingEKU,
+	DefectCAAsLeaf,
+	DefectBadSignature,
+}
+
+var defectDescriptions = map[Defect]string{
+	DefectExpired:          "Validity period ended 24 hours ago",
+	DefectNotYetValid:      "Validity period starts in 24 hours",
+	DefectWrongCA:          "Signed by an impostor CA that has the same subject as the real CA",
+	DefectHostnameMismatch: "Issued for mismatch.invalid and 192.0.2.1 instead of the requested names",
+	DefectMissingEKU:       "Extended key usage only allows code signing, not TLS server or client authentication",
+	DefectCAAsLeaf:         "Leaf certificate with CA:TRUE basic constraints and the certificate signing key usage",
+	DefectBadSignature:     "Signature bytes are corrupted so the certificate fails signature verification",
+}
+
+// Description returns a human readable explanation of the defect
+func (d Defect) Description() string {
+	return defectDescriptions[d]
+}
+
+// ParseDefect converts a string into a Defect
+func ParseDefect(s string) (Defect, error) {
+	d := Defect(s)
+	if _, ok := defectDescriptions[d]; !ok {
+		return "", fmt.Errorf("unknown defect %q", s)
+	}
+	return d, nil
+}
+
+// ParseDefects converts defect names into Defects. Each defect may be named once, so a
+// request generates at most one certificate per supported defect.
+func ParseDefects(names []string) ([]Defect, error) {
+	defects := make([]Defect, 0, len(names))
+	seen := make(map[Defect]bool, len(names))
+	for _, name := range names {
+		defect, err := ParseDefect(name)
+		if err != nil {
+			return nil, err
+		}
+		if seen[defect] {
+			return nil, fmt.Errorf("defect %q is listed more than once", name)
+		}
+		seen[defect] = true
+		defects = append(defects, defect)
+	}
+	return defects, nil
+}
+
+// BrokenCert is a certificate generated with a deliberate defect
+type BrokenCert struct {
+	Defect Defect
+	Bundle *CertBundle
+}
+
+// GenerateBrokenCerts creates one certificate per defect, all derived from the same config.
+// When defects is empty, every supported defect is generated; a defect listed twice is an error.
+func GenerateBrokenCerts(config CertConfig, defects []Defect, caCertPEM, caKeyPEM []byte) ([]BrokenCert, error) {
+	if len(defects) == 0 {
+		defects = Defects
+	}
+	names := make([]string, len(defects))
+	for i, defect := range defects {
+		names[i] = string(defect)
+	}
+	if _, err := ParseDefects(names); err != nil {
+		return nil, &ValidationError{Fields: []*FieldError{{Field: "defects", Err: err}}}
+	}
+
+	certs := make([]BrokenCert, 0, len(defects))
+	for _, defect := range defects {
+		bundle, err := GenerateBrokenCert(config, defect, caCertPEM, caKeyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", defect, err)
+		}
+		certs = append(certs, BrokenCert{Defect: defect, Bundle: bundle})
+	}
+
+	return certs, nil
+}
+
+// GenerateBrokenCert creates a certificate like GenerateCert, but with the given defect.
+// Name constraints of the CA are not enforced, since the result is invalid on purpose.
+func GenerateBrokenCert(config CertConfig, defect Defect, caCertPEM, caKeyPEM []byte) (*CertBundle, error) {
+	if _, err := ParseDefect(string(defect)); err != nil {
+		return nil, &ValidationError{Fields: []*FieldError{{Field: "defect", Err: err}}}
+	}
+
+	now := time.Now()
+	switch defect {
+	case DefectExpired:
+		config.NotBefore = now.Add(-48 * time.Hour)
+		config.NotAfter = now.Add(-24 * time.Hour)
+	case DefectNotYetValid:
+		config.NotBefore = now.Add(24 * time.Hour)
+		config.NotAfter = now.Add(48 * time.Hour)
+	case DefectHostnameMismatch:
+		config.DNSNames = []string{"mismatch.invalid"}
+		config.IPAddresses = []string{"192.0.2.1"}
+		if config.Type == CertTypeClient {
+			config.Type = CertTypeServer
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	caCert, caKey, err := parseCA(caCertPEM, caKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	privKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate private key: %w", err)
+	}
+
+	template, err := leafTemplate(config, caCert, &privKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	template.SerialNumber, err = config.serialNumber(caCert)
+	if err != nil {
+		return nil, err
+	}
+
+	switch defect {
+	case DefectMissingEKU:
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}
+	case DefectCAAsLeaf:
+		template.IsCA = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		template.MaxPathLenZero = true
+	case DefectWrongCA:
+		caCert, caKey, err = impostorCA(caCert)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, caCert, &privKey.PublicKey, caKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	if defect == DefectBadSignature {
+		// The signature BIT STRING is the last element of the certificate, and flipping
+		// its final byte keeps the DER structure intact
+		certDER[len(certDER)-1] ^= 0xff
+	}
+
+	return encodeBundle(certDER, privKey)
+}
+
+// impostorCA creates a throwaway self-signed CA that copies the subject of the real CA,
+// so certificates it signs name the expected issuer but fail signature verification
+func impostorCA(real *x509.Certificate) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate private key: %w", err)
+	}
+
+	serialNumber, err := randomSerial()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               real.Subject,
+		NotBefore:             real.NotBefore,
+		NotAfter:              real.NotAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create impostor CA: %w", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse impostor CA: %w", err)
+	}
+
+	return cert, key, nil
+}
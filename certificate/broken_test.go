@@ -0,0 +1,115 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+)
+
+func TestGenerateBrokenCerts(t *testing.T) {
+	ca, err := GenerateCA(CAConfig{CommonName: "Test CA", Organization: "Test Org", ExpiryDays: 365})
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+
+	block, _ := pem.Decode(ca.CertPEM)
+	caCert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse CA certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+
+	config := CertConfig{
+		CommonName: "localhost",
+		ExpiryDays: 30,
+		Type:       CertTypeServer,
+		DNSNames:   []string{"localhost"},
+	}
+
+	// Sanity check: the same config without a defect verifies fine
+	good, err := GenerateCert(config, ca.CertPEM, ca.KeyPEM)
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	if err := verifyServerCert(good.CertPEM, roots); err != nil {
+		t.Fatalf("Valid certificate failed verification: %v", err)
+	}
+
+	certs, err := GenerateBrokenCerts(config, nil, ca.CertPEM, ca.KeyPEM)
+	if err != nil {
+		t.Fatalf("GenerateBrokenCerts() error = %v", err)
+	}
+	if len(certs) != len(Defects) {
+		t.Fatalf("Expected %d broken certificates, got %d", len(Defects), len(certs))
+	}
+
+	for _, broken := range certs {
+		t.Run(string(broken.Defect), func(t *testing.T) {
+			if broken.Defect.Description() == "" {
+				t.Error("Defect has no description")
+			}
+
+			if broken.Defect == DefectCAAsLeaf {
+				// Go's verifier accepts CA certificates as leaves, so check the flag directly
+				block, _ := pem.Decode(broken.Bundle.CertPEM)
+				cert, err := x509.ParseCertificate(block.Bytes)
+				if err != nil {
+					t.Fatalf("Failed to parse certificate: %v", err)
+				}
+				if !cert.IsCA {
+					t.Error("Expected leaf certificate to be marked as CA")
+				}
+				return
+			}
+
+			if err := verifyServerCert(broken.Bundle.CertPEM, roots); err == nil {
+				t.Error("Broken certificate unexpectedly passed verification")
+			}
+		})
+	}
+}
+
+func TestGenerateBrokenCertUnknownDefect(t *testing.T) {
+	_, err := GenerateBrokenCert(CertConfig{CommonName: "x", ExpiryDays: 1}, "bogus", nil, nil)
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Fields[0].Field != "defect" {
+		t.Errorf("Expected defect validation error, got %v", err)
+	}
+}
+
+func TestParseDefects(t *testing.T) {
+	defects, err := ParseDefects([]string{"expired", "wrong-ca"})
+	if err != nil || len(defects) != 2 || defects[0] != DefectExpired || defects[1] != DefectWrongCA {
+		t.Errorf("ParseDefects() = %v, %v", defects, err)
+	}
+	if _, err := ParseDefects([]string{"expired", "bogus"}); err == nil {
+		t.Error("Expected an error for an unknown defect")
+	}
+	if _, err := ParseDefects([]string{"expired", "expired"}); err == nil {
+		t.Error("Expected an error for a repeated defect")
+	}
+
+	_, err = GenerateBrokenCerts(CertConfig{CommonName: "x", ExpiryDays: 1}, []Defect{DefectExpired, DefectExpired}, nil, nil)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Fields[0].Field != "defects" {
+		t.Errorf("Expected defects validation error, got %v", err)
+	}
+}
+
+func verifyServerCert(certPEM []byte, roots *x509.CertPool) error {
+	block, _ := pem.Decode(certPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return err
+	}
+
+	_, err = cert.Verify(x509.VerifyOptions{
+		DNSName:   "localhost",
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	return err
+}
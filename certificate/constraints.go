@@ -0,0 +1,239 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ErrNameConstraint is returned when a certificate name is not allowed by the issuing CA's name constraints
+var ErrNameConstraint = errors.New("name not permitted by CA name constraints")
+
+// NameConstraints restricts the names a CA may issue certificates for.
+// IP ranges are given in CIDR notation, e.g. "10.0.0.0/8".
+type NameConstraints struct {
+	PermittedDNSDomains     []string `json:"permittedDnsDomains,omitempty"`
+	ExcludedDNSDomains      []string `json:"excludedDnsDomains,omitempty"`
+	PermittedIPRanges       []string `json:"permittedIpRanges,omitempty"`
+	ExcludedIPRanges        []string `json:"excludedIpRanges,omitempty"`
+	PermittedEmailAddresses []string `json:"permittedEmailAddresses,omitempty"`
+	ExcludedEmailAddresses  []string `json:"excludedEmailAddresses,omitempty"`
+	PermittedURIDomains     []string `json:"permittedUriDomains,omitempty"`
+	ExcludedURIDomains      []string `json:"excludedUriDomains,omitempty"`
+}
+
+// IsEmpty reports whether no constraint is set
+func (nc NameConstraints) IsEmpty() bool {
+	return len(nc.PermittedDNSDomains) == 0 && len(nc.ExcludedDNSDomains) == 0 &&
+		len(nc.PermittedIPRanges) == 0 && len(nc.ExcludedIPRanges) == 0 &&
+		len(nc.PermittedEmailAddresses) == 0 && len(nc.ExcludedEmailAddresses) == 0 &&
+		len(nc.PermittedURIDomains) == 0 && len(nc.ExcludedURIDomains) == 0
+}
+
+// apply adds the name constraints extension to a CA template. The extension is marked
+// critical as required by RFC 5280.
+func (nc NameConstraints) apply(template *x509.Certificate) error {
+	if nc.IsEmpty() {
+		return nil
+	}
+
+	permittedIPs, err := parseCIDRs(nc.PermittedIPRanges)
+	if err != nil {
+		return err
+	}
+	excludedIPs, err := parseCIDRs(nc.ExcludedIPRanges)
+	if err != nil {
+		return err
+	}
+
+	template.PermittedDNSDomainsCritical = true
+	template.PermittedDNSDomains = nc.PermittedDNSDomains
+	template.ExcludedDNSDomains = nc.ExcludedDNSDomains
+	template.PermittedIPRanges = permittedIPs
+	template.ExcludedIPRanges = excludedIPs
+	template.PermittedEmailAddresses = nc.PermittedEmailAddresses
+	template.ExcludedEmailAddresses = nc.ExcludedEmailAddresses
+	template.PermittedURIDomains = nc.PermittedURIDomains
+	template.ExcludedURIDomains = nc.ExcludedURIDomains
+
+	return nil
+}
+
+// validate reports malformed constraints to the validator
+func (nc NameConstraints) validate(v *validator) {
+	for _, list := range []struct {
+		field  string
+		values []string
+	}{
+		{"nameConstraints.permittedIpRanges", nc.PermittedIPRanges},
+		{"nameConstraints.excludedIpRanges", nc.ExcludedIPRanges},
+	} {
+		for i, s := range list.values {
+			if _, _, err := net.ParseCIDR(strings.TrimSpace(s)); err != nil {
+				v.addf(fmt.Sprintf("%s[%d]", list.field, i), "invalid CIDR %q", s)
+			}
+		}
+	}
+
+	for _, list := range []struct {
+		field  string
+		values []string
+	}{
+		{"nameConstraints.permittedDnsDomains", nc.PermittedDNSDomains},
+		{"nameConstraints.excludedDnsDomains", nc.ExcludedDNSDomains},
+		{"nameConstraints.permittedEmailAddresses", nc.PermittedEmailAddresses},
+		{"nameConstraints.excludedEmailAddresses", nc.ExcludedEmailAddresses},
+		{"nameConstraints.permittedUriDomains", nc.PermittedURIDomains},
+		{"nameConstraints.excludedUriDomains", nc.ExcludedURIDomains},
+	} {
+		for i, s := range list.values {
+			if strings.TrimSpace(s) == "" || strings.ContainsAny(s, " *") {
+				v.addf(fmt.Sprintf("%s[%d]", list.field, i), "invalid constraint %q", s)
+			}
+		}
+	}
+}
+
+func parseCIDRs(values []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, s := range values {
+		_, ipNet, err := net.ParseCIDR(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", s, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// checkChainConstraints verifies that the template is permitted by the path length and name
+// constraints of every CA in chain, which starts with the signing CA and continues towards
+// the root, since constraints of a CA also bind the CAs below it
+func checkChainConstraints(chain []*x509.Certificate, template *x509.Certificate) error {
+	for i, ca := range chain {
+		// i intermediate CAs lie between this CA and the new certificate
+		if i > 0 && ca.MaxPathLen >= 0 && i > ca.MaxPathLen {
+			return fmt.Errorf("%w: CA %q allows %d intermediate CAs below it, the chain has %d", ErrInvalidCA, ca.Subject.CommonName, ca.MaxPathLen, i)
+		}
+		if err := checkNameConstraints(ca, template); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkNameConstraints verifies that all subject alternative names of the template are
+// permitted by the name constraints of the issuing CA
+func checkNameConstraints(ca *x509.Certificate, template *x509.Certificate) error {
+	var v validator
+
+	for i, name := range template.DNSNames {
+		if !permitted(name, ca.PermittedDNSDomains, ca.ExcludedDNSDomains, matchDNSConstraint) {
+			v.add(fmt.Sprintf("dnsNames[%d]", i), fmt.Errorf("%w: DNS name %q", ErrNameConstraint, name))
+		}
+	}
+
+	for i, ip := range template.IPAddresses {
+		if !permittedIP(ip, ca.PermittedIPRanges, ca.ExcludedIPRanges) {
+			v.add(fmt.Sprintf("ipAddresses[%d]", i), fmt.Errorf("%w: IP address %s", ErrNameConstraint, ip))
+		}
+	}
+
+	for i, email := range template.EmailAddresses {
+		if !permitted(email, ca.PermittedEmailAddresses, ca.ExcludedEmailAddresses, matchEmailConstraint) {
+			v.add(fmt.Sprintf("emailAddresses[%d]", i), fmt.Errorf("%w: email address %q", ErrNameConstraint, email))
+		}
+	}
+
+	for i, uri := range template.URIs {
+		field := fmt.Sprintf("uris[%d]", i)
+		if uri.Scheme == "spiffe" {
+			field = "spiffeId"
+		}
+		if !permitted(uri.Hostname(), ca.PermittedURIDomains, ca.ExcludedURIDomains, matchURIConstraint) {
+			v.add(field, fmt.Errorf("%w: URI %q", ErrNameConstraint, uri))
+		}
+	}
+
+	return v.err()
+}
+
+// permitted reports whether name matches one of the permitted constraints (if any)
+// and none of the excluded constraints
+func permitted(name string, permittedList, excludedList []string, match func(name, constraint string) bool) bool {
+	for _, constraint := range excludedList {
+		if match(name, constraint) {
+			return false
+		}
+	}
+	if len(permittedList) == 0 {
+		return true
+	}
+	for _, constraint := range permittedList {
+		if match(name, constraint) {
+			return true
+		}
+	}
+	return false
+}
+
+func permittedIP(ip net.IP, permittedList, excludedList []*net.IPNet) bool {
+	for _, ipNet := range excludedList {
+		if ipNet.Contains(ip) {
+			return false
+		}
+	}
+	if len(permittedList) == 0 {
+		return true
+	}
+	for _, ipNet := range permittedList {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchDNSConstraint implements RFC 5280 DNS name matching: "example.com" matches the
+// domain and all of its subdomains, ".example.com" only its subdomains
+func matchDNSConstraint(name, constraint string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	constraint = strings.ToLower(constraint)
+
+	if strings.HasPrefix(constraint, ".") {
+		return strings.HasSuffix(name, constraint)
+	}
+	return name == constraint || strings.HasSuffix(name, "."+constraint)
+}
+
+// matchEmailConstraint implements RFC 5280 email matching: a full mailbox matches exactly,
+// "example.com" matches mailboxes on that host and ".example.com" mailboxes on any subdomain
+func matchEmailConstraint(email, constraint string) bool {
+	if strings.Contains(constraint, "@") {
+		return strings.EqualFold(email, constraint)
+	}
+
+	_, host, ok := strings.Cut(email, "@")
+	if !ok {
+		return false
+	}
+	return matchHostConstraint(host, constraint)
+}
+
+// matchURIConstraint implements RFC 5280 URI matching on the host part of the URI
+func matchURIConstraint(host, constraint string) bool {
+	return matchHostConstraint(host, constraint)
+}
+
+// matchHostConstraint matches a host exactly, or any subdomain when the constraint starts with '.'
+func matchHostConstraint(host, constraint string) bool {
+	host = strings.ToLower(host)
+	constraint = strings.ToLower(constraint)
+
+	if strings.HasPrefix(constraint, ".") {
+		return strings.HasSuffix(host, constraint)
+	}
+	return host == constraint
+}
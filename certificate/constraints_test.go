@@ -0,0 +1,202 @@
+package certificate
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestNameConstraints(t *testing.T) {
+	ca, err := GenerateCA(CAConfig{
+		CommonName: "Constrained CA",
+		ExpiryDays: 365,
+		NameConstraints: NameConstraints{
+			PermittedDNSDomains:     []string{"internal.example"},
+			ExcludedDNSDomains:      []string{"secret.internal.example"},
+			PermittedIPRanges:       []string{"10.0.0.0/8"},
+			PermittedEmailAddresses: []string{"internal.example"},
+			PermittedURIDomains:     []string{".internal.example"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+
+	block, _ := pem.Decode(ca.CertPEM)
+	caCert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse CA certificate: %v", err)
+	}
+	if !caCert.PermittedDNSDomainsCritical {
+		t.Error("Name constraints extension should be critical")
+	}
+	if len(caCert.PermittedIPRanges) != 1 || caCert.PermittedIPRanges[0].String() != "10.0.0.0/8" {
+		t.Errorf("Unexpected permitted IP ranges %v", caCert.PermittedIPRanges)
+	}
+
+	tests := []struct {
+		name       string
+		config     CertConfig
+		wantFields []string
+	}{
+		{
+			name: "permitted names",
+			config: CertConfig{
+				CommonName:     "app",
+				DNSNames:       []string{"app.internal.example", "internal.example"},
+				IPAddresses:    []string{"10.1.2.3"},
+				EmailAddresses: []string{"ops@internal.example"},
+				URIs:           []string{"https://svc.internal.example/api"},
+			},
+		},
+		{
+			name:       "DNS name outside permitted subtree",
+			config:     CertConfig{CommonName: "app", DNSNames: []string{"www.google.com"}},
+			wantFields: []string{"dnsNames[0]"},
+		},
+		{
+			name:       "excluded DNS name",
+			config:     CertConfig{CommonName: "app", DNSNames: []string{"db.secret.internal.example"}},
+			wantFields: []string{"dnsNames[0]"},
+		},
+		{
+			name:       "IP address outside permitted range",
+			config:     CertConfig{CommonName: "app", IPAddresses: []string{"192.168.1.1", "::1"}},
+			wantFields: []string{"ipAddresses[0]", "ipAddresses[1]"},
+		},
+		{
+			name:       "email and URI outside permitted subtree",
+			config:     CertConfig{CommonName: "app", EmailAddresses: []string{"a@example.com"}, URIs: []string{"https://internal.example/"}},
+			wantFields: []string{"emailAddresses[0]", "uris[0]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.ExpiryDays = 30
+			bundle, err := GenerateCert(tt.config, ca.CertPEM, ca.KeyPEM)
+			if len(tt.wantFields) > 0 {
+				if !errors.Is(err, ErrNameConstraint) {
+					t.Fatalf("GenerateCert() error = %v, want ErrNameConstraint", err)
+				}
+				assertFieldErrors(t, err, tt.wantFields)
+				return
+			}
+			if err != nil {
+				t.Fatalf("GenerateCert() error = %v", err)
+			}
+
+			// The issued certificate must also pass standard chain verification
+			block, _ := pem.Decode(bundle.CertPEM)
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				t.Fatalf("Failed to parse certificate: %v", err)
+			}
+			roots := x509.NewCertPool()
+			roots.AddCert(caCert)
+			if _, err := cert.Verify(x509.VerifyOptions{Roots: roots}); err != nil {
+				t.Errorf("Verify() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestNameConstraintsValidate(t *testing.T) {
+	config := CAConfig{
+		CommonName: "Constrained CA",
+		ExpiryDays: 365,
+		NameConstraints: NameConstraints{
+			PermittedIPRanges:   []string{"10.0.0.0"},
+			PermittedDNSDomains: []string{"*.example.com"},
+		},
+	}
+
+	assertFieldErrors(t, config.Validate(), []string{
+		"nameConstraints.permittedIpRanges[0]",
+		"nameConstraints.permittedDnsDomains[0]",
+	})
+}
+
+// intermediateCA creates an unconstrained intermediate CA signed by the parent bundle, which
+// certgen itself cannot generate
+func intermediateCA(t *testing.T, parent *CertBundle, maxPathLen int) *CertBundle {
+	t.Helper()
+	parentCert, parentKey, err := parseCA(parent.CertPEM, parent.KeyPEM)
+	if err != nil {
+		t.Fatalf("parseCA() error = %v", err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "Intermediate CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLen:            maxPathLen,
+		MaxPathLenZero:        maxPathLen == 0,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	bundle, err := encodeBundle(der, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Upload the intermediate with the chain above it, like an intermediate CA is distributed
+	bundle.CertPEM = append(bundle.CertPEM, parent.CertPEM...)
+	return bundle
+}
+
+func TestChainConstraints(t *testing.T) {
+	root, err := GenerateCA(CAConfig{
+		CommonName:      "Constrained Root",
+		ExpiryDays:      365,
+		NameConstraints: NameConstraints{PermittedDNSDomains: []string{"internal.example"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+	otherRoot, err := GenerateCA(CAConfig{CommonName: "Other Root", ExpiryDays: 365})
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+	intermediate := intermediateCA(t, root, -1)
+	lastIntermediate := intermediateCA(t, intermediateCA(t, root, 0), -1)
+
+	issue := func(ca *CertBundle, dnsName string) error {
+		_, err := GenerateCert(CertConfig{CommonName: "app", DNSNames: []string{dnsName}, ExpiryDays: 30}, ca.CertPEM, ca.KeyPEM)
+		return err
+	}
+
+	if err := issue(intermediate, "app.internal.example"); err != nil {
+		t.Errorf("Issuing a permitted name below the intermediate error = %v", err)
+	}
+	if err := issue(intermediate, "www.google.com"); !errors.Is(err, ErrNameConstraint) {
+		t.Errorf("Issuing a name excluded by the root error = %v, want ErrNameConstraint", err)
+	}
+	if err := issue(lastIntermediate, "app.internal.example"); !errors.Is(err, ErrInvalidCA) {
+		t.Errorf("Issuing below the path length of an intermediate error = %v, want ErrInvalidCA", err)
+	}
+
+	wrongChain := &CertBundle{
+		CertPEM: slices.Concat(intermediate.CertPEM[:len(intermediate.CertPEM)-len(root.CertPEM)], otherRoot.CertPEM),
+		KeyPEM:  intermediate.KeyPEM,
+	}
+	if err := issue(wrongChain, "app.internal.example"); !errors.Is(err, ErrInvalidCA) {
+		t.Errorf("Issuing with a chain the intermediate is not part of error = %v, want ErrInvalidCA", err)
+	}
+}
@@ -0,0 +1,69 @@
+package certificate
+
+// KeyAlgorithmECDSAP384 identifies the ECDSA P-384 keys generated for all certificates
+const KeyAlgorithmECDSAP384 = "ecdsa-p384"
+
+// Defaults holds the subject attributes and validity used when a request leaves them empty
+type Defaults struct {
+	// KeyAlgorithm is the key algorithm of generated keys; only KeyAlgorithmECDSAP384 is supported
+	KeyAlgorithm       string
+	Organization       string
+	OrganizationalUnit string
+	Country            string
+	Province           string
+	Locality           string
+	// Validity applies when a request sets neither a validity, expiry days nor NotAfter, e.g. "90d"
+	Validity string
+}
+
+// Validate checks the defaults and returns a *ValidationError naming every invalid field
+func (d Defaults) Validate() error {
+	var v validator
+	if d.KeyAlgorithm != "" && d.KeyAlgorithm != KeyAlgorithmECDSAP384 {
+		v.addf("keyAlgorithm", "unsupported key algorithm %q, keys are always %s", d.KeyAlgorithm, KeyAlgorithmECDSAP384)
+	}
+	if d.Country != "" && !isCountryCode(d.Country) {
+		v.addf("country", "%q is not an ISO 3166-1 alpha-2 country code", d.Country)
+	}
+	if d.Validity != "" {
+		if _, err := ParseValidity(d.Validity); err != nil {
+			v.add("validity", err)
+		}
+	}
+	return v.err()
+}
+
+// ApplyCA fills in the empty fields of a CA config
+func (d Defaults) ApplyCA(config *CAConfig) {
+	d.applySubject(&config.Organization, &config.OrganizationalUnit, &config.Country, &config.Province, &config.Locality)
+	d.applyValidity(&config.Validity, config.ExpiryDays, config.NotAfter.IsZero())
+}
+
+// ApplyCert fills in the empty fields of a certificate config
+func (d Defaults) ApplyCert(config *CertConfig) {
+	d.applySubject(&config.Organization, &config.OrganizationalUnit, &config.Country, &config.Province, &config.Locality)
+	d.applyValidity(&config.Validity, config.ExpiryDays, config.NotAfter.IsZero())
+}
+
+func (d Defaults) applySubject(organization, organizationalUnit, country, province, locality *string) {
+	for _, field := range []struct {
+		value *string
+		def   string
+	}{
+		{organization, d.Organization},
+		{organizationalUnit, d.OrganizationalUnit},
+		{country, d.Country},
+		{province, d.Province},
+		{locality, d.Locality},
+	} {
+		if *field.value == "" {
+			*field.value = field.def
+		}
+	}
+}
+
+func (d Defaults) applyValidity(validity *string, expiryDays int, noNotAfter bool) {
+	if *validity == "" && expiryDays <= 0 && noNotAfter {
+		*validity = d.Validity
+	}
+}
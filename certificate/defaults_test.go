@@ -0,0 +1,41 @@
+package certificate
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDefaultsApply(t *testing.T) {
+	defaults := Defaults{Organization: "Default Org", Country: "DE", Validity: "30d"}
+
+	config := CertConfig{CommonName: "svc", Organization: "Own Org"}
+	defaults.ApplyCert(&config)
+	if config.Organization != "Own Org" || config.Country != "DE" || config.Validity != "30d" {
+		t.Errorf("ApplyCert() = %+v, want only empty fields filled in", config)
+	}
+
+	// Expiry days and an explicit end take precedence over the default validity
+	ca := CAConfig{ExpiryDays: 7}
+	defaults.ApplyCA(&ca)
+	if ca.Validity != "" {
+		t.Errorf("ApplyCA() Validity = %q, want the expiry days to apply", ca.Validity)
+	}
+	config = CertConfig{NotAfter: time.Now().Add(time.Hour)}
+	defaults.ApplyCert(&config)
+	if config.Validity != "" {
+		t.Errorf("ApplyCert() Validity = %q, want NotAfter to apply", config.Validity)
+	}
+}
+
+func TestDefaultsValidate(t *testing.T) {
+	if err := (Defaults{KeyAlgorithm: KeyAlgorithmECDSAP384, Country: "US", Validity: "90d"}).Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	err := Defaults{KeyAlgorithm: "rsa-2048", Country: "Germany", Validity: "soon"}.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Fields) != 3 {
+		t.Errorf("Validate() error = %v, want keyAlgorithm, country and validity", err)
+	}
+}
@@ -0,0 +1,230 @@
+package certificate
+
+import (
+	"container/list"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDynamicCacheSize is the number of leaf certificates a DynamicIssuer keeps by default
+const DefaultDynamicCacheSize = 1024
+
+// renewFraction is the share of a leaf's lifetime left when it is reissued
+const renewFraction = 0.1
+
+// ErrNameNotAllowed is returned by DynamicIssuer.GetCertificate for server names outside the allow-list
+var ErrNameNotAllowed = errors.New("server name not allowed")
+
+// DynamicIssuer mints a leaf certificate for every server name seen during TLS handshakes,
+// signed by its CA. Leaves are cached in memory and reissued shortly before they expire;
+// concurrent handshakes for the same name share a single issuance.
+type DynamicIssuer struct {
+	ca          *Issued
+	allowed     []string
+	cacheSize   int
+	leafOptions []CertOption
+
+	mu       sync.Mutex
+	lru      *list.List
+	entries  map[string]*list.Element
+	inflight map[string]*issueCall
+}
+
+type cacheEntry struct {
+	name string
+	cert *tls.Certificate
+}
+
+type issueCall struct {
+	done chan struct{}
+	cert *tls.Certificate
+	err  error
+}
+
+// IssuerOption configures a DynamicIssuer
+type IssuerOption func(*DynamicIssuer)
+
+// WithAllowedNames restricts issuance to server names matching one of the patterns, where
+// '*' matches any sequence of characters (e.g. "*.test"). All names are allowed by default,
+// so issuers reachable by untrusted clients should set it: every new name costs a key generation.
+func WithAllowedNames(patterns ...string) IssuerOption {
+	return func(d *DynamicIssuer) {
+		for _, pattern := range patterns {
+			d.allowed = append(d.allowed, strings.ToLower(pattern))
+		}
+	}
+}
+
+// WithCacheSize sets how many leaf certificates are kept; the least recently used are evicted first
+func WithCacheSize(n int) IssuerOption {
+	return func(d *DynamicIssuer) { d.cacheSize = n }
+}
+
+// WithLeafOptions adds options applied to every leaf certificate, e.g. WithValidity or WithOrganization
+func WithLeafOptions(opts ...CertOption) IssuerOption {
+	return func(d *DynamicIssuer) { d.leafOptions = append(d.leafOptions, opts...) }
+}
+
+// NewDynamicIssuer creates a DynamicIssuer for the CA
+func NewDynamicIssuer(ca *Issued, opts ...IssuerOption) *DynamicIssuer {
+	d := &DynamicIssuer{
+		ca:        ca,
+		cacheSize: DefaultDynamicCacheSize,
+		lru:       list.New(),
+		entries:   make(map[string]*list.Element),
+		inflight:  make(map[string]*issueCall),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.cacheSize < 1 {
+		d.cacheSize = 1
+	}
+	return d
+}
+
+// TLSConfig returns a server configuration that issues certificates with GetCertificate
+func (d *DynamicIssuer) TLSConfig() *tls.Config {
+	return &tls.Config{
+		GetCertificate: d.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+}
+
+// GetCertificate implements tls.Config.GetCertificate. Without SNI, the certificate is issued
+// for the IP address the client connected to.
+func (d *DynamicIssuer) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if name == "" && hello.Conn != nil {
+		if host, _, err := net.SplitHostPort(hello.Conn.LocalAddr().String()); err == nil {
+			name = host
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("no server name to issue a certificate for")
+	}
+	if !validServerName(name) {
+		return nil, fmt.Errorf("%w: invalid server name %q", ErrNameNotAllowed, name)
+	}
+	if !d.nameAllowed(name) {
+		return nil, fmt.Errorf("%w: %s", ErrNameNotAllowed, name)
+	}
+	return d.certificate(name)
+}
+
+func (d *DynamicIssuer) nameAllowed(name string) bool {
+	if len(d.allowed) == 0 {
+		return true
+	}
+	for _, pattern := range d.allowed {
+		if matchPattern(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// validServerName reports whether name is an IP address or a host name of at most 253
+// characters whose labels are 1 to 63 letters, digits, hyphens or underscores
+func validServerName(name string) bool {
+	if net.ParseIP(name) != nil {
+		return true
+	}
+	if len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// certificate returns the cached leaf for name, or issues one. Only the first caller for a name
+// issues; the others wait for its result.
+func (d *DynamicIssuer) certificate(name string) (*tls.Certificate, error) {
+	d.mu.Lock()
+	if elem, ok := d.entries[name]; ok {
+		entry := elem.Value.(*cacheEntry)
+		if !needsRenewal(entry.cert) {
+			d.lru.MoveToFront(elem)
+			d.mu.Unlock()
+			return entry.cert, nil
+		}
+	}
+	if call, ok := d.inflight[name]; ok {
+		d.mu.Unlock()
+		<-call.done
+		return call.cert, call.err
+	}
+	call := &issueCall{done: make(chan struct{})}
+	d.inflight[name] = call
+	d.mu.Unlock()
+
+	call.cert, call.err = d.issue(name)
+
+	d.mu.Lock()
+	delete(d.inflight, name)
+	if call.err == nil {
+		d.store(name, call.cert)
+	}
+	d.mu.Unlock()
+	close(call.done)
+
+	return call.cert, call.err
+}
+
+// store adds the leaf to the cache and evicts the least recently used leaves; d.mu must be held
+func (d *DynamicIssuer) store(name string, cert *tls.Certificate) {
+	if elem, ok := d.entries[name]; ok {
+		elem.Value.(*cacheEntry).cert = cert
+		d.lru.MoveToFront(elem)
+		return
+	}
+	d.entries[name] = d.lru.PushFront(&cacheEntry{name: name, cert: cert})
+	for d.lru.Len() > d.cacheSize {
+		oldest := d.lru.Back()
+		d.lru.Remove(oldest)
+		delete(d.entries, oldest.Value.(*cacheEntry).name)
+	}
+}
+
+// needsRenewal reports whether less than renewFraction of the leaf's lifetime is left
+func needsRenewal(cert *tls.Certificate) bool {
+	lifetime := cert.Leaf.NotAfter.Sub(cert.Leaf.NotBefore)
+	renewAt := cert.Leaf.NotAfter.Add(-time.Duration(float64(lifetime) * renewFraction))
+	return time.Now().After(renewAt)
+}
+
+func (d *DynamicIssuer) issue(name string) (*tls.Certificate, error) {
+	// RFC 5280 limits common names to 64 characters; the full name is in the SAN anyway
+	commonName := name
+	if len(commonName) > 64 {
+		commonName, _, _ = strings.Cut(name, ".")
+	}
+	opts := []CertOption{WithType(CertTypeServer), WithCommonName(commonName)}
+	if net.ParseIP(name) != nil {
+		opts = append(opts, WithIPAddresses(name))
+	} else {
+		opts = append(opts, WithDNSNames(name))
+	}
+	opts = append(opts, d.leafOptions...)
+
+	leaf, err := NewCert(d.ca, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue certificate for %s: %w", name, err)
+	}
+	cert := leaf.TLSCertificate()
+	return &cert, nil
+}
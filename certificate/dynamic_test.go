@@ -0,0 +1,191 @@
+package certificate
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDynamicIssuerHandshake(t *testing.T) {
+	ca, err := NewCA()
+	if err != nil {
+		t.Fatalf("NewCA() error = %v", err)
+	}
+	issuer := NewDynamicIssuer(ca, WithAllowedNames("*.test", "127.0.0.1"))
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", issuer.TLSConfig())
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = conn.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Certificate)
+
+	tests := []struct {
+		serverName string
+		wantErr    bool
+	}{
+		{serverName: "api.test"},
+		{serverName: "WWW.Example.Test."},
+		{serverName: ""},
+		{serverName: "example.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.serverName, func(t *testing.T) {
+			conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{RootCAs: pool, ServerName: tt.serverName})
+			if tt.wantErr {
+				if err == nil {
+					conn.Close()
+					t.Fatal("Expected the handshake to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Dial() error = %v", err)
+			}
+			conn.Close()
+		})
+	}
+}
+
+func TestDynamicIssuerAllowList(t *testing.T) {
+	ca, err := NewCA()
+	if err != nil {
+		t.Fatalf("NewCA() error = %v", err)
+	}
+	issuer := NewDynamicIssuer(ca, WithAllowedNames("*.internal"))
+
+	_, err = issuer.GetCertificate(&tls.ClientHelloInfo{ServerName: "evil.example"})
+	if !errors.Is(err, ErrNameNotAllowed) {
+		t.Errorf("GetCertificate() error = %v, want ErrNameNotAllowed", err)
+	}
+	if _, err := issuer.GetCertificate(&tls.ClientHelloInfo{ServerName: "db.internal"}); err != nil {
+		t.Errorf("GetCertificate() error = %v", err)
+	}
+
+	// Names that are no valid host names are rejected even when a pattern matches
+	for _, name := range []string{"a b.internal", "x..internal", strings.Repeat("a", 64) + ".internal", strings.Repeat("a.", 127) + "internal"} {
+		if _, err := issuer.GetCertificate(&tls.ClientHelloInfo{ServerName: name}); !errors.Is(err, ErrNameNotAllowed) {
+			t.Errorf("GetCertificate(%q) error = %v, want ErrNameNotAllowed", name, err)
+		}
+	}
+
+	// Common names are limited to 64 characters, the SAN carries the full name
+	long := strings.Repeat("a", 60) + "." + strings.Repeat("b", 60) + ".internal"
+	cert, err := issuer.GetCertificate(&tls.ClientHelloInfo{ServerName: long})
+	if err != nil {
+		t.Fatalf("GetCertificate() error = %v", err)
+	}
+	if cert.Leaf.Subject.CommonName != strings.Repeat("a", 60) || cert.Leaf.DNSNames[0] != long {
+		t.Errorf("Leaf CN = %q, DNS names = %v", cert.Leaf.Subject.CommonName, cert.Leaf.DNSNames)
+	}
+}
+
+func TestDynamicIssuerCache(t *testing.T) {
+	ca, err := NewCA()
+	if err != nil {
+		t.Fatalf("NewCA() error = %v", err)
+	}
+	issuer := NewDynamicIssuer(ca, WithCacheSize(2), WithLeafOptions(WithOrganization("Dynamic")))
+
+	get := func(name string) *tls.Certificate {
+		t.Helper()
+		cert, err := issuer.GetCertificate(&tls.ClientHelloInfo{ServerName: name})
+		if err != nil {
+			t.Fatalf("GetCertificate(%s) error = %v", name, err)
+		}
+		return cert
+	}
+
+	a := get("a.test")
+	if a.Leaf.Subject.Organization[0] != "Dynamic" || a.Leaf.DNSNames[0] != "a.test" {
+		t.Errorf("Unexpected leaf: subject %s, DNS names %v", a.Leaf.Subject, a.Leaf.DNSNames)
+	}
+	if get("a.test") != a {
+		t.Error("Expected the cached certificate")
+	}
+
+	get("b.test")
+	get("a.test") // a is now the most recently used
+	get("c.test") // evicts b
+	if get("a.test") != a {
+		t.Error("Expected a.test to survive eviction")
+	}
+	if _, ok := issuer.entries["b.test"]; ok {
+		t.Error("Expected b.test to be evicted")
+	}
+
+	ip := get("127.0.0.1")
+	if len(ip.Leaf.IPAddresses) != 1 || !ip.Leaf.IPAddresses[0].Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("Expected an IP address SAN, got %v", ip.Leaf.IPAddresses)
+	}
+}
+
+func TestDynamicIssuerRenewal(t *testing.T) {
+	ca, err := NewCA()
+	if err != nil {
+		t.Fatalf("NewCA() error = %v", err)
+	}
+	// The window ends within the last tenth of the lifetime, so the leaf is due for renewal at once
+	issuer := NewDynamicIssuer(ca, WithLeafOptions(WithWindow(time.Now().Add(-time.Hour), time.Now().Add(time.Minute))))
+
+	first, err := issuer.GetCertificate(&tls.ClientHelloInfo{ServerName: "renew.test"})
+	if err != nil {
+		t.Fatalf("GetCertificate() error = %v", err)
+	}
+	second, err := issuer.GetCertificate(&tls.ClientHelloInfo{ServerName: "renew.test"})
+	if err != nil {
+		t.Fatalf("GetCertificate() error = %v", err)
+	}
+	if first == second {
+		t.Error("Expected the certificate to be reissued")
+	}
+}
+
+func TestDynamicIssuerSingleFlight(t *testing.T) {
+	ca, err := NewCA()
+	if err != nil {
+		t.Fatalf("NewCA() error = %v", err)
+	}
+	issuer := NewDynamicIssuer(ca)
+
+	const n = 20
+	certs := make([]*tls.Certificate, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cert, err := issuer.GetCertificate(&tls.ClientHelloInfo{ServerName: "busy.test"})
+			if err != nil {
+				t.Errorf("GetCertificate() error = %v", err)
+			}
+			certs[i] = cert
+		}()
+	}
+	wg.Wait()
+
+	for _, cert := range certs {
+		if cert != certs[0] {
+			t.Fatal("Expected all handshakes to share one issued certificate")
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// ExtensionValueType selects how the value of a custom extension is encoded
+type ExtensionValueType string
+
+const (
+	// ExtensionValueDER takes the value as hex encoded DER, used verbatim (the default)
+	ExtensionValueDER ExtensionValueType = "der"
+	// ExtensionValueUTF8 encodes the value as a UTF8String
+	ExtensionValueUTF8 ExtensionValueType = "utf8"
+	// ExtensionValueIA5 encodes the value as an IA5String (ASCII only)
+	ExtensionValueIA5 ExtensionValueType = "ia5"
+	// ExtensionValuePrintable encodes the value as a PrintableString
+	ExtensionValuePrintable ExtensionValueType = "printable"
+	// ExtensionValueBMP encodes the value as a BMPString, e.g. for the Microsoft certificate template name
+	ExtensionValueBMP ExtensionValueType = "bmp"
+	// ExtensionValueOctets encodes the hex encoded value as an OCTET STRING
+	ExtensionValueOctets ExtensionValueType = "octets"
+	// ExtensionValueInteger encodes the decimal value as an INTEGER
+	ExtensionValueInteger ExtensionValueType = "integer"
+	// ExtensionValueBoolean encodes "true" or "false" as a BOOLEAN
+	ExtensionValueBoolean ExtensionValueType = "boolean"
+	// ExtensionValueNull encodes a NULL; the value must be empty
+	ExtensionValueNull ExtensionValueType = "null"
+	// ExtensionValueOID encodes the dotted value as an OBJECT IDENTIFIER
+	ExtensionValueOID ExtensionValueType = "oid"
+)
+
+// Extension is a custom X.509 extension identified by its dotted OID. Extensions that
+// certgen generates from other settings (see managedExtensions) cannot be set this way.
+type Extension struct {
+	OID      string             `json:"oid"`
+	Critical bool               `json:"critical,omitempty"`
+	Type     ExtensionValueType `json:"type,omitempty"`
+	Value    string             `json:"value,omitempty"`
+}
+
+// CertificatePolicy is a certificate policy OID with optional CPS (certification practice statement) URIs
+type CertificatePolicy struct {
+	OID     string   `json:"oid"`
+	CPSURIs []string `json:"cpsUris,omitempty"`
+}
+
+var (
+	oidExtensionCertificatePolicies = asn1.ObjectIdentifier{2, 5, 29, 32}
+	oidExtensionTLSFeature          = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 24}
+	oidPolicyQualifierCPS           = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 2, 1}
+)
+
+// managedExtensions lists the extensions certgen generates itself and where they come from.
+// Custom extensions with these OIDs would replace the generated ones, since x509 lets
+// ExtraExtensions override template fields, and so bypass name constraints and policies.
+var managedExtensions = []struct {
+	oid    asn1.ObjectIdentifier
+	source string
+}{
+	{asn1.ObjectIdentifier{2, 5, 29, 14}, "the subject key identifier is derived from the key"},
+	{asn1.ObjectIdentifier{2, 5, 29, 15}, "set key usages with keyUsage"},
+	{asn1.ObjectIdentifier{2, 5, 29, 17}, "set subject alternative names with dnsNames, ipAddresses, emailAddresses, uris or spiffeId"},
+	{asn1.ObjectIdentifier{2, 5, 29, 19}, "basic constraints follow from the certificate type"},
+	{asn1.ObjectIdentifier{2, 5, 29, 30}, "set name constraints with nameConstraints"},
+	{oidExtensionCertificatePolicies, "set certificate policies with certificatePolicies"},
+	{asn1.ObjectIdentifier{2, 5, 29, 35}, "the authority key identifier is derived from the CA"},
+	{asn1.ObjectIdentifier{2, 5, 29, 37}, "set extended key usages with extKeyUsage"},
+	{oidExtensionTLSFeature, "request OCSP stapling with mustStaple"},
+}
+
+// checkManaged rejects the OIDs of extensions certgen generates itself
+func checkManaged(oid asn1.ObjectIdentifier) error {
+	for _, managed := range managedExtensions {
+		if oid.Equal(managed.oid) {
+			return fmt.Errorf("extension %s cannot be set directly: %s", oid, managed.source)
+		}
+	}
+	return nil
+}
+
+// tlsFeatureStatusRequest is the status_request TLS extension number (RFC 7633), i.e. OCSP Must-Staple
+const tlsFeatureStatusRequest = 5
+
+// policyInformation is the ASN.1 PolicyInformation structure of RFC 5280 section 4.2.1.4
+type policyInformation struct {
+	Policy     asn1.ObjectIdentifier
+	Qualifiers []policyQualifierInfo `asn1:"optional,omitempty"`
+}
+
+type policyQualifierInfo struct {
+	ID        asn1.ObjectIdentifier
+	Qualifier asn1.RawValue
+}
+
+// extensions collects the custom extension settings shared by CAConfig and CertConfig
+type extensions struct {
+	Custom     []Extension
+	Policies   []CertificatePolicy
+	MustStaple bool
+}
+
+// apply adds the extensions to a certificate template
+func (e extensions) apply(template *x509.Certificate) error {
+	if len(e.Policies) > 0 {
+		ext, err := certificatePoliciesExtension(e.Policies)
+		if err != nil {
+			return err
+		}
+		template.ExtraExtensions = append(template.ExtraExtensions, ext)
+	}
+
+	if e.MustStaple {
+		value, err := asn1.Marshal([]int{tlsFeatureStatusRequest})
+		if err != nil {
+			return fmt.Errorf("failed to encode TLS feature extension: %w", err)
+		}
+		template.ExtraExtensions = append(template.ExtraExtensions, pkix.Extension{Id: oidExtensionTLSFeature, Value: value})
+	}
+
+	for _, custom := range e.Custom {
+		ext, err := custom.encode()
+		if err != nil {
+			return err
+		}
+		template.ExtraExtensions = append(template.ExtraExtensions, ext)
+	}
+
+	return nil
+}
+
+// validate reports invalid extension settings to the validator
+func (e extensions) validate(v *validator) {
+	seen := make(map[string]bool)
+	for i, custom := range e.Custom {
+		field := fmt.Sprintf("extensions[%d]", i)
+		oid, err := parseOID(custom.OID)
+		if err != nil {
+			v.add(field+".oid", err)
+			continue
+		}
+		if err := checkManaged(oid); err != nil {
+			v.add(field+".oid", err)
+			continue
+		}
+		if seen[oid.String()] {
+			v.addf(field+".oid", "duplicate extension %s", oid)
+		}
+		seen[oid.String()] = true
+
+		if _, err := custom.encode(); err != nil {
+			v.add(field+".value", err)
+		}
+	}
+
+	for i, policy := range e.Policies {
+		field := fmt.Sprintf("certificatePolicies[%d]", i)
+		if _, err := parseOID(policy.OID); err != nil {
+			v.add(field+".oid", err)
+		}
+		for j, uri := range policy.CPSURIs {
+			if err := checkCPSURI(uri); err != nil {
+				v.add(fmt.Sprintf("%s.cpsUris[%d]", field, j), err)
+			}
+		}
+	}
+}
+
+// encode builds the DER encoded extension
+func (e Extension) encode() (pkix.Extension, error) {
+	oid, err := parseOID(e.OID)
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	if err := checkManaged(oid); err != nil {
+		return pkix.Extension{}, err
+	}
+
+	value, err := encodeExtensionValue(e.Type, e.Value)
+	if err != nil {
+		return pkix.Extension{}, fmt.Errorf("extension %s: %w", oid, err)
+	}
+
+	return pkix.Extension{Id: oid, Critical: e.Critical, Value: value}, nil
+}
+
+// encodeExtensionValue encodes a typed extension value as DER
+func encodeExtensionValue(typ ExtensionValueType, value string) ([]byte, error) {
+	switch typ {
+	case "", ExtensionValueDER:
+		der, err := decodeHex(value)
+		if err != nil {
+			return nil, err
+		}
+		var raw asn1.RawValue
+		rest, err := asn1.Unmarshal(der, &raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DER value: %w", err)
+		}
+		if len(rest) > 0 {
+			return nil, errors.New("invalid DER value: trailing data")
+		}
+		return der, nil
+	case ExtensionValueUTF8:
+		return asn1.MarshalWithParams(value, "utf8")
+	case ExtensionValueIA5:
+		return asn1.MarshalWithParams(value, "ia5")
+	case ExtensionValuePrintable:
+		return asn1.MarshalWithParams(value, "printable")
+	case ExtensionValueBMP:
+		var b []byte
+		for _, u := range utf16.Encode([]rune(value)) {
+			b = append(b, byte(u>>8), byte(u))
+		}
+		return asn1.Marshal(asn1.RawValue{Tag: asn1.TagBMPString, Bytes: b})
+	case ExtensionValueOctets:
+		octets, err := decodeHex(value)
+		if err != nil {
+			return nil, err
+		}
+		return asn1.Marshal(octets)
+	case ExtensionValueInteger:
+		n, ok := new(big.Int).SetString(strings.TrimSpace(value), 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer %q", value)
+		}
+		return asn1.Marshal(n)
+	case ExtensionValueBoolean:
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid boolean %q", value)
+		}
+		return asn1.Marshal(b)
+	case ExtensionValueNull:
+		if value != "" {
+			return nil, errors.New("null extensions must not have a value")
+		}
+		return asn1.NullBytes, nil
+	case ExtensionValueOID:
+		oid, err := parseOID(value)
+		if err != nil {
+			return nil, err
+		}
+		return asn1.Marshal(oid)
+	default:
+		return nil, fmt.Errorf("unknown extension value type %q", typ)
+	}
+}
+
+// decodeHex decodes a hex string, ignoring colons and whitespace between bytes
+func decodeHex(s string) ([]byte, error) {
+	s = strings.NewReplacer(":", "", " ", "", "\n", "", "\t", "").Replace(s)
+	if s == "" {
+		return nil, errors.New("value is required")
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex value: %w", err)
+	}
+	return b, nil
+}
+
+// certificatePoliciesExtension encodes the certificate policies extension including CPS qualifiers
+func certificatePoliciesExtension(policies []CertificatePolicy) (pkix.Extension, error) {
+	infos := make([]policyInformation, 0, len(policies))
+	for _, policy := range policies {
+		oid, err := parseOID(policy.OID)
+		if err != nil {
+			return pkix.Extension{}, err
+		}
+		info := policyInformation{Policy: oid}
+		for _, uri := range policy.CPSURIs {
+			info.Qualifiers = append(info.Qualifiers, policyQualifierInfo{
+				ID:        oidPolicyQualifierCPS,
+				Qualifier: asn1.RawValue{Tag: asn1.TagIA5String, Bytes: []byte(strings.TrimSpace(uri))},
+			})
+		}
+		infos = append(infos, info)
+	}
+
+	value, err := asn1.Marshal(infos)
+	if err != nil {
+		return pkix.Extension{}, fmt.Errorf("failed to encode certificate policies: %w", err)
+	}
+	return pkix.Extension{Id: oidExtensionCertificatePolicies, Value: value}, nil
+}
+
+// checkCPSURI verifies that a CPS pointer is an absolute URI that fits into an IA5String
+func checkCPSURI(s string) error {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil || u.Scheme == "" {
+		return fmt.Errorf("invalid CPS URI %q", s)
+	}
+	for _, r := range s {
+		if r > 0x7f {
+			return fmt.Errorf("CPS URI %q must be ASCII", s)
+		}
+	}
+	return nil
+}
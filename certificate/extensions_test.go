@@ -0,0 +1,194 @@
+package certificate
+
+import (
+	"encoding/hex"
+	"slices"
+	"testing"
+)
+
+func TestGenerateCertExtensions(t *testing.T) {
+	caBundle, err := GenerateCA(CAConfig{
+		CommonName:          "Test CA",
+		ExpiryDays:          1,
+		CertificatePolicies: []CertificatePolicy{{OID: "2.5.29.32.0"}},
+	})
+	if err != nil {
+		t.Fatalf("GenerateCA() error = %v", err)
+	}
+
+	caInfo, err := Inspect(caBundle.CertPEM)
+	if err != nil {
+		t.Fatalf("Inspect() error = %v", err)
+	}
+	if len(caInfo.CertificatePolicies) != 1 || caInfo.CertificatePolicies[0].OID != "2.5.29.32.0" {
+		t.Errorf("Expected the anyPolicy OID on the CA, got %v", caInfo.CertificatePolicies)
+	}
+
+	config := CertConfig{
+		CommonName: "partner.example",
+		ExpiryDays: 1,
+		DNSNames:   []string{"partner.example"},
+		Extensions: []Extension{
+			{OID: "1.3.6.1.4.1.311.20.2", Type: ExtensionValueBMP, Value: "WebServer"},
+			{OID: "1.3.6.1.4.1.99999.1", Critical: true, Type: ExtensionValueUTF8, Value: "tenant-a"},
+			{OID: "1.3.6.1.4.1.99999.2", Value: "02:01:2a"},
+		},
+		CertificatePolicies: []CertificatePolicy{
+			{OID: "2.23.140.1.2.1"},
+			{OID: "1.3.6.1.4.1.99999.10.1", CPSURIs: []string{"https://pki.example/cps"}},
+		},
+		MustStaple: true,
+	}
+
+	bundle, err := GenerateCert(config, caBundle.CertPEM, caBundle.KeyPEM)
+	if err != nil {
+		t.Fatalf("GenerateCert() error = %v", err)
+	}
+
+	info, err := Inspect(bundle.CertPEM)
+	if err != nil {
+		t.Fatalf("Inspect() error = %v", err)
+	}
+
+	if !info.MustStaple {
+		t.Error("Expected the certificate to be Must-Staple")
+	}
+
+	wantPolicies := []CertificatePolicy{
+		{OID: "2.23.140.1.2.1"},
+		{OID: "1.3.6.1.4.1.99999.10.1", CPSURIs: []string{"https://pki.example/cps"}},
+	}
+	if len(info.CertificatePolicies) != len(wantPolicies) {
+		t.Fatalf("Expected policies %v, got %v", wantPolicies, info.CertificatePolicies)
+	}
+	for i, want := range wantPolicies {
+		got := info.CertificatePolicies[i]
+		if got.OID != want.OID || !slices.Equal(got.CPSURIs, want.CPSURIs) {
+			t.Errorf("Policy %d = %v, want %v", i, got, want)
+		}
+	}
+
+	wantExtensions := map[string]struct {
+		critical bool
+		value    string
+	}{
+		// BMPString "WebServer"
+		"1.3.6.1.4.1.311.20.2": {value: "1e12005700650062005300650072007600650072"},
+		// UTF8String "tenant-a"
+		"1.3.6.1.4.1.99999.1": {critical: true, value: "0c0874656e616e742d61"},
+		// INTEGER 42, given as raw DER
+		"1.3.6.1.4.1.99999.2": {value: "02012a"},
+	}
+	for oid, want := range wantExtensions {
+		i := slices.IndexFunc(info.Extensions, func(e ExtensionInfo) bool { return e.OID == oid })
+		if i < 0 {
+			t.Errorf("Extension %s not found", oid)
+			continue
+		}
+		got := info.Extensions[i]
+		if got.Critical != want.critical {
+			t.Errorf("Extension %s critical = %v, want %v", oid, got.Critical, want.critical)
+		}
+		if got.Value != want.value {
+			t.Errorf("Extension %s value = %s, want %s", oid, got.Value, want.value)
+		}
+	}
+}
+
+func TestEncodeExtensionValue(t *testing.T) {
+	tests := []struct {
+		typ     ExtensionValueType
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{typ: ExtensionValueIA5, value: "abc", want: "1603616263"},
+		{typ: ExtensionValuePrintable, value: "ABC", want: "1303414243"},
+		{typ: ExtensionValueOctets, value: "de:ad", want: "0402dead"},
+		{typ: ExtensionValueInteger, value: "256", want: "02020100"},
+		{typ: ExtensionValueBoolean, value: "true", want: "0101ff"},
+		{typ: ExtensionValueNull, want: "0500"},
+		{typ: ExtensionValueOID, value: "1.2.3", want: "06022a03"},
+		{typ: ExtensionValueDER, value: "0500ff", wantErr: true},
+		{typ: ExtensionValueDER, value: "zz", wantErr: true},
+		{typ: ExtensionValueIA5, value: "ä", wantErr: true},
+		{typ: ExtensionValueNull, value: "x", wantErr: true},
+		{typ: "float", value: "1.5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := encodeExtensionValue(tt.typ, tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("encodeExtensionValue(%q, %q) error = %v, wantErr %v", tt.typ, tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && hex.EncodeToString(got) != tt.want {
+			t.Errorf("encodeExtensionValue(%q, %q) = %x, want %s", tt.typ, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestExtensionsValidate(t *testing.T) {
+	config := CertConfig{
+		CommonName: "leaf",
+		ExpiryDays: 1,
+		Extensions: []Extension{
+			{OID: "not-an-oid"},
+			{OID: "1.2.3.4", Type: ExtensionValueInteger, Value: "ten"},
+			{OID: "1.3.6.1.5.5.7.1.24", Value: "3003020105"},
+		},
+		CertificatePolicies: []CertificatePolicy{
+			{OID: "1.2.3", CPSURIs: []string{"pki.example/cps"}},
+		},
+		MustStaple: true,
+	}
+
+	assertFieldErrors(t, config.Validate(), []string{
+		"extensions[0].oid",
+		"extensions[1].value",
+		"extensions[2].oid",
+		"certificatePolicies[0].cpsUris[0]",
+	})
+}
+
+func TestManagedExtensions(t *testing.T) {
+	caBundle, err := GenerateCA(CAConfig{
+		CommonName:      "Constrained CA",
+		ExpiryDays:      1,
+		NameConstraints: NameConstraints{PermittedDNSDomains: []string{"internal.example"}},
+	})
+	if err != nil {
+		t.Fatalf("GenerateCA() error = %v", err)
+	}
+
+	// Each value is a well-formed extension that would widen what the certificate may be used for
+	values := map[string]string{
+		"2.5.29.14":          "04020102",
+		"2.5.29.15":          "030205a0",
+		"2.5.29.17":          "3010820e7777772e676f6f676c652e636f6d", // DNS:www.google.com
+		"2.5.29.19":          "30030101ff",                           // CA:TRUE
+		"2.5.29.30":          "3000",
+		"2.5.29.32":          "3006300406022a03",
+		"2.5.29.35":          "300480020102",
+		"2.5.29.37":          "300a06082b06010505070303", // codeSigning
+		"1.3.6.1.5.5.7.1.24": "3003020105",
+	}
+	for oid, value := range values {
+		t.Run(oid, func(t *testing.T) {
+			config := CertConfig{
+				CommonName: "app.internal.example",
+				ExpiryDays: 1,
+				DNSNames:   []string{"app.internal.example"},
+				Extensions: []Extension{{OID: oid, Value: value}},
+			}
+			assertFieldErrors(t, config.Validate(), []string{"extensions[0].oid"})
+
+			if _, err := GenerateCertWithPolicy(config, &Policy{AllowedSANPatterns: []string{"*.internal.example"}}, caBundle.CertPEM, caBundle.KeyPEM); err == nil {
+				t.Errorf("Expected extension %s to be rejected", oid)
+			}
+			if _, err := (Extension{OID: oid, Value: value}).encode(); err == nil {
+				t.Errorf("Expected encoding extension %s to fail", oid)
+			}
+		})
+	}
+}
@@ -0,0 +1,547 @@
+package certificate
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidCA is returned when the CA certificate or private key used for signing cannot be parsed
+var ErrInvalidCA = errors.New("invalid CA")
+
+// CAConfig holds configuration for CA certificate generation.
+// Empty subject attributes are omitted from the generated certificate.
+type CAConfig struct {
+	Organization string
+	CommonName   string
+	Country      string
+	Locality     string
+	ExpiryDays   int
+	// Validity overrides ExpiryDays with a duration such as "15m", "6h" or "90d"
+	Validity string
+	// NotBefore and NotAfter pin the validity window explicitly. Without NotBefore the
+	// window starts now, backdated by Backdate (DefaultBackdate when zero, disabled when
+	// negative); without NotAfter it ends Validity or ExpiryDays after the start.
+	NotBefore time.Time
+	NotAfter  time.Time
+	Backdate  time.Duration
+	// Additional distinguished name attributes
+	OrganizationalUnit string
+	Province           string
+	StreetAddress      string
+	PostalCode         string
+	SerialNumber       string
+	ExtraAttributes    []Attribute
+	// DN is an RFC 4514 distinguished name (e.g. "CN=foo,OU=bar,O=baz") whose
+	// attributes take precedence over the individual fields above
+	DN string
+	// NameConstraints limits the names the CA may issue certificates for
+	NameConstraints NameConstraints
+	// Extensions are added as custom X.509 extensions, CertificatePolicies builds the
+	// certificate policies extension and MustStaple the TLS feature extension (RFC 7633)
+	Extensions          []Extension
+	CertificatePolicies []CertificatePolicy
+	MustStaple          bool
+	// Serial pins the serial number (decimal, "0x" hex or colon separated hex, see ParseSerial);
+	// a random 128-bit serial is used when empty
+	Serial string
+}
+
+// CertType identifies the intended usage of a leaf certificate
+type CertType string
+
+const (
+	// CertTypeServer is a certificate used for TLS server authentication
+	CertTypeServer CertType = "server"
+	// CertTypeClient is a certificate used for TLS client authentication
+	CertTypeClient CertType = "client"
+	// CertTypePeer is a certificate used for both server and client authentication,
+	// as required by cluster members talking mutual TLS to each other (etcd, Consul, ...)
+	CertTypePeer CertType = "peer"
+)
+
+// ParseCertType converts a string into a CertType, defaulting to CertTypeServer when empty
+func ParseCertType(s string) (CertType, error) {
+	switch CertType(s) {
+	case "", CertTypeServer:
+		return CertTypeServer, nil
+	case CertTypeClient:
+		return CertTypeClient, nil
+	case CertTypePeer:
+		return CertTypePeer, nil
+	default:
+		return "", fmt.Errorf("unknown certificate type %q", s)
+	}
+}
+
+// ExtKeyUsages returns the extended key usages for the certificate type
+func (t CertType) ExtKeyUsages() []x509.ExtKeyUsage {
+	switch t {
+	case CertTypeClient:
+		return []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	case CertTypePeer:
+		return []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	default:
+		return []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	}
+}
+
+// HasSANs reports whether certificates of this type carry DNS and IP subject alternative names
+func (t CertType) HasSANs() bool {
+	return t != CertTypeClient
+}
+
+// CertConfig holds configuration for client/server/peer certificate generation.
+// Empty subject attributes are omitted from the generated certificate.
+type CertConfig struct {
+	Organization string
+	CommonName   string
+	Country      string
+	Locality     string
+	ExpiryDays   int
+	// Validity overrides ExpiryDays with a duration such as "15m", "6h" or "90d"
+	Validity string
+	// NotBefore and NotAfter pin the validity window explicitly. Without NotBefore the
+	// window starts now, backdated by Backdate (DefaultBackdate when zero, disabled when
+	// negative); without NotAfter it ends Validity or ExpiryDays after the start.
+	NotBefore time.Time
+	NotAfter  time.Time
+	Backdate  time.Duration
+	// Additional distinguished name attributes
+	OrganizationalUnit string
+	Province           string
+	StreetAddress      string
+	PostalCode         string
+	SerialNumber       string
+	ExtraAttributes    []Attribute
+	// DN is an RFC 4514 distinguished name (e.g. "CN=foo,OU=bar,O=baz") whose
+	// attributes take precedence over the individual fields above
+	DN          string
+	Type        CertType
+	DNSNames    []string
+	IPAddresses []string
+	// URIs and EmailAddresses are added as subject alternative names for every certificate type
+	URIs           []string
+	EmailAddresses []string
+	// SPIFFEID builds an X.509-SVID: the ID becomes the single URI SAN and no CommonName is required
+	SPIFFEID string
+	// KeyUsage and ExtKeyUsage override the defaults of Type. Key usages are RFC 5280 names
+	// (see KeyUsageNames), extended key usages are names (see ExtKeyUsageNames) or dotted OIDs.
+	KeyUsage    []string
+	ExtKeyUsage []string
+	// SerialStrategy selects how the serial number is chosen, SerialRandom when empty.
+	// SerialSpecified uses Serial, SerialSequential asks SerialCounter for the next
+	// number of the issuing CA.
+	SerialStrategy SerialStrategy
+	Serial         string
+	SerialCounter  SerialCounter
+	// Extensions are added as custom X.509 extensions, CertificatePolicies builds the
+	// certificate policies extension and MustStaple the TLS feature extension (RFC 7633)
+	Extensions          []Extension
+	CertificatePolicies []CertificatePolicy
+	MustStaple          bool
+}
+
+// validity returns the validity period settings of the CA config
+func (c CAConfig) validity() validity {
+	return validity{
+		ExpiryDays: c.ExpiryDays,
+		Duration:   c.Validity,
+		NotBefore:  c.NotBefore,
+		NotAfter:   c.NotAfter,
+		Backdate:   c.Backdate,
+	}
+}
+
+// subject returns the distinguished name attributes of the CA config
+func (c CAConfig) subject() subject {
+	return subject{
+		Organization:       c.Organization,
+		OrganizationalUnit: c.OrganizationalUnit,
+		CommonName:         c.CommonName,
+		Country:            c.Country,
+		Province:           c.Province,
+		Locality:           c.Locality,
+		StreetAddress:      c.StreetAddress,
+		PostalCode:         c.PostalCode,
+		SerialNumber:       c.SerialNumber,
+		ExtraAttributes:    c.ExtraAttributes,
+		DN:                 c.DN,
+	}
+}
+
+// extensions returns the custom extension settings of the CA config
+func (c CAConfig) extensions() extensions {
+	return extensions{
+		Custom:     c.Extensions,
+		Policies:   c.CertificatePolicies,
+		MustStaple: c.MustStaple,
+	}
+}
+
+// validity returns the validity period settings of the certificate config
+func (c CertConfig) validity() validity {
+	return validity{
+		ExpiryDays: c.ExpiryDays,
+		Duration:   c.Validity,
+		NotBefore:  c.NotBefore,
+		NotAfter:   c.NotAfter,
+		Backdate:   c.Backdate,
+	}
+}
+
+// subject returns the distinguished name attributes of the certificate config
+func (c CertConfig) subject() subject {
+	return subject{
+		Organization:       c.Organization,
+		OrganizationalUnit: c.OrganizationalUnit,
+		CommonName:         c.CommonName,
+		Country:            c.Country,
+		Province:           c.Province,
+		Locality:           c.Locality,
+		StreetAddress:      c.StreetAddress,
+		PostalCode:         c.PostalCode,
+		SerialNumber:       c.SerialNumber,
+		ExtraAttributes:    c.ExtraAttributes,
+		DN:                 c.DN,
+	}
+}
+
+// extensions returns the custom extension settings of the certificate config
+func (c CertConfig) extensions() extensions {
+	return extensions{
+		Custom:     c.Extensions,
+		Policies:   c.CertificatePolicies,
+		MustStaple: c.MustStaple,
+	}
+}
+
+// CertBundle contains PEM-encoded certificate and private key
+type CertBundle struct {
+	CertPEM []byte
+	KeyPEM  []byte
+}
+
+// UnifiedPEM returns a single PEM file containing both the certificate and private key
+func (cb *CertBundle) UnifiedPEM() []byte {
+	// Ensure PEM blocks are properly separated with newlines
+	unified := make([]byte, 0, len(cb.CertPEM)+len(cb.KeyPEM)+1)
+
+	// Add certificate
+	unified = append(unified, cb.CertPEM...)
+
+	// Ensure there's a newline between certificate and private key
+	if len(unified) > 0 && unified[len(unified)-1] != '\n' {
+		unified = append(unified, '\n')
+	}
+
+	// Add private key
+	unified = append(unified, cb.KeyPEM...)
+
+	return unified
+}
+
+// ChainPEM returns a PEM file containing the leaf certificate followed by the CA certificate
+func (cb *CertBundle) ChainPEM(caCertPEM []byte) []byte {
+	// Ensure PEM blocks are properly separated with newlines
+	chain := make([]byte, 0, len(cb.CertPEM)+len(caCertPEM)+1)
+
+	// Add certificate
+	chain = append(chain, cb.CertPEM...)
+
+	// Ensure there's a newline between certificate and CA certificate
+	if len(chain) > 0 && chain[len(chain)-1] != '\n' {
+		chain = append(chain, '\n')
+	}
+
+	// Add CA certificate
+	chain = append(chain, caCertPEM...)
+
+	return chain
+}
+
+// FullChainPEM returns a PEM file containing the leaf cert, CA cert, and private key
+func (cb *CertBundle) FullChainPEM(caCertPEM []byte) []byte {
+	// Ensure PEM blocks are properly separated with newlines
+	fullChain := make([]byte, 0, len(cb.CertPEM)+len(caCertPEM)+len(cb.KeyPEM)+2)
+
+	// Add certificate
+	fullChain = append(fullChain, cb.CertPEM...)
+
+	// Ensure there's a newline between certificate and CA certificate
+	if len(fullChain) > 0 && fullChain[len(fullChain)-1] != '\n' {
+		fullChain = append(fullChain, '\n')
+	}
+
+	// Add CA certificate
+	fullChain = append(fullChain, caCertPEM...)
+
+	// Ensure there's a newline between CA certificate and private key
+	if len(fullChain) > 0 && fullChain[len(fullChain)-1] != '\n' {
+		fullChain = append(fullChain, '\n')
+	}
+
+	// Add private key
+	fullChain = append(fullChain, cb.KeyPEM...)
+
+	return fullChain
+}
+
+// GenerateCA creates a new CA certificate and private key
+func GenerateCA(config CAConfig) (*CertBundle, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Generate private key
+	privKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate private key: %w", err)
+	}
+
+	// Prepare certificate template
+	serialNumber, err := config.serialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	// A self-signed CA identifies itself, so its authority key identifier equals its own
+	keyID, err := keyIdentifier(&privKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	subjectName, err := config.subject().name()
+	if err != nil {
+		return nil, fmt.Errorf("invalid subject: %w", err)
+	}
+
+	notBefore, notAfter, err := config.validity().window(time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("invalid validity: %w", err)
+	}
+
+	// MaxPathLen allows one intermediate CA below the CA. certgen does not generate
+	// intermediates, but signs with one uploaded together with its chain and enforces the
+	// constraints of the whole chain.
+	template := x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               subjectName,
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLen:            1,
+		SubjectKeyId:          keyID,
+		AuthorityKeyId:        keyID,
+	}
+
+	if err := config.NameConstraints.apply(&template); err != nil {
+		return nil, fmt.Errorf("invalid name constraints: %w", err)
+	}
+
+	if err := config.extensions().apply(&template); err != nil {
+		return nil, fmt.Errorf("invalid extensions: %w", err)
+	}
+
+	// Create certificate
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &privKey.PublicKey, privKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	return encodeBundle(certDER, privKey)
+}
+
+// GenerateCert creates a new client, server or peer certificate signed by the provided CA
+func GenerateCert(config CertConfig, caCertPEM, caKeyPEM []byte) (*CertBundle, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	caCert, caKey, err := parseCA(caCertPEM, caKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	issuers, err := parseIssuers(caCert, caCertPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate private key for new certificate
+	privKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate private key: %w", err)
+	}
+
+	template, err := leafTemplate(config, caCert, &privKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Refuse to issue names the CA or any CA above it is not allowed to certify
+	if err := checkChainConstraints(append([]*x509.Certificate{caCert}, issuers...), template); err != nil {
+		return nil, err
+	}
+
+	// Take the serial last so rejected requests leave no gaps in sequential serials
+	template.SerialNumber, err = config.serialNumber(caCert)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create certificate
+	certDER, err := x509.CreateCertificate(rand.Reader, template, caCert, &privKey.PublicKey, caKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	return encodeBundle(certDER, privKey)
+}
+
+// parseCA decodes and parses a PEM encoded CA certificate and private key
+func parseCA(caCertPEM, caKeyPEM []byte) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	caCertBlock, _ := pem.Decode(caCertPEM)
+	if caCertBlock == nil {
+		return nil, nil, fmt.Errorf("%w: failed to decode CA certificate PEM", ErrInvalidCA)
+	}
+
+	caCert, err := x509.ParseCertificate(caCertBlock.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: failed to parse CA certificate: %w", ErrInvalidCA, err)
+	}
+
+	caKeyBlock, _ := pem.Decode(caKeyPEM)
+	if caKeyBlock == nil {
+		return nil, nil, fmt.Errorf("%w: failed to decode CA private key PEM", ErrInvalidCA)
+	}
+
+	caKey, err := x509.ParseECPrivateKey(caKeyBlock.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: failed to parse CA private key: %w", ErrInvalidCA, err)
+	}
+
+	return caCert, caKey, nil
+}
+
+// parseIssuers parses the certificates following the signing CA in caCertPEM, which lets an
+// intermediate CA be uploaded together with the chain up to its root. Every certificate must
+// have signed the one before it.
+func parseIssuers(caCert *x509.Certificate, caCertPEM []byte) ([]*x509.Certificate, error) {
+	var issuers []*x509.Certificate
+	_, rest := pem.Decode(caCertPEM)
+	child := caCert
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return issuers, nil
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		issuer, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to parse CA chain certificate %d: %w", ErrInvalidCA, len(issuers)+1, err)
+		}
+		if err := child.CheckSignatureFrom(issuer); err != nil {
+			return nil, fmt.Errorf("%w: CA chain certificate %d did not issue the certificate before it: %w", ErrInvalidCA, len(issuers)+1, err)
+		}
+		issuers = append(issuers, issuer)
+		child = issuer
+	}
+}
+
+// leafTemplate prepares the certificate template for a client, server or peer certificate
+// with the public key pub, issued by caCert. The serial number is left for the caller to set
+// once the request has passed all checks.
+func leafTemplate(config CertConfig, caCert *x509.Certificate, pub crypto.PublicKey) (*x509.Certificate, error) {
+	keyUsage, err := config.keyUsages()
+	if err != nil {
+		return nil, err
+	}
+
+	extKeyUsage, unknownExtKeyUsage, err := config.extKeyUsages()
+	if err != nil {
+		return nil, err
+	}
+
+	subjectKeyID, err := keyIdentifier(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	// CAs created before key identifiers were set explicitly may lack one, so fall back
+	// to computing it from the CA public key
+	authorityKeyID := caCert.SubjectKeyId
+	if len(authorityKeyID) == 0 {
+		authorityKeyID, err = keyIdentifier(caCert.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	subjectName, err := config.subject().name()
+	if err != nil {
+		return nil, fmt.Errorf("invalid subject: %w", err)
+	}
+
+	notBefore, notAfter, err := config.validity().window(time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("invalid validity: %w", err)
+	}
+
+	template := &x509.Certificate{
+		Subject:               subjectName,
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              keyUsage,
+		ExtKeyUsage:           extKeyUsage,
+		UnknownExtKeyUsage:    unknownExtKeyUsage,
+		BasicConstraintsValid: true,
+		IsCA:                  false,
+		SubjectKeyId:          subjectKeyID,
+		AuthorityKeyId:        authorityKeyID,
+	}
+
+	if err := applySANs(template, config); err != nil {
+		return nil, err
+	}
+
+	if err := config.extensions().apply(template); err != nil {
+		return nil, fmt.Errorf("invalid extensions: %w", err)
+	}
+
+	return template, nil
+}
+
+// encodeBundle PEM encodes a DER certificate and its private key
+func encodeBundle(certDER []byte, privKey *ecdsa.PrivateKey) (*CertBundle, error) {
+	certPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: certDER,
+	})
+
+	privKeyDER, err := x509.MarshalECPrivateKey(privKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal private key: %w", err)
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "EC PRIVATE KEY",
+		Bytes: privKeyDER,
+	})
+
+	return &CertBundle{
+		CertPEM: certPEM,
+		KeyPEM:  keyPEM,
+	}, nil
+}
@@ -33,7 +33,7 @@ func TestGenerateCA(t *testing.T) {
 				Locality:     "Test City",
 				ExpiryDays:   0,
 			},
-			wantErr: false,
+			wantErr: true,
 		},
 	}
 
@@ -114,7 +114,7 @@ func TestGenerateCert(t *testing.T) {
 				Country:      "US",
 				Locality:     "Test City",
 				ExpiryDays:   365,
-				IsClient:     true,
+				Type:         CertTypeClient,
 			},
 			wantErr: false,
 		},
@@ -126,11 +126,24 @@ func TestGenerateCert(t *testing.T) {
 				Country:      "US",
 				Locality:     "Test City",
 				ExpiryDays:   365,
-				IsClient:     false,
+				Type:         CertTypeServer,
 				DNSNames:     []string{"localhost", "example.com"},
 			},
 			wantErr: false,
 		},
+		{
+			name: "valid peer certificate",
+			config: CertConfig{
+				Organization: "Test Org",
+				CommonName:   "Test Peer",
+				Country:      "US",
+				Locality:     "Test City",
+				ExpiryDays:   365,
+				Type:         CertTypePeer,
+				DNSNames:     []string{"etcd-0.cluster.local"},
+			},
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -186,30 +199,20 @@ func TestGenerateCert(t *testing.T) {
 				}
 
 				// Verify certificate usage
-				if tt.config.IsClient {
+				for _, want := range tt.config.Type.ExtKeyUsages() {
 					found := false
 					for _, usage := range cert.ExtKeyUsage {
-						if usage == x509.ExtKeyUsageClientAuth {
+						if usage == want {
 							found = true
 							break
 						}
 					}
 					if !found {
-						t.Error("Client certificate missing ExtKeyUsageClientAuth")
-					}
-				} else {
-					found := false
-					for _, usage := range cert.ExtKeyUsage {
-						if usage == x509.ExtKeyUsageServerAuth {
-							found = true
-							break
-						}
+						t.Errorf("%s certificate missing ExtKeyUsage %v", tt.config.Type, want)
 					}
-					if !found {
-						t.Error("Server certificate missing ExtKeyUsageServerAuth")
-					}
-
-					// Verify DNS names for server certificates
+				}
+				if tt.config.Type.HasSANs() {
+					// Verify DNS names for server and peer certificates
 					if len(tt.config.DNSNames) > 0 {
 						if len(cert.DNSNames) != len(tt.config.DNSNames) {
 							t.Errorf("Expected %d DNS names, got %d", len(tt.config.DNSNames), len(cert.DNSNames))
@@ -233,7 +236,7 @@ func TestGenerateCertInvalidCA(t *testing.T) {
 		Country:      "US",
 		Locality:     "Test City",
 		ExpiryDays:   365,
-		IsClient:     true,
+		Type:         CertTypeClient,
 	}
 
 	// Test with invalid CA PEM data
@@ -264,7 +267,7 @@ func TestChainPEM(t *testing.T) {
 		Country:      "US",
 		Locality:     "Test City",
 		ExpiryDays:   365,
-		IsClient:     true,
+		Type:         CertTypeClient,
 	}
 	bundle, err := GenerateCert(certConfig, ca.CertPEM, ca.KeyPEM)
 	if err != nil {
@@ -336,7 +339,7 @@ func TestFullChainPEM(t *testing.T) {
 		Country:      "US",
 		Locality:     "Test City",
 		ExpiryDays:   365,
-		IsClient:     false,
+		Type:         CertTypeServer,
 		DNSNames:     []string{"localhost"},
 	}
 	bundle, err := GenerateCert(certConfig, ca.CertPEM, ca.KeyPEM)
@@ -397,3 +400,28 @@ func TestFullChainPEM(t *testing.T) {
 		t.Error("First certificate in full chain should be leaf (non-CA), but it's a CA")
 	}
 }
+
+func TestParseCertType(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    CertType
+		wantErr bool
+	}{
+		{input: "", want: CertTypeServer},
+		{input: "server", want: CertTypeServer},
+		{input: "client", want: CertTypeClient},
+		{input: "peer", want: CertTypePeer},
+		{input: "bogus", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseCertType(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseCertType(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseCertType(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
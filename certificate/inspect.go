@@ -0,0 +1,153 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// CertInfo is a readable summary of a certificate, including all of its extensions
+type CertInfo struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serialNumber"`
+	NotBefore    time.Time `json:"notBefore"`
+	NotAfter     time.Time `json:"notAfter"`
+	IsCA         bool      `json:"isCa"`
+	KeyUsage     []string  `json:"keyUsage,omitempty"`
+	ExtKeyUsage  []string  `json:"extKeyUsage,omitempty"`
+	SubjectKeyID string    `json:"subjectKeyId,omitempty"`
+	// PublicKeyFingerprint is the SHA-256 of the public key, which identifies a CA in policies
+	PublicKeyFingerprint string              `json:"publicKeyFingerprint"`
+	AuthorityKeyID       string              `json:"authorityKeyId,omitempty"`
+	DNSNames             []string            `json:"dnsNames,omitempty"`
+	IPAddresses          []string            `json:"ipAddresses,omitempty"`
+	URIs                 []string            `json:"uris,omitempty"`
+	EmailAddresses       []string            `json:"emailAddresses,omitempty"`
+	CertificatePolicies  []CertificatePolicy `json:"certificatePolicies,omitempty"`
+	MustStaple           bool                `json:"mustStaple"`
+	Extensions           []ExtensionInfo     `json:"extensions"`
+}
+
+// ExtensionInfo describes a single extension of an inspected certificate
+type ExtensionInfo struct {
+	OID      string `json:"oid"`
+	Name     string `json:"name,omitempty"`
+	Critical bool   `json:"critical"`
+	// Value is the hex encoded DER value of the extension
+	Value string `json:"value"`
+}
+
+// extensionNames maps well-known extension OIDs to readable names
+var extensionNames = map[string]string{
+	"2.5.29.14":               "Subject Key Identifier",
+	"2.5.29.15":               "Key Usage",
+	"2.5.29.17":               "Subject Alternative Name",
+	"2.5.29.19":               "Basic Constraints",
+	"2.5.29.30":               "Name Constraints",
+	"2.5.29.31":               "CRL Distribution Points",
+	"2.5.29.32":               "Certificate Policies",
+	"2.5.29.35":               "Authority Key Identifier",
+	"2.5.29.37":               "Extended Key Usage",
+	"1.3.6.1.5.5.7.1.1":       "Authority Information Access",
+	"1.3.6.1.5.5.7.1.24":      "TLS Feature",
+	"1.3.6.1.4.1.311.20.2":    "Microsoft Certificate Template Name",
+	"1.3.6.1.4.1.311.21.7":    "Microsoft Certificate Template",
+	"1.3.6.1.4.1.311.21.10":   "Microsoft Application Policies",
+	"1.3.6.1.4.1.11129.2.4.2": "Signed Certificate Timestamps",
+}
+
+// Inspect parses a PEM encoded certificate and summarizes it
+func Inspect(certPEM []byte) (*CertInfo, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("failed to decode certificate PEM")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	info := &CertInfo{
+		Subject:              cert.Subject.String(),
+		Issuer:               cert.Issuer.String(),
+		SerialNumber:         colonHex(cert.SerialNumber.Bytes()),
+		NotBefore:            cert.NotBefore,
+		NotAfter:             cert.NotAfter,
+		IsCA:                 cert.IsCA,
+		KeyUsage:             keyUsageList(cert.KeyUsage),
+		ExtKeyUsage:          extKeyUsageList(cert.ExtKeyUsage, cert.UnknownExtKeyUsage),
+		SubjectKeyID:         colonHex(cert.SubjectKeyId),
+		PublicKeyFingerprint: spkiFingerprint(cert),
+		AuthorityKeyID:       colonHex(cert.AuthorityKeyId),
+		DNSNames:             cert.DNSNames,
+		EmailAddresses:       cert.EmailAddresses,
+	}
+	for _, ip := range cert.IPAddresses {
+		info.IPAddresses = append(info.IPAddresses, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		info.URIs = append(info.URIs, uri.String())
+	}
+
+	for _, ext := range cert.Extensions {
+		info.Extensions = append(info.Extensions, ExtensionInfo{
+			OID:      ext.Id.String(),
+			Name:     extensionNames[ext.Id.String()],
+			Critical: ext.Critical,
+			Value:    hex.EncodeToString(ext.Value),
+		})
+
+		switch {
+		case ext.Id.Equal(oidExtensionCertificatePolicies):
+			policies, err := parseCertificatePolicies(ext.Value)
+			if err != nil {
+				return nil, err
+			}
+			info.CertificatePolicies = policies
+		case ext.Id.Equal(oidExtensionTLSFeature):
+			var features []int
+			if _, err := asn1.Unmarshal(ext.Value, &features); err != nil {
+				return nil, fmt.Errorf("failed to parse TLS feature extension: %w", err)
+			}
+			info.MustStaple = slices.Contains(features, tlsFeatureStatusRequest)
+		}
+	}
+
+	return info, nil
+}
+
+// parseCertificatePolicies decodes the certificate policies extension, keeping CPS qualifiers only
+func parseCertificatePolicies(der []byte) ([]CertificatePolicy, error) {
+	var infos []policyInformation
+	if _, err := asn1.Unmarshal(der, &infos); err != nil {
+		return nil, fmt.Errorf("failed to parse certificate policies: %w", err)
+	}
+
+	policies := make([]CertificatePolicy, 0, len(infos))
+	for _, info := range infos {
+		policy := CertificatePolicy{OID: info.Policy.String()}
+		for _, qualifier := range info.Qualifiers {
+			if qualifier.ID.Equal(oidPolicyQualifierCPS) && qualifier.Qualifier.Tag == asn1.TagIA5String {
+				policy.CPSURIs = append(policy.CPSURIs, string(qualifier.Qualifier.Bytes))
+			}
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// colonHex formats bytes as colon separated uppercase hex, as printed by OpenSSL
+func colonHex(b []byte) string {
+	parts := make([]string, len(b))
+	for i, c := range b {
+		parts[i] = fmt.Sprintf("%02X", c)
+	}
+	return strings.Join(parts, ":")
+}
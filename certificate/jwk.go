@@ -0,0 +1,234 @@
+package certificate
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+)
+
+// JWK is a JSON Web Key (RFC 7517) for the key of a certificate. The certificate and its
+// chain are included in x5c, so relying parties can verify the key against the CA.
+type JWK struct {
+	Kty string `json:"kty"`
+	// Crv, X, Y and D are set for EC keys
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	D   string `json:"d,omitempty"`
+	// N and E are set for RSA keys
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// X5C holds the base64 (not base64url) DER certificates, leaf first
+	X5C     []string `json:"x5c"`
+	X5TS256 string   `json:"x5t#S256"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []*JWK `json:"keys"`
+}
+
+// PublicJWK returns the certificate's public key as a JWK. When caCertPEM is set, the CA
+// certificate is appended to the x5c chain.
+func (cb *CertBundle) PublicJWK(caCertPEM []byte) (*JWK, error) {
+	cert, chain, err := jwkChain(cb.CertPEM, caCertPEM)
+	if err != nil {
+		return nil, err
+	}
+	return newJWK(cert, chain)
+}
+
+// PrivateJWK returns the certificate's key pair as a JWK including the private key
+func (cb *CertBundle) PrivateJWK(caCertPEM []byte) (*JWK, error) {
+	jwk, err := cb.PublicJWK(caCertPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(cb.KeyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode private key PEM")
+	}
+	privKey, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	ecdhKey, err := privKey.ECDH()
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert private key: %w", err)
+	}
+	jwk.D = base64.RawURLEncoding.EncodeToString(ecdhKey.Bytes())
+	return jwk, nil
+}
+
+// PublicJWKS returns the public JWK of the certificate as a key set
+func (cb *CertBundle) PublicJWKS(caCertPEM []byte) (*JWKS, error) {
+	jwk, err := cb.PublicJWK(caCertPEM)
+	if err != nil {
+		return nil, err
+	}
+	return &JWKS{Keys: []*JWK{jwk}}, nil
+}
+
+// ParseJWKS converts every certificate in certsPEM into a public JWK. Each key's x5c only
+// holds its own certificate, since the PEM input may mix unrelated certificates.
+func ParseJWKS(certsPEM []byte) (*JWKS, error) {
+	jwks := &JWKS{Keys: []*JWK{}}
+	for rest := certsPEM; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate %d: %w", len(jwks.Keys)+1, err)
+		}
+		jwk, err := newJWK(cert, [][]byte{cert.Raw})
+		if err != nil {
+			return nil, err
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	if len(jwks.Keys) == 0 {
+		return nil, fmt.Errorf("no certificates found in PEM input")
+	}
+	return jwks, nil
+}
+
+// jwkChain parses the leaf certificate and returns it with the DER chain for x5c
+func jwkChain(certPEM, caCertPEM []byte) (*x509.Certificate, [][]byte, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, nil, fmt.Errorf("failed to decode certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	chain := [][]byte{cert.Raw}
+	if len(caCertPEM) > 0 {
+		caBlock, _ := pem.Decode(caCertPEM)
+		if caBlock == nil || caBlock.Type != "CERTIFICATE" {
+			return nil, nil, fmt.Errorf("failed to decode CA certificate PEM")
+		}
+		if _, err := x509.ParseCertificate(caBlock.Bytes); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+		}
+		chain = append(chain, caBlock.Bytes)
+	}
+	return cert, chain, nil
+}
+
+// newJWK builds the public JWK of cert. The kid is the hex encoded subject key identifier,
+// so it matches the SKI shown by Inspect and openssl.
+func newJWK(cert *x509.Certificate, chain [][]byte) (*JWK, error) {
+	ski := cert.SubjectKeyId
+	if len(ski) == 0 {
+		var err error
+		if ski, err = keyIdentifier(cert.PublicKey); err != nil {
+			return nil, err
+		}
+	}
+
+	thumbprint := sha256.Sum256(cert.Raw)
+	jwk := &JWK{
+		Kid:     hex.EncodeToString(ski),
+		Use:     "sig",
+		X5TS256: base64.RawURLEncoding.EncodeToString(thumbprint[:]),
+	}
+	for _, der := range chain {
+		jwk.X5C = append(jwk.X5C, base64.StdEncoding.EncodeToString(der))
+	}
+
+	switch pub := cert.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		var alg string
+		switch pub.Curve {
+		case elliptic.P256():
+			jwk.Crv, alg = "P-256", "ES256"
+		case elliptic.P384():
+			jwk.Crv, alg = "P-384", "ES384"
+		case elliptic.P521():
+			jwk.Crv, alg = "P-521", "ES512"
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", pub.Curve.Params().Name)
+		}
+		ecdhKey, err := pub.ECDH()
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert public key: %w", err)
+		}
+		// Uncompressed point: 0x04 || X || Y, each coordinate padded to the curve size
+		point := ecdhKey.Bytes()[1:]
+		size := len(point) / 2
+		jwk.Kty, jwk.Alg = "EC", alg
+		jwk.X = base64.RawURLEncoding.EncodeToString(point[:size])
+		jwk.Y = base64.RawURLEncoding.EncodeToString(point[size:])
+	case *rsa.PublicKey:
+		jwk.Kty, jwk.Alg = "RSA", "RS256"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", cert.PublicKey)
+	}
+
+	return jwk, nil
+}
+
+// PublicKey returns the EC or RSA public key of the JWK, e.g. to verify signed tokens
+func (k *JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "EC":
+		var curve elliptic.Curve
+		var ecdhCurve ecdh.Curve
+		switch k.Crv {
+		case "P-256":
+			curve, ecdhCurve = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, ecdhCurve = elliptic.P384(), ecdh.P384()
+		case "P-521":
+			curve, ecdhCurve = elliptic.P521(), ecdh.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		size := (curve.Params().BitSize + 7) / 8
+		if errX != nil || errY != nil || len(x) != size || len(y) != size {
+			return nil, fmt.Errorf("invalid EC coordinates")
+		}
+		// Rejects points that are not on the curve
+		if _, err := ecdhCurve.NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, fmt.Errorf("invalid EC public key: %w", err)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "RSA":
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid RSA modulus or exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
@@ -0,0 +1,156 @@
+package certificate
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+)
+
+func TestCertBundleJWK(t *testing.T) {
+	caBundle, err := GenerateCA(CAConfig{CommonName: "Test CA", ExpiryDays: 1})
+	if err != nil {
+		t.Fatalf("GenerateCA() error = %v", err)
+	}
+	bundle, err := GenerateCert(CertConfig{CommonName: "signer", ExpiryDays: 1, Type: CertTypeClient}, caBundle.CertPEM, caBundle.KeyPEM)
+	if err != nil {
+		t.Fatalf("GenerateCert() error = %v", err)
+	}
+	cert := parseTestCert(t, bundle.CertPEM)
+
+	jwk, err := bundle.PrivateJWK(caBundle.CertPEM)
+	if err != nil {
+		t.Fatalf("PrivateJWK() error = %v", err)
+	}
+
+	if jwk.Kty != "EC" || jwk.Crv != "P-384" || jwk.Alg != "ES384" || jwk.Use != "sig" {
+		t.Errorf("Unexpected key parameters: kty %s, crv %s, alg %s, use %s", jwk.Kty, jwk.Crv, jwk.Alg, jwk.Use)
+	}
+	if jwk.Kid != hex.EncodeToString(cert.SubjectKeyId) {
+		t.Errorf("kid = %s, want the SKI %x", jwk.Kid, cert.SubjectKeyId)
+	}
+
+	pub := cert.PublicKey.(*ecdsa.PublicKey)
+	if decodeJWKInt(t, jwk.X).Cmp(pub.X) != 0 || decodeJWKInt(t, jwk.Y).Cmp(pub.Y) != 0 {
+		t.Error("x/y do not match the certificate's public key")
+	}
+	block, _ := pem.Decode(bundle.KeyPEM)
+	privKey, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse private key: %v", err)
+	}
+	if decodeJWKInt(t, jwk.D).Cmp(privKey.D) != 0 {
+		t.Error("d does not match the private key")
+	}
+
+	if len(jwk.X5C) != 2 {
+		t.Fatalf("Expected leaf and CA in x5c, got %d certificates", len(jwk.X5C))
+	}
+	leafDER, err := base64.StdEncoding.DecodeString(jwk.X5C[0])
+	if err != nil || !bytes.Equal(leafDER, cert.Raw) {
+		t.Errorf("x5c[0] is not the leaf certificate (err = %v)", err)
+	}
+
+	publicJWK, err := bundle.PublicJWK(nil)
+	if err != nil {
+		t.Fatalf("PublicJWK() error = %v", err)
+	}
+	out, err := json.Marshal(publicJWK)
+	if err != nil {
+		t.Fatalf("Failed to marshal JWK: %v", err)
+	}
+	if bytes.Contains(out, []byte(`"d"`)) {
+		t.Errorf("Public JWK contains the private key: %s", out)
+	}
+	if len(publicJWK.X5C) != 1 {
+		t.Errorf("Expected only the leaf in x5c without a CA, got %d certificates", len(publicJWK.X5C))
+	}
+	// Only a CA certificate may end up in x5c, never e.g. the CA private key
+	if _, err := bundle.PublicJWK(caBundle.KeyPEM); err == nil {
+		t.Error("Expected a CA private key to be rejected as the x5c chain")
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	caBundle, err := GenerateCA(CAConfig{CommonName: "Test CA", ExpiryDays: 1})
+	if err != nil {
+		t.Fatalf("GenerateCA() error = %v", err)
+	}
+	bundle, err := GenerateCert(CertConfig{CommonName: "signer", ExpiryDays: 1, Type: CertTypeClient}, caBundle.CertPEM, caBundle.KeyPEM)
+	if err != nil {
+		t.Fatalf("GenerateCert() error = %v", err)
+	}
+
+	jwks, err := ParseJWKS(bundle.FullChainPEM(caBundle.CertPEM))
+	if err != nil {
+		t.Fatalf("ParseJWKS() error = %v", err)
+	}
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("Expected 2 keys, got %d", len(jwks.Keys))
+	}
+	if jwks.Keys[0].Kid == jwks.Keys[1].Kid {
+		t.Error("Expected distinct key IDs")
+	}
+
+	if _, err := ParseJWKS(bundle.KeyPEM); err == nil {
+		t.Error("Expected an error for PEM input without certificates")
+	}
+}
+
+func decodeJWKInt(t *testing.T, s string) *big.Int {
+	t.Helper()
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatalf("Invalid base64url value %q: %v", s, err)
+	}
+	return new(big.Int).SetBytes(b)
+}
+
+func TestJWKPublicKey(t *testing.T) {
+	caBundle, err := GenerateCA(CAConfig{CommonName: "Test CA", ExpiryDays: 1})
+	if err != nil {
+		t.Fatalf("GenerateCA() error = %v", err)
+	}
+	jwk, err := caBundle.PublicJWK(nil)
+	if err != nil {
+		t.Fatalf("PublicJWK() error = %v", err)
+	}
+
+	pub, err := jwk.PublicKey()
+	if err != nil {
+		t.Fatalf("PublicKey() error = %v", err)
+	}
+	if !pub.(*ecdsa.PublicKey).Equal(parseTestCert(t, caBundle.CertPEM).PublicKey) {
+		t.Error("PublicKey() does not match the certificate's public key")
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	rsaJWK := &JWK{
+		Kty: "RSA",
+		N:   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+	}
+	if pub, err := rsaJWK.PublicKey(); err != nil || !rsaKey.PublicKey.Equal(pub) {
+		t.Errorf("PublicKey() = %v, %v, want the RSA public key", pub, err)
+	}
+
+	offCurve := *jwk
+	offCurve.Y = offCurve.X
+	if _, err := offCurve.PublicKey(); err == nil {
+		t.Error("Expected an error for a point that is not on the curve")
+	}
+	if _, err := (&JWK{Kty: "oct"}).PublicKey(); err == nil {
+		t.Error("Expected an error for a symmetric key")
+	}
+}
@@ -0,0 +1,263 @@
+// Package certificate generates X.509 CAs and certificates for development and testing.
+// NewCA and NewCert with functional options cover the common cases; GenerateCA and
+// GenerateCert accept the full configuration.
+package certificate
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+)
+
+// DefaultCACommonName is the Common Name of CAs created by NewCA without WithCommonName
+const DefaultCACommonName = "Certgen Test CA"
+
+// Issued is a generated certificate in PEM form together with its parsed certificate and
+// private key, ready for use with crypto/tls and crypto/x509
+type Issued struct {
+	*CertBundle
+	Certificate *x509.Certificate
+	// Signer is the private key, an *ecdsa.PrivateKey on P-384
+	Signer crypto.Signer
+}
+
+// CAOption configures a CA created by NewCA
+type CAOption interface {
+	applyCA(*CAConfig)
+}
+
+// CertOption configures a certificate created by NewCert
+type CertOption interface {
+	applyCert(*CertConfig)
+}
+
+// Option configures a setting shared by CA and leaf certificates, so it can be passed to
+// both NewCA and NewCert
+type Option struct {
+	ca   func(*CAConfig)
+	cert func(*CertConfig)
+}
+
+func (o Option) applyCA(c *CAConfig)     { o.ca(c) }
+func (o Option) applyCert(c *CertConfig) { o.cert(c) }
+
+type caOptionFunc func(*CAConfig)
+
+func (f caOptionFunc) applyCA(c *CAConfig) { f(c) }
+
+type certOptionFunc func(*CertConfig)
+
+func (f certOptionFunc) applyCert(c *CertConfig) { f(c) }
+
+// NewCA creates a CA. Without options it is named DefaultCACommonName and valid for 365 days.
+func NewCA(opts ...CAOption) (*Issued, error) {
+	config := CAConfig{CommonName: DefaultCACommonName, ExpiryDays: 365}
+	for _, opt := range opts {
+		opt.applyCA(&config)
+	}
+
+	bundle, err := GenerateCA(config)
+	if err != nil {
+		return nil, err
+	}
+	return ParseIssued(bundle)
+}
+
+// NewCert creates a certificate signed by ca. Without options it is a server certificate valid
+// for 365 days; the Common Name defaults to the first DNS name.
+func NewCert(ca *Issued, opts ...CertOption) (*Issued, error) {
+	config := CertConfig{Type: CertTypeServer, ExpiryDays: 365}
+	for _, opt := range opts {
+		opt.applyCert(&config)
+	}
+	if config.CommonName == "" && config.SPIFFEID == "" && len(config.DNSNames) > 0 {
+		config.CommonName = config.DNSNames[0]
+	}
+
+	bundle, err := GenerateCert(config, ca.CertPEM, ca.KeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return ParseIssued(bundle)
+}
+
+// ParseIssued parses the certificate and private key of a bundle
+func ParseIssued(bundle *CertBundle) (*Issued, error) {
+	certBlock, _ := pem.Decode(bundle.CertPEM)
+	if certBlock == nil {
+		return nil, fmt.Errorf("failed to decode certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	keyBlock, _ := pem.Decode(bundle.KeyPEM)
+	if keyBlock == nil {
+		return nil, fmt.Errorf("failed to decode private key PEM")
+	}
+	key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	return &Issued{CertBundle: bundle, Certificate: cert, Signer: key}, nil
+}
+
+// TLSCertificate returns the certificate and key for tls.Config.Certificates
+func (i *Issued) TLSCertificate() tls.Certificate {
+	return tls.Certificate{
+		Certificate: [][]byte{i.Certificate.Raw},
+		PrivateKey:  i.Signer,
+		Leaf:        i.Certificate,
+	}
+}
+
+// WithCommonName sets the subject Common Name
+func WithCommonName(cn string) Option {
+	return Option{
+		ca:   func(c *CAConfig) { c.CommonName = cn },
+		cert: func(c *CertConfig) { c.CommonName = cn },
+	}
+}
+
+// WithOrganization sets the subject Organization
+func WithOrganization(org string) Option {
+	return Option{
+		ca:   func(c *CAConfig) { c.Organization = org },
+		cert: func(c *CertConfig) { c.Organization = org },
+	}
+}
+
+// WithOrganizationalUnit sets the subject Organizational Unit
+func WithOrganizationalUnit(ou string) Option {
+	return Option{
+		ca:   func(c *CAConfig) { c.OrganizationalUnit = ou },
+		cert: func(c *CertConfig) { c.OrganizationalUnit = ou },
+	}
+}
+
+// WithCountry sets the subject Country (ISO 3166-1 alpha-2)
+func WithCountry(country string) Option {
+	return Option{
+		ca:   func(c *CAConfig) { c.Country = country },
+		cert: func(c *CertConfig) { c.Country = country },
+	}
+}
+
+// WithLocality sets the subject Locality
+func WithLocality(locality string) Option {
+	return Option{
+		ca:   func(c *CAConfig) { c.Locality = locality },
+		cert: func(c *CertConfig) { c.Locality = locality },
+	}
+}
+
+// WithDN sets the subject from an RFC 4514 distinguished name
+func WithDN(dn string) Option {
+	return Option{
+		ca:   func(c *CAConfig) { c.DN = dn },
+		cert: func(c *CertConfig) { c.DN = dn },
+	}
+}
+
+// WithValidity sets how long the certificate is valid, starting now
+func WithValidity(d time.Duration) Option {
+	return Option{
+		ca:   func(c *CAConfig) { c.Validity = d.String() },
+		cert: func(c *CertConfig) { c.Validity = d.String() },
+	}
+}
+
+// WithWindow pins the validity period to notBefore and notAfter
+func WithWindow(notBefore, notAfter time.Time) Option {
+	return Option{
+		ca:   func(c *CAConfig) { c.NotBefore, c.NotAfter = notBefore, notAfter },
+		cert: func(c *CertConfig) { c.NotBefore, c.NotAfter = notBefore, notAfter },
+	}
+}
+
+// WithExtensions adds custom extensions
+func WithExtensions(exts ...Extension) Option {
+	return Option{
+		ca:   func(c *CAConfig) { c.Extensions = append(c.Extensions, exts...) },
+		cert: func(c *CertConfig) { c.Extensions = append(c.Extensions, exts...) },
+	}
+}
+
+// WithCertificatePolicies adds certificate policies
+func WithCertificatePolicies(policies ...CertificatePolicy) Option {
+	return Option{
+		ca:   func(c *CAConfig) { c.CertificatePolicies = append(c.CertificatePolicies, policies...) },
+		cert: func(c *CertConfig) { c.CertificatePolicies = append(c.CertificatePolicies, policies...) },
+	}
+}
+
+// WithMustStaple adds the TLS feature extension requiring OCSP stapling
+func WithMustStaple() Option {
+	return Option{
+		ca:   func(c *CAConfig) { c.MustStaple = true },
+		cert: func(c *CertConfig) { c.MustStaple = true },
+	}
+}
+
+// WithNameConstraints restricts the names a CA may issue certificates for
+func WithNameConstraints(constraints NameConstraints) CAOption {
+	return caOptionFunc(func(c *CAConfig) { c.NameConstraints = constraints })
+}
+
+// WithCAConfig replaces the whole CA configuration, for settings without a dedicated option.
+// Options passed after it still apply.
+func WithCAConfig(config CAConfig) CAOption {
+	return caOptionFunc(func(c *CAConfig) { *c = config })
+}
+
+// WithType sets the certificate type (server, client or peer)
+func WithType(certType CertType) CertOption {
+	return certOptionFunc(func(c *CertConfig) { c.Type = certType })
+}
+
+// WithDNSNames adds DNS name SANs
+func WithDNSNames(names ...string) CertOption {
+	return certOptionFunc(func(c *CertConfig) { c.DNSNames = append(c.DNSNames, names...) })
+}
+
+// WithIPAddresses adds IP address SANs
+func WithIPAddresses(ips ...string) CertOption {
+	return certOptionFunc(func(c *CertConfig) { c.IPAddresses = append(c.IPAddresses, ips...) })
+}
+
+// WithURIs adds URI SANs
+func WithURIs(uris ...string) CertOption {
+	return certOptionFunc(func(c *CertConfig) { c.URIs = append(c.URIs, uris...) })
+}
+
+// WithEmailAddresses adds email address SANs
+func WithEmailAddresses(emails ...string) CertOption {
+	return certOptionFunc(func(c *CertConfig) { c.EmailAddresses = append(c.EmailAddresses, emails...) })
+}
+
+// WithSPIFFEID makes the certificate an X.509-SVID for the SPIFFE ID
+func WithSPIFFEID(id string) CertOption {
+	return certOptionFunc(func(c *CertConfig) { c.SPIFFEID = id })
+}
+
+// WithKeyUsage overrides the key usage of the certificate type, e.g. "digitalSignature"
+func WithKeyUsage(usages ...string) CertOption {
+	return certOptionFunc(func(c *CertConfig) { c.KeyUsage = append(c.KeyUsage, usages...) })
+}
+
+// WithExtKeyUsage overrides the extended key usage of the certificate type with names such as
+// "codeSigning" or dotted OIDs
+func WithExtKeyUsage(usages ...string) CertOption {
+	return certOptionFunc(func(c *CertConfig) { c.ExtKeyUsage = append(c.ExtKeyUsage, usages...) })
+}
+
+// WithCertConfig replaces the whole certificate configuration, for settings without a
+// dedicated option. Options passed after it still apply.
+func WithCertConfig(config CertConfig) CertOption {
+	return certOptionFunc(func(c *CertConfig) { *c = config })
+}
@@ -0,0 +1,67 @@
+package certificate
+
+import (
+	"crypto"
+	"crypto/x509"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestNewCAAndCert(t *testing.T) {
+	ca, err := NewCA(WithCommonName("Options CA"), WithOrganization("Example"), WithValidity(48*time.Hour))
+	if err != nil {
+		t.Fatalf("NewCA() error = %v", err)
+	}
+	if !ca.Certificate.IsCA || ca.Certificate.Subject.CommonName != "Options CA" {
+		t.Errorf("Unexpected CA certificate: isCA %v, subject %s", ca.Certificate.IsCA, ca.Certificate.Subject)
+	}
+	if got := ca.Certificate.NotAfter.Sub(time.Now()).Round(time.Hour); got != 48*time.Hour {
+		t.Errorf("CA expires in %v, want 48h", got)
+	}
+
+	leaf, err := NewCert(ca, WithDNSNames("api.example", "localhost"), WithIPAddresses("127.0.0.1"), WithOrganization("Example"))
+	if err != nil {
+		t.Fatalf("NewCert() error = %v", err)
+	}
+	if leaf.Certificate.Subject.CommonName != "api.example" {
+		t.Errorf("Expected the Common Name to default to the first DNS name, got %q", leaf.Certificate.Subject.CommonName)
+	}
+	if !slices.Equal(leaf.Certificate.ExtKeyUsage, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}) {
+		t.Errorf("Expected a server certificate, got EKUs %v", leaf.Certificate.ExtKeyUsage)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Certificate)
+	if _, err := leaf.Certificate.Verify(x509.VerifyOptions{DNSName: "localhost", Roots: pool}); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+
+	type publicKeyEqualer interface {
+		Equal(crypto.PublicKey) bool
+	}
+	if !leaf.Signer.Public().(publicKeyEqualer).Equal(leaf.Certificate.PublicKey) {
+		t.Error("Signer does not match the certificate's public key")
+	}
+
+	client, err := NewCert(ca, WithCommonName("alice"), WithType(CertTypeClient))
+	if err != nil {
+		t.Fatalf("NewCert() error = %v", err)
+	}
+	if !slices.Equal(client.Certificate.ExtKeyUsage, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}) {
+		t.Errorf("Expected a client certificate, got EKUs %v", client.Certificate.ExtKeyUsage)
+	}
+}
+
+func TestNewCertValidation(t *testing.T) {
+	ca, err := NewCA()
+	if err != nil {
+		t.Fatalf("NewCA() error = %v", err)
+	}
+	if ca.Certificate.Subject.CommonName != DefaultCACommonName {
+		t.Errorf("CA Common Name = %q, want %q", ca.Certificate.Subject.CommonName, DefaultCACommonName)
+	}
+
+	_, err = NewCert(ca, WithIPAddresses("not-an-ip"))
+	assertFieldErrors(t, err, []string{"commonName", "ipAddresses[0]"})
+}
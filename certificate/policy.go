@@ -0,0 +1,311 @@
+package certificate
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrPolicyViolation is returned when a certificate request is rejected by the issuance policy of a CA
+var ErrPolicyViolation = errors.New("rejected by issuance policy")
+
+// Policy restricts which certificates may be issued from a CA
+type Policy struct {
+	// AllowedSANPatterns lists patterns every DNS name, IP address, email address and URI
+	// must match. '*' matches any sequence of characters, e.g. "*.internal.example".
+	AllowedSANPatterns []string `json:"allowedSanPatterns,omitempty"`
+	// MaxValidity is the longest allowed validity, e.g. "90d" or "24h"
+	MaxValidity string `json:"maxValidity,omitempty"`
+	// AllowedProfiles lists the permitted certificate types (server, client, peer)
+	AllowedProfiles []CertType `json:"allowedProfiles,omitempty"`
+	// AllowedExtKeyUsages lists the extended key usages (names or dotted OIDs) requests may ask
+	// for. Without it, requests under AllowedProfiles may only ask for the EKUs of those profiles.
+	AllowedExtKeyUsages []string `json:"allowedExtKeyUsages,omitempty"`
+	// SubjectOverrides replaces subject attributes of every request with fixed values
+	SubjectOverrides SubjectOverrides `json:"subjectOverrides"`
+}
+
+// SubjectOverrides holds subject attributes enforced by a policy; empty values are left untouched
+type SubjectOverrides struct {
+	Organization       string `json:"organization,omitempty"`
+	OrganizationalUnit string `json:"organizationalUnit,omitempty"`
+	Country            string `json:"country,omitempty"`
+	Province           string `json:"province,omitempty"`
+	Locality           string `json:"locality,omitempty"`
+}
+
+func (o SubjectOverrides) isEmpty() bool {
+	return o == SubjectOverrides{}
+}
+
+// Apply enforces the policy on a certificate config: subject overrides are written into the
+// config, and every other rule is checked. Violations are returned as a *ValidationError
+// whose field errors wrap ErrPolicyViolation.
+func (p *Policy) Apply(config *CertConfig) error {
+	if p == nil {
+		return nil
+	}
+
+	var v validator
+	violation := func(field, format string, args ...any) {
+		v.add(field, fmt.Errorf("%w: %s", ErrPolicyViolation, fmt.Sprintf(format, args...)))
+	}
+
+	if !p.SubjectOverrides.isEmpty() {
+		if config.DN != "" {
+			violation("dn", "distinguished name strings are not allowed when the policy overrides subject attributes")
+		}
+		if len(config.ExtraAttributes) > 0 {
+			violation("extraAttributes", "extra subject attributes are not allowed when the policy overrides subject attributes")
+		}
+		overrides := p.SubjectOverrides
+		if overrides.Organization != "" {
+			config.Organization = overrides.Organization
+		}
+		if overrides.OrganizationalUnit != "" {
+			config.OrganizationalUnit = overrides.OrganizationalUnit
+		}
+		if overrides.Country != "" {
+			config.Country = overrides.Country
+		}
+		if overrides.Province != "" {
+			config.Province = overrides.Province
+		}
+		if overrides.Locality != "" {
+			config.Locality = overrides.Locality
+		}
+	}
+
+	if len(p.AllowedProfiles) > 0 && !slices.Contains(p.AllowedProfiles, config.Type) {
+		violation("type", "certificate type %q is not allowed", config.Type)
+	}
+
+	for i, name := range config.ExtKeyUsage {
+		if !p.extKeyUsageAllowed(name) {
+			violation(fmt.Sprintf("extKeyUsage[%d]", i), "extended key usage %q is not allowed", name)
+		}
+	}
+
+	if p.MaxValidity != "" {
+		maxValidity, err := ParseValidity(p.MaxValidity)
+		if err != nil {
+			return fmt.Errorf("invalid policy: maxValidity: %w", err)
+		}
+
+		now := time.Now()
+		if _, notAfter, err := config.validity().window(now); err == nil {
+			start := config.NotBefore
+			if start.IsZero() {
+				start = now
+			}
+			if notAfter.Sub(start) > maxValidity {
+				violation("validity", "validity exceeds the maximum of %s", p.MaxValidity)
+			}
+		}
+	}
+
+	if len(p.AllowedSANPatterns) > 0 {
+		type sanList struct {
+			field  string
+			values []string
+		}
+		var sans []sanList
+		if config.Type.HasSANs() {
+			sans = append(sans, sanList{"dnsNames", config.DNSNames}, sanList{"ipAddresses", config.IPAddresses})
+		}
+		sans = append(sans, sanList{"emailAddresses", config.EmailAddresses}, sanList{"uris", config.URIs})
+
+		for _, san := range sans {
+			for i, value := range san.values {
+				if !p.sanAllowed(value) {
+					violation(fmt.Sprintf("%s[%d]", san.field, i), "%q does not match any allowed SAN pattern", value)
+				}
+			}
+		}
+		if config.SPIFFEID != "" && !p.sanAllowed(config.SPIFFEID) {
+			violation("spiffeId", "%q does not match any allowed SAN pattern", config.SPIFFEID)
+		}
+
+		// Legacy clients still match host names against the common name
+		if name, err := config.subject().name(); err == nil && looksLikeHost(name.CommonName) && !p.sanAllowed(name.CommonName) {
+			field := "commonName"
+			if config.CommonName != name.CommonName {
+				field = "dn"
+			}
+			violation(field, "%q does not match any allowed SAN pattern", name.CommonName)
+		}
+	}
+
+	return v.err()
+}
+
+// extKeyUsageAllowed reports whether the policy permits requesting the extended key usage
+func (p *Policy) extKeyUsageAllowed(name string) bool {
+	allowed := p.AllowedExtKeyUsages
+	if len(allowed) == 0 {
+		if len(p.AllowedProfiles) == 0 {
+			return true
+		}
+		for _, profile := range p.AllowedProfiles {
+			allowed = append(allowed, extKeyUsageList(profile.ExtKeyUsages(), nil)...)
+		}
+	}
+	return slices.ContainsFunc(allowed, func(a string) bool { return sameExtKeyUsage(a, name) })
+}
+
+func (p *Policy) sanAllowed(value string) bool {
+	value = strings.ToLower(strings.TrimSpace(value))
+	for _, pattern := range p.AllowedSANPatterns {
+		if matchPattern(strings.ToLower(pattern), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// looksLikeHost reports whether a common name could be taken for a host name or an IP address
+// by clients that fall back to the common name, i.e. it is an IP address or a dotted name made
+// of host name characters
+func looksLikeHost(cn string) bool {
+	if net.ParseIP(cn) != nil {
+		return true
+	}
+	if !strings.Contains(cn, ".") {
+		return false
+	}
+	for _, c := range cn {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '.' || c == '*' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPattern reports whether s matches the pattern, where '*' matches any sequence of characters
+func matchPattern(pattern, s string) bool {
+	star := strings.IndexByte(pattern, '*')
+	if star < 0 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, pattern[:star]) {
+		return false
+	}
+	rest := pattern[star+1:]
+	for i := star; i <= len(s); i++ {
+		if matchPattern(rest, s[i:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateCertWithPolicy applies the policy to the config and, if it is satisfied,
+// issues the certificate like GenerateCert
+func GenerateCertWithPolicy(config CertConfig, policy *Policy, caCertPEM, caKeyPEM []byte) (*CertBundle, error) {
+	if err := policy.Apply(&config); err != nil {
+		return nil, err
+	}
+	return GenerateCert(config, caCertPEM, caKeyPEM)
+}
+
+// PolicySet maps the public key fingerprints of CAs (see PublicKeyFingerprint) to their
+// issuance policy. Keying by the public key rather than the certificate keeps a CA under its
+// policy when its key holder re-signs the certificate. The key "*" holds a default policy for
+// CAs without a specific entry. Fingerprints may be written in upper case and with colons;
+// LoadPolicySet normalizes them.
+type PolicySet map[string]*Policy
+
+// LoadPolicySet reads a JSON encoded PolicySet and validates its policies. Unknown fields
+// are rejected, so a misspelled rule cannot silently leave a CA unrestricted.
+func LoadPolicySet(r io.Reader) (PolicySet, error) {
+	var raw PolicySet
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to decode policies: %w", err)
+	}
+
+	policies := make(PolicySet, len(raw))
+	for key, policy := range raw {
+		key = strings.ToLower(strings.ReplaceAll(key, ":", ""))
+		if key != "*" {
+			if b, err := hex.DecodeString(key); err != nil || len(b) != sha256.Size {
+				return nil, fmt.Errorf("policy %s: keys must be SHA-256 public key fingerprints or \"*\"", key)
+			}
+		}
+		policies[key] = policy
+		if policy == nil {
+			continue
+		}
+		if policy.MaxValidity != "" {
+			if _, err := ParseValidity(policy.MaxValidity); err != nil {
+				return nil, fmt.Errorf("policy %s: maxValidity: %w", key, err)
+			}
+		}
+		for _, profile := range policy.AllowedProfiles {
+			if _, err := ParseCertType(string(profile)); err != nil || profile == "" {
+				return nil, fmt.Errorf("policy %s: allowedProfiles: unknown certificate type %q", key, profile)
+			}
+		}
+		for _, name := range policy.AllowedExtKeyUsages {
+			if _, _, err := ParseExtKeyUsage(name); err != nil {
+				return nil, fmt.Errorf("policy %s: allowedExtKeyUsages: %w", key, err)
+			}
+		}
+	}
+
+	return policies, nil
+}
+
+// Lookup returns the policy for the given PEM encoded CA certificate, falling back to the
+// default policy. It returns nil when no policy applies.
+func (ps PolicySet) Lookup(caCertPEM []byte) *Policy {
+	if len(ps) == 0 {
+		return nil
+	}
+	if fingerprint, err := PublicKeyFingerprint(caCertPEM); err == nil {
+		if policy, ok := ps[fingerprint]; ok {
+			return policy
+		}
+	}
+	return ps["*"]
+}
+
+// Fingerprint returns the lowercase hex SHA-256 fingerprint of a PEM encoded certificate
+func Fingerprint(certPEM []byte) (string, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return "", fmt.Errorf("failed to decode certificate PEM")
+	}
+	sum := sha256.Sum256(block.Bytes)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// PublicKeyFingerprint returns the lowercase hex SHA-256 of the DER encoded public key
+// (SubjectPublicKeyInfo) of a PEM encoded certificate. Unlike Fingerprint it stays the same
+// when a certificate is re-signed for the same key.
+func PublicKeyFingerprint(certPEM []byte) (string, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return "", fmt.Errorf("failed to decode certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	return spkiFingerprint(cert), nil
+}
+
+func spkiFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return hex.EncodeToString(sum[:])
+}
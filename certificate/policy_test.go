@@ -0,0 +1,197 @@
+package certificate
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestPolicyApply(t *testing.T) {
+	policy := &Policy{
+		AllowedSANPatterns: []string{"*.internal.example", "10.*"},
+		MaxValidity:        "30d",
+		AllowedProfiles:    []CertType{CertTypeServer, CertTypePeer},
+		SubjectOverrides:   SubjectOverrides{Organization: "Platform Team", Country: "DE"},
+	}
+
+	tests := []struct {
+		name       string
+		config     CertConfig
+		wantFields []string
+	}{
+		{
+			name:   "allowed request",
+			config: CertConfig{CommonName: "app", ExpiryDays: 30, Type: CertTypeServer, DNSNames: []string{"app.internal.example"}, IPAddresses: []string{"10.1.2.3"}},
+		},
+		{
+			name:       "disallowed profile",
+			config:     CertConfig{CommonName: "app", ExpiryDays: 30, Type: CertTypeClient},
+			wantFields: []string{"type"},
+		},
+		{
+			name:       "validity too long",
+			config:     CertConfig{CommonName: "app", Validity: "31d", Type: CertTypeServer},
+			wantFields: []string{"validity"},
+		},
+		{
+			name:       "SAN outside patterns",
+			config:     CertConfig{CommonName: "app", ExpiryDays: 1, Type: CertTypePeer, DNSNames: []string{"www.example.com"}, IPAddresses: []string{"192.168.0.1"}},
+			wantFields: []string{"dnsNames[0]", "ipAddresses[0]"},
+		},
+		{
+			name:       "DN with subject overrides",
+			config:     CertConfig{DN: "CN=app,O=Evil Corp", ExpiryDays: 1, Type: CertTypeServer},
+			wantFields: []string{"dn"},
+		},
+		{
+			name:       "host name in common name",
+			config:     CertConfig{CommonName: "www.example.com", ExpiryDays: 1, Type: CertTypeServer, DNSNames: []string{"app.internal.example"}},
+			wantFields: []string{"commonName"},
+		},
+		{
+			name:       "IP address in common name",
+			config:     CertConfig{CommonName: "192.168.0.1", ExpiryDays: 1, Type: CertTypePeer},
+			wantFields: []string{"commonName"},
+		},
+		{
+			name:   "allowed host name in common name",
+			config: CertConfig{CommonName: "app.internal.example", ExpiryDays: 1, Type: CertTypeServer},
+		},
+		{
+			name:       "EKUs outside the allowed server and peer profiles",
+			config:     CertConfig{CommonName: "app", ExpiryDays: 1, Type: CertTypeServer, ExtKeyUsage: []string{"serverAuth", "clientAuth", "codeSigning", "any", "1.3.6.1.4.1.99999.3.1"}},
+			wantFields: []string{"extKeyUsage[2]", "extKeyUsage[3]", "extKeyUsage[4]"},
+		},
+		{
+			name:   "EKUs of allowed profiles",
+			config: CertConfig{CommonName: "app", ExpiryDays: 1, Type: CertTypeServer, ExtKeyUsage: []string{"ServerAuth", "clientAuth"}},
+		},
+		{
+			name:       "extra attributes with subject overrides",
+			config:     CertConfig{CommonName: "app", ExpiryDays: 1, Type: CertTypeServer, ExtraAttributes: []Attribute{{OID: "1.3.6.1.4.1.99999.2", Value: "x"}}},
+			wantFields: []string{"extraAttributes"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Apply(&tt.config)
+			assertFieldErrors(t, err, tt.wantFields)
+			if len(tt.wantFields) > 0 && !errors.Is(err, ErrPolicyViolation) {
+				t.Errorf("Expected ErrPolicyViolation, got %v", err)
+			}
+			if tt.config.Organization != "Platform Team" || tt.config.Country != "DE" {
+				t.Errorf("Subject overrides not applied: %+v", tt.config)
+			}
+		})
+	}
+}
+
+func TestPolicyAllowedExtKeyUsages(t *testing.T) {
+	policy := &Policy{AllowedProfiles: []CertType{CertTypeClient}, AllowedExtKeyUsages: []string{"clientAuth", "1.3.6.1.4.1.99999.3.1"}}
+
+	config := CertConfig{CommonName: "device", ExpiryDays: 1, Type: CertTypeClient, ExtKeyUsage: []string{"clientAuth", "1.3.6.1.4.1.99999.3.1"}}
+	assertFieldErrors(t, policy.Apply(&config), nil)
+
+	config.ExtKeyUsage = []string{"emailProtection"}
+	assertFieldErrors(t, policy.Apply(&config), []string{"extKeyUsage[0]"})
+
+	if _, err := LoadPolicySet(strings.NewReader(`{"*": {"allowedExtKeyUsages": ["webAuth"]}}`)); err == nil {
+		t.Error("Expected an unknown extended key usage to be rejected")
+	}
+}
+
+func TestGenerateCertWithPolicy(t *testing.T) {
+	ca, err := GenerateCA(CAConfig{CommonName: "Test CA", ExpiryDays: 365})
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+
+	policy := &Policy{SubjectOverrides: SubjectOverrides{Organization: "Forced Org"}}
+	bundle, err := GenerateCertWithPolicy(CertConfig{CommonName: "app", Organization: "Requested Org", ExpiryDays: 1}, policy, ca.CertPEM, ca.KeyPEM)
+	if err != nil {
+		t.Fatalf("GenerateCertWithPolicy() error = %v", err)
+	}
+
+	block, _ := pem.Decode(bundle.CertPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	if cert.Subject.Organization[0] != "Forced Org" {
+		t.Errorf("Expected overridden organization, got %v", cert.Subject.Organization)
+	}
+}
+
+func TestPolicySetLookup(t *testing.T) {
+	ca, err := GenerateCA(CAConfig{CommonName: "Test CA", ExpiryDays: 365})
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+	other, err := GenerateCA(CAConfig{CommonName: "Other CA", ExpiryDays: 365})
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+
+	fingerprint, err := PublicKeyFingerprint(ca.CertPEM)
+	if err != nil {
+		t.Fatalf("PublicKeyFingerprint() error = %v", err)
+	}
+
+	// Write the fingerprint in the colon separated upper case form shown by openssl
+	var pairs []string
+	for i := 0; i < len(fingerprint); i += 2 {
+		pairs = append(pairs, strings.ToUpper(fingerprint[i:i+2]))
+	}
+
+	policies, err := LoadPolicySet(strings.NewReader(`{
+		"` + strings.Join(pairs, ":") + `": {"maxValidity": "7d"},
+		"*": {"maxValidity": "1d"}
+	}`))
+	if err != nil {
+		t.Fatalf("LoadPolicySet() error = %v", err)
+	}
+
+	if got := policies.Lookup(ca.CertPEM); got == nil || got.MaxValidity != "7d" {
+		t.Errorf("Expected CA specific policy, got %+v", got)
+	}
+	// Re-signing the CA certificate for the same key must not escape its policy
+	caCert, caKey, err := parseCA(ca.CertPEM, ca.KeyPEM)
+	if err != nil {
+		t.Fatalf("parseCA() error = %v", err)
+	}
+	caCert.SerialNumber = big.NewInt(4242)
+	resignedDER, err := x509.CreateCertificate(rand.Reader, caCert, caCert, caCert.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to re-sign CA: %v", err)
+	}
+	resigned := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: resignedDER})
+	if certFingerprint, _ := Fingerprint(resigned); certFingerprint == "" || bytes.Equal(resigned, ca.CertPEM) {
+		t.Fatal("Re-signed CA certificate is not a new certificate")
+	}
+	if got := policies.Lookup(resigned); got == nil || got.MaxValidity != "7d" {
+		t.Errorf("Expected the CA specific policy for the re-signed CA, got %+v", got)
+	}
+
+	if got := policies.Lookup(other.CertPEM); got == nil || got.MaxValidity != "1d" {
+		t.Errorf("Expected default policy, got %+v", got)
+	}
+	if got := PolicySet(nil).Lookup(ca.CertPEM); got != nil {
+		t.Errorf("Expected no policy, got %+v", got)
+	}
+
+	if _, err := LoadPolicySet(strings.NewReader(`{"*": {"maxValidity": "forever"}}`)); err == nil {
+		t.Error("LoadPolicySet() should reject invalid maxValidity")
+	}
+	if _, err := LoadPolicySet(strings.NewReader(`{"*": {"maxValidty": "1d"}}`)); err == nil {
+		t.Error("LoadPolicySet() should reject unknown fields")
+	}
+	if _, err := LoadPolicySet(strings.NewReader(`{"ca.example": {"maxValidity": "1d"}}`)); err == nil {
+		t.Error("LoadPolicySet() should reject keys that are no fingerprints")
+	}
+}
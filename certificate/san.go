@@ -0,0 +1,125 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+// ErrInvalidSAN is returned when a subject alternative name cannot be parsed
+var ErrInvalidSAN = errors.New("invalid subject alternative name")
+
+// applySANs validates the subject alternative names of the config and adds them to the template
+func applySANs(template *x509.Certificate, config CertConfig) error {
+	// DNS names and IP addresses only make sense for certificates used by servers
+	if config.Type.HasSANs() {
+		if len(config.DNSNames) > 0 {
+			template.DNSNames = config.DNSNames
+		}
+		for _, s := range config.IPAddresses {
+			ip, err := parseIP(s)
+			if err != nil {
+				return err
+			}
+			template.IPAddresses = append(template.IPAddresses, ip)
+		}
+	}
+
+	for _, s := range config.EmailAddresses {
+		email, err := parseEmail(s)
+		if err != nil {
+			return err
+		}
+		template.EmailAddresses = append(template.EmailAddresses, email)
+	}
+
+	if config.SPIFFEID != "" {
+		// An X.509-SVID carries exactly one URI SAN, the SPIFFE ID itself
+		if len(config.URIs) > 0 {
+			return fmt.Errorf("%w: SPIFFE certificates must not contain additional URIs", ErrInvalidSAN)
+		}
+		id, err := ParseSPIFFEID(config.SPIFFEID)
+		if err != nil {
+			return err
+		}
+		template.URIs = []*url.URL{id}
+		return nil
+	}
+
+	for _, s := range config.URIs {
+		u, err := parseURI(s)
+		if err != nil {
+			return err
+		}
+		template.URIs = append(template.URIs, u)
+	}
+
+	return nil
+}
+
+// parseIP validates an IPv4 or IPv6 address
+func parseIP(s string) (net.IP, error) {
+	ip := net.ParseIP(strings.TrimSpace(s))
+	if ip == nil {
+		return nil, fmt.Errorf("%w: IP address %q", ErrInvalidSAN, s)
+	}
+	return ip, nil
+}
+
+// parseURI validates an absolute URI
+func parseURI(s string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil || u.Scheme == "" {
+		return nil, fmt.Errorf("%w: URI %q", ErrInvalidSAN, s)
+	}
+	return u, nil
+}
+
+// parseEmail validates a bare email address (without display name)
+func parseEmail(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Address != s {
+		return "", fmt.Errorf("%w: email address %q", ErrInvalidSAN, s)
+	}
+	return addr.Address, nil
+}
+
+// ParseSPIFFEID parses and validates a SPIFFE ID of the form spiffe://trust-domain/path
+func ParseSPIFFEID(s string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("%w: SPIFFE ID %q: %v", ErrInvalidSAN, s, err)
+	}
+
+	switch {
+	case u.Scheme != "spiffe":
+		return nil, fmt.Errorf("%w: SPIFFE ID %q must use the spiffe scheme", ErrInvalidSAN, s)
+	case u.Host == "":
+		return nil, fmt.Errorf("%w: SPIFFE ID %q is missing a trust domain", ErrInvalidSAN, s)
+	case u.User != nil || u.Port() != "":
+		return nil, fmt.Errorf("%w: SPIFFE ID %q must not contain user info or a port", ErrInvalidSAN, s)
+	case u.RawQuery != "" || u.Fragment != "":
+		return nil, fmt.Errorf("%w: SPIFFE ID %q must not contain a query or fragment", ErrInvalidSAN, s)
+	case u.Path == "" || u.Path == "/":
+		return nil, fmt.Errorf("%w: SPIFFE ID %q must have a workload path", ErrInvalidSAN, s)
+	}
+
+	for _, c := range u.Host {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '.' || c == '-' || c == '_') {
+			return nil, fmt.Errorf("%w: SPIFFE trust domain %q contains invalid characters", ErrInvalidSAN, u.Host)
+		}
+	}
+
+	for _, segment := range strings.Split(strings.TrimPrefix(u.Path, "/"), "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return nil, fmt.Errorf("%w: SPIFFE ID %q contains an invalid path segment", ErrInvalidSAN, s)
+		}
+	}
+
+	return u, nil
+}
@@ -0,0 +1,154 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+)
+
+func TestGenerateCertSANs(t *testing.T) {
+	ca, err := GenerateCA(CAConfig{
+		Organization: "Test CA Org",
+		CommonName:   "Test CA",
+		Country:      "US",
+		Locality:     "Test City",
+		ExpiryDays:   365,
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		config     CertConfig
+		wantErr    bool
+		wantURIs   []string
+		wantEmails []string
+		wantIPs    int
+	}{
+		{
+			name: "server with IP, URI and email SANs",
+			config: CertConfig{
+				CommonName:     "Test Server",
+				ExpiryDays:     365,
+				Type:           CertTypeServer,
+				IPAddresses:    []string{"127.0.0.1", "::1"},
+				URIs:           []string{"https://example.com/service"},
+				EmailAddresses: []string{"ops@example.com"},
+			},
+			wantURIs:   []string{"https://example.com/service"},
+			wantEmails: []string{"ops@example.com"},
+			wantIPs:    2,
+		},
+		{
+			name: "client with email SAN",
+			config: CertConfig{
+				CommonName:     "Test Client",
+				ExpiryDays:     365,
+				Type:           CertTypeClient,
+				EmailAddresses: []string{"alice@example.com"},
+			},
+			wantEmails: []string{"alice@example.com"},
+		},
+		{
+			name: "SPIFFE SVID without common name",
+			config: CertConfig{
+				ExpiryDays: 365,
+				Type:       CertTypePeer,
+				SPIFFEID:   "spiffe://example.org/ns/default/sa/web",
+			},
+			wantURIs: []string{"spiffe://example.org/ns/default/sa/web"},
+		},
+		{
+			name: "SPIFFE with additional URIs",
+			config: CertConfig{
+				ExpiryDays: 365,
+				SPIFFEID:   "spiffe://example.org/ns/default/sa/web",
+				URIs:       []string{"https://example.com"},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "invalid IP address",
+			config:  CertConfig{ExpiryDays: 365, IPAddresses: []string{"300.1.1.1"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid email address",
+			config:  CertConfig{ExpiryDays: 365, EmailAddresses: []string{"Alice <alice@example.com>"}},
+			wantErr: true,
+		},
+		{
+			name:    "URI without scheme",
+			config:  CertConfig{ExpiryDays: 365, URIs: []string{"example.com/path"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenerateCert(tt.config, ca.CertPEM, ca.KeyPEM)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GenerateCert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSAN) {
+					t.Errorf("Expected ErrInvalidSAN, got %v", err)
+				}
+				return
+			}
+
+			block, _ := pem.Decode(got.CertPEM)
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				t.Fatalf("Failed to parse certificate: %v", err)
+			}
+
+			if len(cert.URIs) != len(tt.wantURIs) {
+				t.Fatalf("Expected %d URIs, got %d", len(tt.wantURIs), len(cert.URIs))
+			}
+			for i, uri := range tt.wantURIs {
+				if cert.URIs[i].String() != uri {
+					t.Errorf("Expected URI %s, got %s", uri, cert.URIs[i])
+				}
+			}
+			if len(cert.EmailAddresses) != len(tt.wantEmails) {
+				t.Fatalf("Expected %d email addresses, got %d", len(tt.wantEmails), len(cert.EmailAddresses))
+			}
+			for i, email := range tt.wantEmails {
+				if cert.EmailAddresses[i] != email {
+					t.Errorf("Expected email %s, got %s", email, cert.EmailAddresses[i])
+				}
+			}
+			if len(cert.IPAddresses) != tt.wantIPs {
+				t.Errorf("Expected %d IP addresses, got %d", tt.wantIPs, len(cert.IPAddresses))
+			}
+		})
+	}
+}
+
+func TestParseSPIFFEID(t *testing.T) {
+	tests := []struct {
+		id      string
+		wantErr bool
+	}{
+		{id: "spiffe://example.org/ns/x/sa/y"},
+		{id: "spiffe://prod.example-1.org/workload"},
+		{id: "https://example.org/ns/x", wantErr: true},
+		{id: "spiffe:///ns/x", wantErr: true},
+		{id: "spiffe://example.org", wantErr: true},
+		{id: "spiffe://example.org/", wantErr: true},
+		{id: "spiffe://Example.org/ns/x", wantErr: true},
+		{id: "spiffe://example.org:8443/ns/x", wantErr: true},
+		{id: "spiffe://example.org/ns//x", wantErr: true},
+		{id: "spiffe://example.org/ns/x?q=1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseSPIFFEID(tt.id)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSPIFFEID(%q) error = %v, wantErr %v", tt.id, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package certificate
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// SerialStrategy selects how the serial number of a certificate is chosen
+type SerialStrategy string
+
+const (
+	// SerialRandom uses a random 128-bit serial number (the default)
+	SerialRandom SerialStrategy = "random"
+	// SerialSequential uses the next number of a per-CA counter, see SerialCounter
+	SerialSequential SerialStrategy = "sequential"
+	// SerialSpecified uses the serial number given by the caller
+	SerialSpecified SerialStrategy = "specified"
+)
+
+// ParseSerialStrategy converts a string into a SerialStrategy, defaulting to SerialRandom when empty
+func ParseSerialStrategy(s string) (SerialStrategy, error) {
+	switch SerialStrategy(s) {
+	case "", SerialRandom:
+		return SerialRandom, nil
+	case SerialSequential:
+		return SerialSequential, nil
+	case SerialSpecified:
+		return SerialSpecified, nil
+	default:
+		return "", fmt.Errorf("unknown serial strategy %q", s)
+	}
+}
+
+// maxSerial is the largest serial number allowed by RFC 5280 (20 octets, positive)
+var maxSerial = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 159), big.NewInt(1))
+
+// ParseSerial parses a user-specified serial number given in decimal, or in hex with a
+// "0x" prefix or colon separated bytes (e.g. "01:a2:ff")
+func ParseSerial(s string) (*big.Int, error) {
+	s = strings.TrimSpace(s)
+
+	n := new(big.Int)
+	var ok bool
+	switch {
+	case strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X"):
+		_, ok = n.SetString(s[2:], 16)
+	case strings.Contains(s, ":"):
+		_, ok = n.SetString(strings.ReplaceAll(s, ":", ""), 16)
+	default:
+		_, ok = n.SetString(s, 10)
+	}
+	if !ok {
+		return nil, fmt.Errorf("invalid serial number %q", s)
+	}
+
+	if n.Sign() <= 0 || n.Cmp(maxSerial) > 0 {
+		return nil, fmt.Errorf("serial number %q must be positive and at most 20 bytes long", s)
+	}
+	return n, nil
+}
+
+// randomSerial returns a random 128-bit serial number
+func randomSerial() (*big.Int, error) {
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	return serialNumber, nil
+}
+
+// serialNumber returns the specified serial of the CA config or a random one
+func (c CAConfig) serialNumber() (*big.Int, error) {
+	if c.Serial == "" {
+		return randomSerial()
+	}
+	return ParseSerial(c.Serial)
+}
+
+// serialNumber returns the serial for a certificate issued by caCert according to the strategy
+func (c CertConfig) serialNumber(caCert *x509.Certificate) (*big.Int, error) {
+	switch c.SerialStrategy {
+	case SerialSpecified:
+		return ParseSerial(c.Serial)
+	case SerialSequential:
+		if c.SerialCounter == nil {
+			return nil, errors.New("sequential serial numbers require a serial counter")
+		}
+		serial, err := c.SerialCounter.Next(certFingerprint(caCert))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get next serial number: %w", err)
+		}
+		return serial, nil
+	default:
+		return randomSerial()
+	}
+}
+
+// validateSerial reports invalid serial settings to the validator
+func (c CertConfig) validateSerial(v *validator) {
+	strategy, err := ParseSerialStrategy(string(c.SerialStrategy))
+	if err != nil {
+		v.add("serialStrategy", err)
+		return
+	}
+
+	switch strategy {
+	case SerialSpecified:
+		if c.Serial == "" {
+			v.add("serial", errors.New("is required for the specified serial strategy"))
+		} else if _, err := ParseSerial(c.Serial); err != nil {
+			v.add("serial", err)
+		}
+	case SerialSequential:
+		if c.SerialCounter == nil {
+			v.add("serialStrategy", errors.New("sequential serial numbers require a serial counter"))
+		}
+		fallthrough
+	default:
+		if c.Serial != "" {
+			v.addf("serial", "only allowed with the %q serial strategy", SerialSpecified)
+		}
+	}
+}
+
+// SerialCounter hands out sequential serial numbers per CA
+type SerialCounter interface {
+	// Next returns the next serial number for the CA with the given fingerprint
+	Next(caFingerprint string) (*big.Int, error)
+}
+
+// MemorySerialCounter is a SerialCounter that only lives as long as the process
+type MemorySerialCounter struct {
+	mu   sync.Mutex
+	last map[string]*big.Int
+}
+
+// NewMemorySerialCounter creates an empty in-memory serial counter
+func NewMemorySerialCounter() *MemorySerialCounter {
+	return &MemorySerialCounter{last: make(map[string]*big.Int)}
+}
+
+// Next implements SerialCounter
+func (c *MemorySerialCounter) Next(caFingerprint string) (*big.Int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	next := nextSerial(c.last[caFingerprint])
+	c.last[caFingerprint] = next
+	return new(big.Int).Set(next), nil
+}
+
+// FileSerialCounter is a SerialCounter persisted as a JSON file mapping CA fingerprints
+// to the last issued serial number
+type FileSerialCounter struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileSerialCounter creates a serial counter stored at path; the file is created on first use
+func NewFileSerialCounter(path string) *FileSerialCounter {
+	return &FileSerialCounter{path: path}
+}
+
+// Next implements SerialCounter
+func (c *FileSerialCounter) Next(caFingerprint string) (*big.Int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	serials := make(map[string]string)
+	data, err := os.ReadFile(c.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read serial store: %w", err)
+	default:
+		if err := json.Unmarshal(data, &serials); err != nil {
+			return nil, fmt.Errorf("failed to decode serial store: %w", err)
+		}
+	}
+
+	var last *big.Int
+	if s, ok := serials[caFingerprint]; ok {
+		last, ok = new(big.Int).SetString(s, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid serial %q in serial store", s)
+		}
+	}
+
+	next := nextSerial(last)
+	serials[caFingerprint] = next.String()
+
+	data, err = json.MarshalIndent(serials, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode serial store: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated store behind
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".serials-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to write serial store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("failed to write serial store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write serial store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return nil, fmt.Errorf("failed to write serial store: %w", err)
+	}
+
+	return next, nil
+}
+
+// Check reports whether the serial store can be read, for readiness probes
+func (c *FileSerialCounter) Check() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		// Created on first use; the directory must exist for that
+		_, err = os.Stat(filepath.Dir(c.path))
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read serial store: %w", err)
+	}
+	if err := json.Unmarshal(data, &map[string]string{}); err != nil {
+		return fmt.Errorf("failed to decode serial store: %w", err)
+	}
+	return nil
+}
+
+func nextSerial(last *big.Int) *big.Int {
+	if last == nil {
+		return big.NewInt(1)
+	}
+	return new(big.Int).Add(last, big.NewInt(1))
+}
+
+// certFingerprint returns the lowercase hex SHA-256 fingerprint of a parsed certificate
+func certFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// keyIdentifier computes a key identifier as described in RFC 5280 section 4.2.1.2
+// method 1: the SHA-1 hash of the subjectPublicKey BIT STRING
+func keyIdentifier(pub crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+
+	var spki struct {
+		Algorithm        pkix.AlgorithmIdentifier
+		SubjectPublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(der, &spki); err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+
+	sum := sha1.Sum(spki.SubjectPublicKey.Bytes)
+	return sum[:], nil
+}
@@ -0,0 +1,183 @@
+package certificate
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSerial(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{input: "42", want: 42},
+		{input: "0x2a", want: 42},
+		{input: "00:2a", want: 42},
+		{input: "0", wantErr: true},
+		{input: "-1", wantErr: true},
+		{input: "0x" + "ff" + "0000000000000000000000000000000000000000", wantErr: true},
+		{input: "serial", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSerial(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSerial(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got.Cmp(big.NewInt(tt.want)) != 0 {
+			t.Errorf("ParseSerial(%q) = %v, want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateKeyIdentifiers(t *testing.T) {
+	caBundle, err := GenerateCA(CAConfig{CommonName: "Test CA", ExpiryDays: 1})
+	if err != nil {
+		t.Fatalf("GenerateCA() error = %v", err)
+	}
+	ca := parseTestCert(t, caBundle.CertPEM)
+
+	if len(ca.SubjectKeyId) != 20 {
+		t.Fatalf("Expected a 20 byte subject key identifier on the CA, got %x", ca.SubjectKeyId)
+	}
+	if !bytes.Equal(ca.AuthorityKeyId, ca.SubjectKeyId) {
+		t.Errorf("Expected CA authority key identifier %x to equal its subject key identifier %x", ca.AuthorityKeyId, ca.SubjectKeyId)
+	}
+
+	bundle, err := GenerateCert(CertConfig{CommonName: "leaf", ExpiryDays: 1, DNSNames: []string{"leaf.example"}}, caBundle.CertPEM, caBundle.KeyPEM)
+	if err != nil {
+		t.Fatalf("GenerateCert() error = %v", err)
+	}
+	leaf := parseTestCert(t, bundle.CertPEM)
+
+	if !bytes.Equal(leaf.AuthorityKeyId, ca.SubjectKeyId) {
+		t.Errorf("Expected leaf authority key identifier %x, got %x", ca.SubjectKeyId, leaf.AuthorityKeyId)
+	}
+	wantSKI, err := keyIdentifier(leaf.PublicKey)
+	if err != nil {
+		t.Fatalf("keyIdentifier() error = %v", err)
+	}
+	if !bytes.Equal(leaf.SubjectKeyId, wantSKI) {
+		t.Errorf("Expected leaf subject key identifier %x, got %x", wantSKI, leaf.SubjectKeyId)
+	}
+}
+
+func TestGenerateCertSerialStrategies(t *testing.T) {
+	caBundle, err := GenerateCA(CAConfig{CommonName: "Test CA", ExpiryDays: 1, Serial: "0x1000"})
+	if err != nil {
+		t.Fatalf("GenerateCA() error = %v", err)
+	}
+	if serial := parseTestCert(t, caBundle.CertPEM).SerialNumber; serial.Cmp(big.NewInt(0x1000)) != 0 {
+		t.Errorf("Expected CA serial 4096, got %v", serial)
+	}
+
+	otherCA, err := GenerateCA(CAConfig{CommonName: "Other CA", ExpiryDays: 1})
+	if err != nil {
+		t.Fatalf("GenerateCA() error = %v", err)
+	}
+
+	counters := map[string]SerialCounter{
+		"memory": NewMemorySerialCounter(),
+		"file":   NewFileSerialCounter(filepath.Join(t.TempDir(), "serials.json")),
+	}
+	for name, counter := range counters {
+		t.Run(name, func(t *testing.T) {
+			config := CertConfig{CommonName: "leaf", ExpiryDays: 1, SerialStrategy: SerialSequential, SerialCounter: counter}
+
+			for _, tc := range []struct {
+				ca   *CertBundle
+				want int64
+			}{
+				{caBundle, 1},
+				{caBundle, 2},
+				{otherCA, 1},
+				{caBundle, 3},
+			} {
+				bundle, err := GenerateCert(config, tc.ca.CertPEM, tc.ca.KeyPEM)
+				if err != nil {
+					t.Fatalf("GenerateCert() error = %v", err)
+				}
+				if serial := parseTestCert(t, bundle.CertPEM).SerialNumber; serial.Cmp(big.NewInt(tc.want)) != 0 {
+					t.Errorf("Expected serial %d, got %v", tc.want, serial)
+				}
+			}
+		})
+	}
+
+	t.Run("specified", func(t *testing.T) {
+		config := CertConfig{CommonName: "leaf", ExpiryDays: 1, SerialStrategy: SerialSpecified, Serial: "01:00"}
+		bundle, err := GenerateCert(config, caBundle.CertPEM, caBundle.KeyPEM)
+		if err != nil {
+			t.Fatalf("GenerateCert() error = %v", err)
+		}
+		if serial := parseTestCert(t, bundle.CertPEM).SerialNumber; serial.Cmp(big.NewInt(256)) != 0 {
+			t.Errorf("Expected serial 256, got %v", serial)
+		}
+	})
+
+	t.Run("rejected requests", func(t *testing.T) {
+		constrainedCA, err := GenerateCA(CAConfig{CommonName: "Constrained CA", ExpiryDays: 1, NameConstraints: NameConstraints{PermittedDNSDomains: []string{"internal.example"}}})
+		if err != nil {
+			t.Fatalf("GenerateCA() error = %v", err)
+		}
+		config := CertConfig{CommonName: "leaf", ExpiryDays: 1, SerialStrategy: SerialSequential, SerialCounter: NewMemorySerialCounter()}
+
+		rejected := config
+		rejected.DNSNames = []string{"www.example.com"}
+		if _, err := GenerateCert(rejected, constrainedCA.CertPEM, constrainedCA.KeyPEM); err == nil {
+			t.Fatal("Expected the name constraints to reject the request")
+		}
+		bundle, err := GenerateCert(config, constrainedCA.CertPEM, constrainedCA.KeyPEM)
+		if err != nil {
+			t.Fatalf("GenerateCert() error = %v", err)
+		}
+		if serial := parseTestCert(t, bundle.CertPEM).SerialNumber; serial.Cmp(big.NewInt(1)) != 0 {
+			t.Errorf("Expected serial 1 after a rejected request, got %v", serial)
+		}
+	})
+
+	t.Run("persisted", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "serials.json")
+		config := CertConfig{CommonName: "leaf", ExpiryDays: 1, SerialStrategy: SerialSequential}
+
+		for _, want := range []int64{1, 2} {
+			// A fresh counter per certificate simulates a restart of the process
+			config.SerialCounter = NewFileSerialCounter(path)
+			bundle, err := GenerateCert(config, caBundle.CertPEM, caBundle.KeyPEM)
+			if err != nil {
+				t.Fatalf("GenerateCert() error = %v", err)
+			}
+			if serial := parseTestCert(t, bundle.CertPEM).SerialNumber; serial.Cmp(big.NewInt(want)) != 0 {
+				t.Errorf("Expected serial %d, got %v", want, serial)
+			}
+		}
+	})
+}
+
+func TestSerialValidate(t *testing.T) {
+	assertFieldErrors(t, CAConfig{CommonName: "CA", ExpiryDays: 1, Serial: "0"}.Validate(), []string{"serial"})
+	assertFieldErrors(t, CertConfig{CommonName: "leaf", ExpiryDays: 1, SerialStrategy: "counter"}.Validate(), []string{"serialStrategy"})
+	assertFieldErrors(t, CertConfig{CommonName: "leaf", ExpiryDays: 1, SerialStrategy: SerialSpecified}.Validate(), []string{"serial"})
+	assertFieldErrors(t, CertConfig{CommonName: "leaf", ExpiryDays: 1, SerialStrategy: SerialSequential}.Validate(), []string{"serialStrategy"})
+	assertFieldErrors(t, CertConfig{CommonName: "leaf", ExpiryDays: 1, Serial: "42"}.Validate(), []string{"serial"})
+}
+
+func parseTestCert(t *testing.T, certPEM []byte) *x509.Certificate {
+	t.Helper()
+
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		t.Fatal("Failed to decode certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert
+}
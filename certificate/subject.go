@@ -0,0 +1,313 @@
+package certificate
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Attribute is an additional subject attribute identified by its dotted OID
+type Attribute struct {
+	OID   string `json:"oid"`
+	Value string `json:"value"`
+}
+
+// Well-known attribute types accepted in RFC 4514 distinguished names
+var (
+	oidCommonName         = asn1.ObjectIdentifier{2, 5, 4, 3}
+	oidSerialNumber       = asn1.ObjectIdentifier{2, 5, 4, 5}
+	oidCountry            = asn1.ObjectIdentifier{2, 5, 4, 6}
+	oidLocality           = asn1.ObjectIdentifier{2, 5, 4, 7}
+	oidProvince           = asn1.ObjectIdentifier{2, 5, 4, 8}
+	oidStreetAddress      = asn1.ObjectIdentifier{2, 5, 4, 9}
+	oidOrganization       = asn1.ObjectIdentifier{2, 5, 4, 10}
+	oidOrganizationalUnit = asn1.ObjectIdentifier{2, 5, 4, 11}
+	oidPostalCode         = asn1.ObjectIdentifier{2, 5, 4, 17}
+	oidDomainComponent    = asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 25}
+	oidUserID             = asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 1}
+	oidEmailAddress       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}
+)
+
+// attributeTypes maps RFC 4514 short names (upper case) to their OIDs
+var attributeTypes = map[string]asn1.ObjectIdentifier{
+	"CN":           oidCommonName,
+	"SERIALNUMBER": oidSerialNumber,
+	"C":            oidCountry,
+	"L":            oidLocality,
+	"ST":           oidProvince,
+	"STREET":       oidStreetAddress,
+	"O":            oidOrganization,
+	"OU":           oidOrganizationalUnit,
+	"POSTALCODE":   oidPostalCode,
+	"DC":           oidDomainComponent,
+	"UID":          oidUserID,
+	"EMAILADDRESS": oidEmailAddress,
+}
+
+// typedAttributes names the subject fields that hold the standard attribute types. Extra
+// attributes must not use these types, as they would override the field's value in the
+// encoded name and bypass its validation.
+var typedAttributes = []struct {
+	oid   asn1.ObjectIdentifier
+	field string
+}{
+	{oidCommonName, "commonName"},
+	{oidSerialNumber, "serialNumber"},
+	{oidCountry, "country"},
+	{oidLocality, "locality"},
+	{oidProvince, "province"},
+	{oidStreetAddress, "streetAddress"},
+	{oidOrganization, "organization"},
+	{oidOrganizationalUnit, "organizationalUnit"},
+	{oidPostalCode, "postalCode"},
+}
+
+// parseExtraAttribute parses the OID of an extra attribute, rejecting the standard
+// attribute types that have their own subject field
+func parseExtraAttribute(attr Attribute) (asn1.ObjectIdentifier, error) {
+	oid, err := parseOID(attr.OID)
+	if err != nil {
+		return nil, err
+	}
+	for _, typed := range typedAttributes {
+		if oid.Equal(typed.oid) {
+			return nil, fmt.Errorf("OID %s is a standard attribute, set it with the %s field", attr.OID, typed.field)
+		}
+	}
+	return oid, nil
+}
+
+// subject collects the distinguished name attributes shared by CAConfig and CertConfig
+type subject struct {
+	Organization       string
+	OrganizationalUnit string
+	CommonName         string
+	Country            string
+	Province           string
+	Locality           string
+	StreetAddress      string
+	PostalCode         string
+	SerialNumber       string
+	ExtraAttributes    []Attribute
+	DN                 string
+}
+
+// name builds the pkix.Name for the subject. Attributes present in the DN string take
+// precedence over the individual fields, which only fill in what the DN leaves out.
+func (s subject) name() (pkix.Name, error) {
+	var name pkix.Name
+	if s.DN != "" {
+		parsed, err := ParseDN(s.DN)
+		if err != nil {
+			return pkix.Name{}, err
+		}
+		name = parsed
+	}
+
+	if name.Organization == nil && s.Organization != "" {
+		name.Organization = []string{s.Organization}
+	}
+	if name.CommonName == "" {
+		name.CommonName = s.CommonName
+	}
+	if name.Country == nil && s.Country != "" {
+		name.Country = []string{s.Country}
+	}
+	if name.Locality == nil && s.Locality != "" {
+		name.Locality = []string{s.Locality}
+	}
+	if name.OrganizationalUnit == nil && s.OrganizationalUnit != "" {
+		name.OrganizationalUnit = []string{s.OrganizationalUnit}
+	}
+	if name.Province == nil && s.Province != "" {
+		name.Province = []string{s.Province}
+	}
+	if name.StreetAddress == nil && s.StreetAddress != "" {
+		name.StreetAddress = []string{s.StreetAddress}
+	}
+	if name.PostalCode == nil && s.PostalCode != "" {
+		name.PostalCode = []string{s.PostalCode}
+	}
+	if name.SerialNumber == "" {
+		name.SerialNumber = s.SerialNumber
+	}
+
+	for _, attr := range s.ExtraAttributes {
+		oid, err := parseExtraAttribute(attr)
+		if err != nil {
+			return pkix.Name{}, err
+		}
+		name.ExtraNames = append(name.ExtraNames, pkix.AttributeTypeAndValue{Type: oid, Value: attr.Value})
+	}
+
+	return name, nil
+}
+
+// ParseDN parses an RFC 4514 distinguished name string such as "CN=foo,OU=bar,O=baz".
+// Known attribute types populate the matching pkix.Name fields; other types, given as
+// short names (DC, UID, emailAddress) or dotted OIDs, are returned in ExtraNames.
+func ParseDN(dn string) (pkix.Name, error) {
+	var name pkix.Name
+
+	rdns, err := splitDN(dn)
+	if err != nil {
+		return pkix.Name{}, err
+	}
+
+	// RFC 4514 lists the most specific RDN first, so walk backwards to keep the
+	// natural order when the same attribute type appears more than once
+	for i := len(rdns) - 1; i >= 0; i-- {
+		for _, ava := range rdns[i] {
+			key, value, ok := strings.Cut(ava, "=")
+			if !ok {
+				return pkix.Name{}, fmt.Errorf("invalid DN attribute %q: missing '='", ava)
+			}
+			key = strings.TrimSpace(key)
+
+			oid, known := attributeTypes[strings.ToUpper(key)]
+			if !known {
+				oid, err = parseOID(key)
+				if err != nil {
+					return pkix.Name{}, fmt.Errorf("invalid DN attribute type %q", key)
+				}
+			}
+
+			value, err = unescapeDNValue(value)
+			if err != nil {
+				return pkix.Name{}, err
+			}
+
+			switch {
+			case oid.Equal(oidCommonName):
+				name.CommonName = value
+			case oid.Equal(oidSerialNumber):
+				name.SerialNumber = value
+			case oid.Equal(oidCountry):
+				name.Country = append(name.Country, value)
+			case oid.Equal(oidLocality):
+				name.Locality = append(name.Locality, value)
+			case oid.Equal(oidProvince):
+				name.Province = append(name.Province, value)
+			case oid.Equal(oidStreetAddress):
+				name.StreetAddress = append(name.StreetAddress, value)
+			case oid.Equal(oidOrganization):
+				name.Organization = append(name.Organization, value)
+			case oid.Equal(oidOrganizationalUnit):
+				name.OrganizationalUnit = append(name.OrganizationalUnit, value)
+			case oid.Equal(oidPostalCode):
+				name.PostalCode = append(name.PostalCode, value)
+			default:
+				name.ExtraNames = append(name.ExtraNames, pkix.AttributeTypeAndValue{Type: oid, Value: value})
+			}
+		}
+	}
+
+	return name, nil
+}
+
+// splitDN splits a DN string into RDNs (separated by ',') and their attribute
+// type-and-value pairs (separated by '+'), honouring backslash escapes
+func splitDN(dn string) ([][]string, error) {
+	var (
+		rdns    [][]string
+		current []string
+		buf     strings.Builder
+	)
+
+	for i := 0; i < len(dn); i++ {
+		c := dn[i]
+		switch c {
+		case '\\':
+			if i+1 >= len(dn) {
+				return nil, fmt.Errorf("invalid DN %q: trailing backslash", dn)
+			}
+			buf.WriteByte(c)
+			buf.WriteByte(dn[i+1])
+			i++
+		case ',', '+':
+			current = append(current, buf.String())
+			buf.Reset()
+			if c == ',' {
+				rdns = append(rdns, current)
+				current = nil
+			}
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	current = append(current, buf.String())
+	rdns = append(rdns, current)
+
+	for _, rdn := range rdns {
+		for _, ava := range rdn {
+			if strings.TrimSpace(ava) == "" {
+				return nil, fmt.Errorf("invalid DN %q: empty attribute", dn)
+			}
+		}
+	}
+
+	return rdns, nil
+}
+
+// unescapeDNValue resolves RFC 4514 escapes in an attribute value
+func unescapeDNValue(value string) (string, error) {
+	value = strings.TrimLeft(value, " ")
+	if strings.HasPrefix(value, "#") {
+		return "", fmt.Errorf("hex-encoded DN value %q is not supported", value)
+	}
+
+	var out strings.Builder
+	trailing := 0
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c != '\\' {
+			out.WriteByte(c)
+			if c == ' ' {
+				trailing++
+			} else {
+				trailing = 0
+			}
+			continue
+		}
+
+		// Escaped characters are never stripped as trailing whitespace
+		trailing = 0
+		if i+2 < len(value) && isHex(value[i+1]) && isHex(value[i+2]) {
+			b, _ := hex.DecodeString(value[i+1 : i+3])
+			out.Write(b)
+			i += 2
+			continue
+		}
+		out.WriteByte(value[i+1])
+		i++
+	}
+
+	result := out.String()
+	return result[:len(result)-trailing], nil
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// parseOID parses a dotted object identifier such as "1.3.6.1.4.1.311.60.2.1.3"
+func parseOID(s string) (asn1.ObjectIdentifier, error) {
+	parts := strings.Split(strings.TrimSpace(s), ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid OID %q", s)
+	}
+
+	oid := make(asn1.ObjectIdentifier, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid OID %q", s)
+		}
+		oid[i] = n
+	}
+
+	return oid, nil
+}
@@ -0,0 +1,105 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"testing"
+)
+
+func TestParseDN(t *testing.T) {
+	name, err := ParseDN(`CN=foo\, bar,OU=Eng+OU=Ops,O=Baz Inc.,ST=CA,C=US,1.3.6.1.4.1.99999.1=custom,DC=example`)
+	if err != nil {
+		t.Fatalf("ParseDN() error = %v", err)
+	}
+
+	if name.CommonName != "foo, bar" {
+		t.Errorf("Expected CommonName 'foo, bar', got %q", name.CommonName)
+	}
+	if len(name.OrganizationalUnit) != 2 || name.OrganizationalUnit[0] != "Eng" || name.OrganizationalUnit[1] != "Ops" {
+		t.Errorf("Expected OrganizationalUnit [Eng Ops], got %v", name.OrganizationalUnit)
+	}
+	if len(name.Organization) != 1 || name.Organization[0] != "Baz Inc." {
+		t.Errorf("Expected Organization [Baz Inc.], got %v", name.Organization)
+	}
+	if len(name.Province) != 1 || name.Province[0] != "CA" {
+		t.Errorf("Expected Province [CA], got %v", name.Province)
+	}
+	if len(name.ExtraNames) != 2 {
+		t.Fatalf("Expected 2 extra names, got %d", len(name.ExtraNames))
+	}
+	if !name.ExtraNames[0].Type.Equal(oidDomainComponent) {
+		t.Errorf("Expected first extra name to be DC, got %v", name.ExtraNames[0].Type)
+	}
+	if !name.ExtraNames[1].Type.Equal(asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}) || name.ExtraNames[1].Value != "custom" {
+		t.Errorf("Unexpected custom extra name %v", name.ExtraNames[1])
+	}
+}
+
+func TestParseDNInvalid(t *testing.T) {
+	tests := []string{
+		"CN",
+		"CN=foo,,O=bar",
+		"XX=foo",
+		"CN=#0403666f6f",
+		`CN=foo\`,
+	}
+
+	for _, dn := range tests {
+		if _, err := ParseDN(dn); err == nil {
+			t.Errorf("ParseDN(%q) should fail", dn)
+		}
+	}
+}
+
+func TestGenerateCAFullSubject(t *testing.T) {
+	config := CAConfig{
+		Organization:       "Field Org",
+		CommonName:         "Field CA",
+		Country:            "DE",
+		Locality:           "Berlin",
+		ExpiryDays:         365,
+		OrganizationalUnit: "Platform",
+		Province:           "Berlin",
+		StreetAddress:      "Example Street 1",
+		PostalCode:         "10115",
+		SerialNumber:       "42",
+		ExtraAttributes:    []Attribute{{OID: "1.3.6.1.4.1.99999.2", Value: "extra"}},
+		DN:                 "CN=DN CA,O=DN Org",
+	}
+
+	bundle, err := GenerateCA(config)
+	if err != nil {
+		t.Fatalf("GenerateCA() error = %v", err)
+	}
+
+	block, _ := pem.Decode(bundle.CertPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+
+	subject := cert.Subject
+	if subject.CommonName != "DN CA" {
+		t.Errorf("Expected DN CommonName to take precedence, got %q", subject.CommonName)
+	}
+	if subject.Organization[0] != "DN Org" {
+		t.Errorf("Expected DN Organization to take precedence, got %q", subject.Organization[0])
+	}
+	if subject.Country[0] != "DE" || subject.OrganizationalUnit[0] != "Platform" || subject.Province[0] != "Berlin" {
+		t.Errorf("Expected field attributes to fill in the DN, got %v", subject)
+	}
+	if subject.StreetAddress[0] != "Example Street 1" || subject.PostalCode[0] != "10115" || subject.SerialNumber != "42" {
+		t.Errorf("Unexpected address attributes in %v", subject)
+	}
+
+	found := false
+	for _, attr := range subject.Names {
+		if attr.Type.Equal(asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 2}) && attr.Value == "extra" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Extra attribute missing from subject")
+	}
+}
@@ -0,0 +1,205 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// keyUsageNames maps the RFC 5280 key usage names to their bits, in bit order
+var keyUsageNames = []struct {
+	name  string
+	usage x509.KeyUsage
+}{
+	{"digitalSignature", x509.KeyUsageDigitalSignature},
+	{"contentCommitment", x509.KeyUsageContentCommitment},
+	{"keyEncipherment", x509.KeyUsageKeyEncipherment},
+	{"dataEncipherment", x509.KeyUsageDataEncipherment},
+	{"keyAgreement", x509.KeyUsageKeyAgreement},
+	{"keyCertSign", x509.KeyUsageCertSign},
+	{"cRLSign", x509.KeyUsageCRLSign},
+	{"encipherOnly", x509.KeyUsageEncipherOnly},
+	{"decipherOnly", x509.KeyUsageDecipherOnly},
+}
+
+// extKeyUsageNames maps the extended key usage names accepted in configs to their values
+var extKeyUsageNames = []struct {
+	name  string
+	usage x509.ExtKeyUsage
+}{
+	{"any", x509.ExtKeyUsageAny},
+	{"serverAuth", x509.ExtKeyUsageServerAuth},
+	{"clientAuth", x509.ExtKeyUsageClientAuth},
+	{"codeSigning", x509.ExtKeyUsageCodeSigning},
+	{"emailProtection", x509.ExtKeyUsageEmailProtection},
+	{"ipsecEndSystem", x509.ExtKeyUsageIPSECEndSystem},
+	{"ipsecTunnel", x509.ExtKeyUsageIPSECTunnel},
+	{"ipsecUser", x509.ExtKeyUsageIPSECUser},
+	{"timeStamping", x509.ExtKeyUsageTimeStamping},
+	{"ocspSigning", x509.ExtKeyUsageOCSPSigning},
+}
+
+// KeyUsageNames lists the key usage names accepted by ParseKeyUsage
+func KeyUsageNames() []string {
+	names := make([]string, len(keyUsageNames))
+	for i, ku := range keyUsageNames {
+		names[i] = ku.name
+	}
+	return names
+}
+
+// ExtKeyUsageNames lists the extended key usage names accepted by ParseExtKeyUsage
+func ExtKeyUsageNames() []string {
+	names := make([]string, len(extKeyUsageNames))
+	for i, eku := range extKeyUsageNames {
+		names[i] = eku.name
+	}
+	return names
+}
+
+// ParseKeyUsage converts a key usage name such as "digitalSignature" into its bit.
+// "nonRepudiation" is accepted as the older name of contentCommitment.
+func ParseKeyUsage(s string) (x509.KeyUsage, error) {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "nonRepudiation") {
+		return x509.KeyUsageContentCommitment, nil
+	}
+	for _, ku := range keyUsageNames {
+		if strings.EqualFold(s, ku.name) {
+			return ku.usage, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown key usage %q", s)
+}
+
+// ParseExtKeyUsage converts an extended key usage name such as "serverAuth" or a dotted OID.
+// OIDs without a well-known name are returned as unknown, so they can be added as custom EKUs.
+func ParseExtKeyUsage(s string) (usage x509.ExtKeyUsage, unknown asn1.ObjectIdentifier, err error) {
+	s = strings.TrimSpace(s)
+	for _, eku := range extKeyUsageNames {
+		if strings.EqualFold(s, eku.name) {
+			return eku.usage, nil, nil
+		}
+	}
+
+	oid, err := parseOID(s)
+	if err != nil {
+		return 0, nil, fmt.Errorf("unknown extended key usage %q", s)
+	}
+	return 0, oid, nil
+}
+
+// KeyUsage returns the default key usage for the certificate type. Certificates use ECDSA keys,
+// so keyEncipherment (an RSA key transport usage) never applies. Server and peer certificates
+// add keyAgreement for TLS key exchanges with the certificate's EC key (ECDH_ECDSA).
+func (t CertType) KeyUsage() x509.KeyUsage {
+	switch t {
+	case CertTypeClient:
+		return x509.KeyUsageDigitalSignature
+	default:
+		return x509.KeyUsageDigitalSignature | x509.KeyUsageKeyAgreement
+	}
+}
+
+// keyUsages converts key usage names into the combined bits, falling back to the type's default
+func (c CertConfig) keyUsages() (x509.KeyUsage, error) {
+	if len(c.KeyUsage) == 0 {
+		return c.Type.KeyUsage(), nil
+	}
+
+	var usage x509.KeyUsage
+	for _, name := range c.KeyUsage {
+		ku, err := ParseKeyUsage(name)
+		if err != nil {
+			return 0, err
+		}
+		usage |= ku
+	}
+	return usage, nil
+}
+
+// extKeyUsages converts extended key usage names and OIDs, falling back to the type's default
+func (c CertConfig) extKeyUsages() ([]x509.ExtKeyUsage, []asn1.ObjectIdentifier, error) {
+	if len(c.ExtKeyUsage) == 0 {
+		return c.Type.ExtKeyUsages(), nil, nil
+	}
+
+	var usages []x509.ExtKeyUsage
+	var unknown []asn1.ObjectIdentifier
+	for _, name := range c.ExtKeyUsage {
+		eku, oid, err := ParseExtKeyUsage(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if oid != nil {
+			unknown = append(unknown, oid)
+		} else if !slices.Contains(usages, eku) {
+			usages = append(usages, eku)
+		}
+	}
+	return usages, unknown, nil
+}
+
+// validateUsages reports unknown key usage and extended key usage names to the validator,
+// as well as the CA usages keyCertSign and cRLSign, which leaf certificates must not have
+func (c CertConfig) validateUsages(v *validator) {
+	for i, name := range c.KeyUsage {
+		field := fmt.Sprintf("keyUsage[%d]", i)
+		ku, err := ParseKeyUsage(name)
+		if err != nil {
+			v.add(field, err)
+		} else if ku&(x509.KeyUsageCertSign|x509.KeyUsageCRLSign) != 0 {
+			v.addf(field, "%s is only allowed for CA certificates", name)
+		}
+	}
+	for i, name := range c.ExtKeyUsage {
+		if _, _, err := ParseExtKeyUsage(name); err != nil {
+			v.add(fmt.Sprintf("extKeyUsage[%d]", i), err)
+		}
+	}
+}
+
+// sameExtKeyUsage reports whether two extended key usage names or OIDs denote the same usage
+func sameExtKeyUsage(a, b string) bool {
+	ekuA, oidA, errA := ParseExtKeyUsage(a)
+	ekuB, oidB, errB := ParseExtKeyUsage(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	if oidA != nil || oidB != nil {
+		return oidA.Equal(oidB)
+	}
+	return ekuA == ekuB
+}
+
+// keyUsageList returns the names of the bits set in usage
+func keyUsageList(usage x509.KeyUsage) []string {
+	var names []string
+	for _, ku := range keyUsageNames {
+		if usage&ku.usage != 0 {
+			names = append(names, ku.name)
+		}
+	}
+	return names
+}
+
+// extKeyUsageList returns the names of the extended key usages, with unknown ones as dotted OIDs
+func extKeyUsageList(usages []x509.ExtKeyUsage, unknown []asn1.ObjectIdentifier) []string {
+	var names []string
+	for _, usage := range usages {
+		name := fmt.Sprintf("unknown(%d)", usage)
+		for _, eku := range extKeyUsageNames {
+			if eku.usage == usage {
+				name = eku.name
+				break
+			}
+		}
+		names = append(names, name)
+	}
+	for _, oid := range unknown {
+		names = append(names, oid.String())
+	}
+	return names
+}
@@ -0,0 +1,130 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"slices"
+	"testing"
+)
+
+func TestGenerateCertKeyUsage(t *testing.T) {
+	caBundle, err := GenerateCA(CAConfig{CommonName: "Test CA", ExpiryDays: 1})
+	if err != nil {
+		t.Fatalf("GenerateCA() error = %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		config          CertConfig
+		wantKeyUsage    x509.KeyUsage
+		wantExtKeyUsage []x509.ExtKeyUsage
+		wantUnknownEKU  []string
+	}{
+		{
+			name:            "server defaults",
+			config:          CertConfig{CommonName: "server", ExpiryDays: 1, Type: CertTypeServer},
+			wantKeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyAgreement,
+			wantExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		},
+		{
+			name:            "peer defaults",
+			config:          CertConfig{CommonName: "peer", ExpiryDays: 1, Type: CertTypePeer},
+			wantKeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyAgreement,
+			wantExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		},
+		{
+			name:            "client defaults",
+			config:          CertConfig{CommonName: "client", ExpiryDays: 1, Type: CertTypeClient},
+			wantKeyUsage:    x509.KeyUsageDigitalSignature,
+			wantExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		},
+		{
+			name: "code signing",
+			config: CertConfig{
+				CommonName:  "signer",
+				ExpiryDays:  1,
+				Type:        CertTypeClient,
+				KeyUsage:    []string{"digitalSignature", "nonRepudiation"},
+				ExtKeyUsage: []string{"codeSigning", "timeStamping"},
+			},
+			wantKeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+			wantExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning, x509.ExtKeyUsageTimeStamping},
+		},
+		{
+			name: "custom EKU OID",
+			config: CertConfig{
+				CommonName:  "device",
+				ExpiryDays:  1,
+				Type:        CertTypeClient,
+				KeyUsage:    []string{"keyAgreement"},
+				ExtKeyUsage: []string{"clientAuth", "1.3.6.1.4.1.99999.3.1"},
+			},
+			wantKeyUsage:    x509.KeyUsageKeyAgreement,
+			wantExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			wantUnknownEKU:  []string{"1.3.6.1.4.1.99999.3.1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bundle, err := GenerateCert(tt.config, caBundle.CertPEM, caBundle.KeyPEM)
+			if err != nil {
+				t.Fatalf("GenerateCert() error = %v", err)
+			}
+			cert := parseTestCert(t, bundle.CertPEM)
+
+			if cert.KeyUsage != tt.wantKeyUsage {
+				t.Errorf("KeyUsage = %v, want %v", cert.KeyUsage, tt.wantKeyUsage)
+			}
+			if !slices.Equal(cert.ExtKeyUsage, tt.wantExtKeyUsage) {
+				t.Errorf("ExtKeyUsage = %v, want %v", cert.ExtKeyUsage, tt.wantExtKeyUsage)
+			}
+			var unknown []string
+			for _, oid := range cert.UnknownExtKeyUsage {
+				unknown = append(unknown, oid.String())
+			}
+			if !slices.Equal(unknown, tt.wantUnknownEKU) {
+				t.Errorf("UnknownExtKeyUsage = %v, want %v", unknown, tt.wantUnknownEKU)
+			}
+		})
+	}
+}
+
+func TestInspectKeyUsage(t *testing.T) {
+	caBundle, err := GenerateCA(CAConfig{CommonName: "Test CA", ExpiryDays: 1})
+	if err != nil {
+		t.Fatalf("GenerateCA() error = %v", err)
+	}
+
+	config := CertConfig{
+		CommonName:  "responder",
+		ExpiryDays:  1,
+		Type:        CertTypeClient,
+		KeyUsage:    []string{"digitalSignature"},
+		ExtKeyUsage: []string{"ocspSigning", "1.2.3.4"},
+	}
+	bundle, err := GenerateCert(config, caBundle.CertPEM, caBundle.KeyPEM)
+	if err != nil {
+		t.Fatalf("GenerateCert() error = %v", err)
+	}
+
+	info, err := Inspect(bundle.CertPEM)
+	if err != nil {
+		t.Fatalf("Inspect() error = %v", err)
+	}
+	if !slices.Equal(info.KeyUsage, []string{"digitalSignature"}) {
+		t.Errorf("KeyUsage = %v", info.KeyUsage)
+	}
+	if !slices.Equal(info.ExtKeyUsage, []string{"ocspSigning", "1.2.3.4"}) {
+		t.Errorf("ExtKeyUsage = %v", info.ExtKeyUsage)
+	}
+}
+
+func TestUsageValidate(t *testing.T) {
+	config := CertConfig{
+		CommonName:  "leaf",
+		ExpiryDays:  1,
+		KeyUsage:    []string{"digitalSignature", "sign", "keyCertSign", "cRLSign"},
+		ExtKeyUsage: []string{"serverAuth", "webAuth"},
+	}
+	assertFieldErrors(t, config.Validate(), []string{"keyUsage[1]", "keyUsage[2]", "keyUsage[3]", "extKeyUsage[1]"})
+}
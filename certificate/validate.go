@@ -0,0 +1,180 @@
+package certificate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// FieldError describes a single invalid configuration field
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError is returned when a configuration contains one or more invalid fields
+type ValidationError struct {
+	Fields []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Error()
+	}
+	return "invalid configuration: " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Fields))
+	for i, f := range e.Fields {
+		errs[i] = f
+	}
+	return errs
+}
+
+// validator accumulates field errors while checking a configuration
+type validator struct {
+	fields []*FieldError
+}
+
+func (v *validator) add(field string, err error) {
+	v.fields = append(v.fields, &FieldError{Field: field, Err: err})
+}
+
+func (v *validator) addf(field, format string, args ...any) {
+	v.add(field, fmt.Errorf(format, args...))
+}
+
+func (v *validator) err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: v.fields}
+}
+
+// Validate checks the CA configuration and returns a *ValidationError naming every invalid field
+func (c CAConfig) Validate() error {
+	var v validator
+	v.checkSubject(c.subject(), true)
+	c.validity().validate(&v)
+	c.NameConstraints.validate(&v)
+	c.extensions().validate(&v)
+	if c.Serial != "" {
+		if _, err := ParseSerial(c.Serial); err != nil {
+			v.add("serial", err)
+		}
+	}
+	return v.err()
+}
+
+// Validate checks the certificate configuration and returns a *ValidationError naming every invalid field
+func (c CertConfig) Validate() error {
+	var v validator
+	v.checkSubject(c.subject(), c.SPIFFEID == "")
+	c.validity().validate(&v)
+
+	if _, err := ParseCertType(string(c.Type)); err != nil {
+		v.add("type", err)
+	}
+	c.validateUsages(&v)
+	c.validateSerial(&v)
+	c.extensions().validate(&v)
+
+	if c.Type.HasSANs() {
+		for i, s := range c.IPAddresses {
+			if _, err := parseIP(s); err != nil {
+				v.add(fmt.Sprintf("ipAddresses[%d]", i), err)
+			}
+		}
+	}
+	for i, s := range c.EmailAddresses {
+		if _, err := parseEmail(s); err != nil {
+			v.add(fmt.Sprintf("emailAddresses[%d]", i), err)
+		}
+	}
+	for i, s := range c.URIs {
+		if _, err := parseURI(s); err != nil {
+			v.add(fmt.Sprintf("uris[%d]", i), err)
+		}
+	}
+	if c.SPIFFEID != "" {
+		if _, err := ParseSPIFFEID(c.SPIFFEID); err != nil {
+			v.add("spiffeId", err)
+		}
+		if len(c.URIs) > 0 {
+			v.add("uris", fmt.Errorf("%w: SPIFFE certificates must not contain additional URIs", ErrInvalidSAN))
+		}
+	}
+
+	return v.err()
+}
+
+// checkSubject validates the distinguished name attributes, attributing errors
+// in DN-provided values to the dn field
+func (v *validator) checkSubject(s subject, requireCommonName bool) {
+	if s.DN != "" {
+		if _, err := ParseDN(s.DN); err != nil {
+			v.add("dn", err)
+			return
+		}
+	}
+
+	for i, attr := range s.ExtraAttributes {
+		if _, err := parseExtraAttribute(attr); err != nil {
+			v.add(fmt.Sprintf("extraAttributes[%d].oid", i), err)
+		}
+	}
+
+	name, err := s.name()
+	if err != nil {
+		// Only reachable through extra attributes, which were reported above
+		return
+	}
+
+	if requireCommonName && name.CommonName == "" {
+		v.add("commonName", errors.New("is required"))
+	}
+
+	for _, country := range name.Country {
+		if !isCountryCode(country) {
+			field := "country"
+			if s.Country != country {
+				field = "dn"
+			}
+			v.addf(field, "%q is not an ISO 3166-1 alpha-2 country code", country)
+		}
+	}
+}
+
+// isoCountryCodes lists the officially assigned ISO 3166-1 alpha-2 codes
+const isoCountryCodes = "" +
+	"AD AE AF AG AI AL AM AO AQ AR AS AT AU AW AX AZ BA BB BD BE BF BG BH BI BJ BL BM BN BO BQ BR BS " +
+	"BT BV BW BY BZ CA CC CD CF CG CH CI CK CL CM CN CO CR CU CV CW CX CY CZ DE DJ DK DM DO DZ EC EE " +
+	"EG EH ER ES ET FI FJ FK FM FO FR GA GB GD GE GF GG GH GI GL GM GN GP GQ GR GS GT GU GW GY HK HM " +
+	"HN HR HT HU ID IE IL IM IN IO IQ IR IS IT JE JM JO JP KE KG KH KI KM KN KP KR KW KY KZ LA LB LC " +
+	"LI LK LR LS LT LU LV LY MA MC MD ME MF MG MH MK ML MM MN MO MP MQ MR MS MT MU MV MW MX MY MZ NA " +
+	"NC NE NF NG NI NL NO NP NR NU NZ OM PA PE PF PG PH PK PL PM PN PR PS PT PW PY QA RE RO RS RU RW " +
+	"SA SB SC SD SE SG SH SI SJ SK SL SM SN SO SR SS ST SV SX SY SZ TC TD TF TG TH TJ TK TL TM TN TO " +
+	"TR TT TV TW TZ UA UG UM US UY UZ VA VC VE VG VI VN VU WF WS YE YT ZA ZM ZW"
+
+var countryCodes = func() map[string]bool {
+	codes := make(map[string]bool)
+	for _, code := range strings.Fields(isoCountryCodes) {
+		codes[code] = true
+	}
+	return codes
+}()
+
+// isCountryCode reports whether s is an officially assigned ISO 3166-1 alpha-2 code
+func isCountryCode(s string) bool {
+	return countryCodes[s]
+}
@@ -0,0 +1,142 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+)
+
+func TestCAConfigValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     CAConfig
+		wantFields []string
+	}{
+		{
+			name:   "valid config",
+			config: CAConfig{CommonName: "Test CA", Country: "DE", ExpiryDays: 365},
+		},
+		{
+			name:   "empty optional attributes",
+			config: CAConfig{CommonName: "Test CA", ExpiryDays: 1},
+		},
+		{
+			name:       "non-ISO country and negative expiry",
+			config:     CAConfig{CommonName: "Test CA", Country: "UK", ExpiryDays: -1},
+			wantFields: []string{"country", "expiryDays"},
+		},
+		{
+			name:       "lower-case country",
+			config:     CAConfig{CommonName: "Test CA", Country: "us", ExpiryDays: 365},
+			wantFields: []string{"country"},
+		},
+		{
+			name:       "country from DN",
+			config:     CAConfig{DN: "CN=Test CA,C=XX", ExpiryDays: 365},
+			wantFields: []string{"dn"},
+		},
+		{
+			name:       "missing common name",
+			config:     CAConfig{Organization: "Test Org", ExpiryDays: 365},
+			wantFields: []string{"commonName"},
+		},
+		{
+			name:       "invalid extra attribute OID",
+			config:     CAConfig{CommonName: "Test CA", ExpiryDays: 365, ExtraAttributes: []Attribute{{OID: "foo", Value: "bar"}}},
+			wantFields: []string{"extraAttributes[0].oid"},
+		},
+		{
+			name: "extra attributes overriding subject fields",
+			config: CAConfig{CommonName: "Test CA", Country: "DE", ExpiryDays: 365, ExtraAttributes: []Attribute{
+				{OID: "2.5.4.10", Value: "Evil Corp"}, {OID: "2.5.4.6", Value: "Germany"}, {OID: "2.5.4.3", Value: "www.example.com"},
+			}},
+			wantFields: []string{"extraAttributes[0].oid", "extraAttributes[1].oid", "extraAttributes[2].oid"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertFieldErrors(t, tt.config.Validate(), tt.wantFields)
+		})
+	}
+}
+
+func TestCertConfigValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     CertConfig
+		wantFields []string
+	}{
+		{
+			name:   "valid server config",
+			config: CertConfig{CommonName: "server", ExpiryDays: 365, IPAddresses: []string{"10.0.0.1"}},
+		},
+		{
+			name:   "SPIFFE without common name",
+			config: CertConfig{SPIFFEID: "spiffe://example.org/web", ExpiryDays: 365},
+		},
+		{
+			name:       "unknown type",
+			config:     CertConfig{CommonName: "x", ExpiryDays: 365, Type: "bogus"},
+			wantFields: []string{"type"},
+		},
+		{
+			name: "invalid SANs",
+			config: CertConfig{
+				CommonName:     "x",
+				ExpiryDays:     365,
+				IPAddresses:    []string{"10.0.0.1", "nope"},
+				EmailAddresses: []string{"not-an-email"},
+			},
+			wantFields: []string{"ipAddresses[1]", "emailAddresses[0]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertFieldErrors(t, tt.config.Validate(), tt.wantFields)
+		})
+	}
+}
+
+func TestGenerateCAOmitsEmptyAttributes(t *testing.T) {
+	bundle, err := GenerateCA(CAConfig{CommonName: "Test CA", ExpiryDays: 365})
+	if err != nil {
+		t.Fatalf("GenerateCA() error = %v", err)
+	}
+
+	block, _ := pem.Decode(bundle.CertPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+
+	if len(cert.Subject.Names) != 1 {
+		t.Errorf("Expected only the CN attribute in the subject, got %v", cert.Subject.Names)
+	}
+}
+
+func assertFieldErrors(t *testing.T, err error, wantFields []string) {
+	t.Helper()
+
+	if len(wantFields) == 0 {
+		if err != nil {
+			t.Errorf("Validate() unexpected error = %v", err)
+		}
+		return
+	}
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Validate() error = %v, want *ValidationError", err)
+	}
+	if len(validationErr.Fields) != len(wantFields) {
+		t.Fatalf("Validate() returned %d field errors (%v), want %d", len(validationErr.Fields), err, len(wantFields))
+	}
+	for i, field := range wantFields {
+		if validationErr.Fields[i].Field != field {
+			t.Errorf("Field error %d is for %q, want %q", i, validationErr.Fields[i].Field, field)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package certificate
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultBackdate is how far NotBefore is moved into the past to tolerate clock skew
+// between the issuing machine and the systems validating the certificate
+const DefaultBackdate = 5 * time.Minute
+
+// maxDays is the longest validity in days that fits into a time.Duration
+const maxDays = math.MaxInt64 / int64(24*time.Hour)
+
+// validity collects the validity period settings shared by CAConfig and CertConfig
+type validity struct {
+	ExpiryDays int
+	Duration   string
+	NotBefore  time.Time
+	NotAfter   time.Time
+	Backdate   time.Duration
+}
+
+// window computes the NotBefore/NotAfter pair relative to now. An explicit NotAfter wins over
+// Duration, which wins over ExpiryDays. Backdating only moves NotBefore, so the certificate
+// still expires the full duration after now.
+func (v validity) window(now time.Time) (time.Time, time.Time, error) {
+	start := now
+	if !v.NotBefore.IsZero() {
+		start = v.NotBefore
+	}
+
+	notBefore := start
+	if v.NotBefore.IsZero() {
+		backdate := v.Backdate
+		if backdate == 0 {
+			backdate = DefaultBackdate
+		}
+		if backdate > 0 {
+			notBefore = now.Add(-backdate)
+		}
+	}
+
+	notAfter := v.NotAfter
+	if notAfter.IsZero() {
+		d, err := v.duration()
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		notAfter = start.Add(d)
+	}
+
+	if !notAfter.After(notBefore) {
+		return time.Time{}, time.Time{}, errors.New("notAfter must be after notBefore")
+	}
+
+	return notBefore, notAfter, nil
+}
+
+// duration returns the configured validity duration
+func (v validity) duration() (time.Duration, error) {
+	if v.Duration != "" {
+		return ParseValidity(v.Duration)
+	}
+	if v.ExpiryDays <= 0 {
+		return 0, fmt.Errorf("must be greater than zero, got %d", v.ExpiryDays)
+	}
+	if int64(v.ExpiryDays) > maxDays {
+		return 0, fmt.Errorf("must be at most %d, got %d", maxDays, v.ExpiryDays)
+	}
+	return time.Duration(v.ExpiryDays) * 24 * time.Hour, nil
+}
+
+// validate reports invalid validity settings to the validator
+func (v validity) validate(val *validator) {
+	if v.NotAfter.IsZero() {
+		if _, err := v.duration(); err != nil {
+			field := "expiryDays"
+			if v.Duration != "" {
+				field = "validity"
+			}
+			val.add(field, err)
+			return
+		}
+	}
+
+	if _, _, err := v.window(time.Now()); err != nil {
+		val.add("notAfter", err)
+	}
+}
+
+// ParseValidity parses a validity duration such as "15m", "6h", "1h30m" or "90d".
+// In addition to the units understood by time.ParseDuration, a whole number of days
+// may be given with the "d" suffix.
+func ParseValidity(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+
+	var d time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid validity %q", s)
+		}
+		if int64(n) > maxDays {
+			return 0, fmt.Errorf("validity %q exceeds the maximum of %dd", s, maxDays)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		parsed, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid validity %q", s)
+		}
+		d = parsed
+	}
+
+	if d <= 0 {
+		return 0, fmt.Errorf("validity %q must be positive", s)
+	}
+	return d, nil
+}
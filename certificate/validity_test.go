@@ -0,0 +1,119 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseValidity(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{input: "15m", want: 15 * time.Minute},
+		{input: "6h", want: 6 * time.Hour},
+		{input: "1h30m", want: 90 * time.Minute},
+		{input: "90d", want: 90 * 24 * time.Hour},
+		{input: "0s", wantErr: true},
+		{input: "-1h", wantErr: true},
+		{input: "1.5d", wantErr: true},
+		{input: "soon", wantErr: true},
+		{input: "106751d", want: 106751 * 24 * time.Hour},
+		{input: "106752d", wantErr: true},
+		{input: "213504d", wantErr: true},
+		{input: "9223372036854775807d", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseValidity(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseValidity(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseValidity(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateCAValidity(t *testing.T) {
+	notBefore := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		config        CAConfig
+		wantNotBefore time.Duration // relative to now, ignored for explicit windows
+		wantNotAfter  time.Duration
+		explicit      bool
+	}{
+		{
+			name:          "short duration with default backdate",
+			config:        CAConfig{CommonName: "CA", Validity: "15m"},
+			wantNotBefore: -DefaultBackdate,
+			wantNotAfter:  15 * time.Minute,
+		},
+		{
+			name:          "custom backdate",
+			config:        CAConfig{CommonName: "CA", ExpiryDays: 1, Backdate: time.Hour},
+			wantNotBefore: -time.Hour,
+			wantNotAfter:  24 * time.Hour,
+		},
+		{
+			name:         "backdating disabled",
+			config:       CAConfig{CommonName: "CA", Validity: "6h", Backdate: -1},
+			wantNotAfter: 6 * time.Hour,
+		},
+		{
+			name:     "explicit window",
+			config:   CAConfig{CommonName: "CA", NotBefore: notBefore, NotAfter: notAfter},
+			explicit: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			bundle, err := GenerateCA(tt.config)
+			if err != nil {
+				t.Fatalf("GenerateCA() error = %v", err)
+			}
+
+			block, _ := pem.Decode(bundle.CertPEM)
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				t.Fatalf("Failed to parse certificate: %v", err)
+			}
+
+			if tt.explicit {
+				if !cert.NotBefore.Equal(notBefore) || !cert.NotAfter.Equal(notAfter) {
+					t.Errorf("Expected window %v - %v, got %v - %v", notBefore, notAfter, cert.NotBefore, cert.NotAfter)
+				}
+				return
+			}
+			if cert.NotBefore.Sub(now.Add(tt.wantNotBefore)).Abs() > time.Minute {
+				t.Errorf("NotBefore %v differs by more than 1 minute from expected", cert.NotBefore)
+			}
+			if cert.NotAfter.Sub(now.Add(tt.wantNotAfter)).Abs() > time.Minute {
+				t.Errorf("NotAfter %v differs by more than 1 minute from expected", cert.NotAfter)
+			}
+		})
+	}
+}
+
+func TestValidityValidate(t *testing.T) {
+	now := time.Now()
+
+	assertFieldErrors(t, CAConfig{CommonName: "CA", Validity: "forever"}.Validate(), []string{"validity"})
+	assertFieldErrors(t, CAConfig{CommonName: "CA", NotBefore: now, NotAfter: now.Add(-time.Hour)}.Validate(), []string{"notAfter"})
+	assertFieldErrors(t, CAConfig{CommonName: "CA", NotAfter: now.Add(time.Hour)}.Validate(), nil)
+	assertFieldErrors(t, CAConfig{CommonName: "CA", ExpiryDays: math.MaxInt64}.Validate(), []string{"expiryDays"})
+
+	// 213504 days would wrap around to about 25 minutes and slip under a policy's maximum
+	_, err := GenerateCertWithPolicy(CertConfig{CommonName: "app", Validity: "213504d", Type: CertTypeServer}, &Policy{MaxValidity: "30d"}, nil, nil)
+	assertFieldErrors(t, err, []string{"validity"})
+}
@@ -0,0 +1,274 @@
+// Package client is a typed Go client for the certgen HTTP API, as described by the
+// OpenAPI document the server publishes at /openapi.json.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client calls a certgen server
+type Client struct {
+	// BaseURL is the server address, e.g. "http://localhost:8080"
+	BaseURL string
+	// HTTPClient is used for requests, http.DefaultClient when nil
+	HTTPClient *http.Client
+	// Token is sent as a bearer token when set, an API token or an OIDC token
+	Token string
+}
+
+// NewClient creates a client for the certgen server at baseURL
+func NewClient(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// GenerateCA generates a CA certificate and private key
+func (c *Client) GenerateCA(ctx context.Context, req FormData) (*CAResponse, error) {
+	var resp CAResponse
+	if err := c.postJSON(ctx, "/api/v1/ca", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GenerateCert generates a client, server or peer certificate signed by the CA in req
+func (c *Client) GenerateCert(ctx context.Context, req CertRequest) (*CertResponse, error) {
+	var resp CertResponse
+	if err := c.postJSON(ctx, "/api/v1/cert", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GenerateCAZip generates a CA certificate and returns the ZIP download
+func (c *Client) GenerateCAZip(ctx context.Context, req FormData) ([]byte, error) {
+	return c.postZip(ctx, "/api/v1/ca", req)
+}
+
+// GenerateCertZip generates a certificate and returns the ZIP download
+func (c *Client) GenerateCertZip(ctx context.Context, req CertRequest) ([]byte, error) {
+	return c.postZip(ctx, "/api/v1/cert", req)
+}
+
+// Inspect summarizes a PEM encoded certificate
+func (c *Client) Inspect(ctx context.Context, certPEM string) (*CertInfo, error) {
+	var info CertInfo
+	if err := c.postJSON(ctx, "/api/v1/inspect", map[string]string{"certificate": certPEM}, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// JWKS converts PEM encoded certificates into a JSON Web Key Set
+func (c *Client) JWKS(ctx context.Context, certsPEM string) (*JWKS, error) {
+	var jwks JWKS
+	if err := c.do(ctx, http.MethodPost, "/jwks", "application/x-pem-file", strings.NewReader(certsPEM), "application/jwk-set+json", &jwks); err != nil {
+		return nil, err
+	}
+	return &jwks, nil
+}
+
+// GenerateBrokenZip signs a set of deliberately invalid certificates, one per defect in
+// req.Defects (all when empty), and returns the ZIP download
+func (c *Client) GenerateBrokenZip(ctx context.Context, req CertRequest) ([]byte, error) {
+	return c.postMultipart(ctx, "/generate/broken", map[string]string{"caCert": req.CACert, "caKey": req.CAKey}, req.FormData)
+}
+
+// GenerateSSHCAZip generates an SSH CA key pair and returns the ZIP download
+func (c *Client) GenerateSSHCAZip(ctx context.Context, req SSHFormData) ([]byte, error) {
+	return c.postZip(ctx, "/generate/ssh/ca", req)
+}
+
+// SignSSHCertZip signs an SSH user or host certificate with the CA key in req and returns
+// the ZIP download
+func (c *Client) SignSSHCertZip(ctx context.Context, req SSHCertRequest) ([]byte, error) {
+	return c.postMultipart(ctx, "/generate/ssh/cert", map[string]string{"caKey": req.CAKey}, req.SSHFormData)
+}
+
+// RandomData returns random form data for kind "ca", "server" or "client"
+func (c *Client) RandomData(ctx context.Context, kind string) (*RandomData, error) {
+	var data RandomData
+	if err := c.do(ctx, http.MethodGet, "/gen/random/"+url.PathEscape(kind), "", nil, "application/json", &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// Audit returns the audit events matching q, oldest first
+func (c *Client) Audit(ctx context.Context, q AuditQuery) ([]AuditEvent, error) {
+	params := url.Values{}
+	for name, value := range map[string]string{"action": q.Action, "user": q.User, "ca": q.CA} {
+		if value != "" {
+			params.Set(name, value)
+		}
+	}
+	if !q.Since.IsZero() {
+		params.Set("since", q.Since.Format(time.RFC3339))
+	}
+	if !q.Until.IsZero() {
+		params.Set("until", q.Until.Format(time.RFC3339))
+	}
+	if q.Limit > 0 {
+		params.Set("limit", strconv.Itoa(q.Limit))
+	}
+
+	path := "/api/v1/audit"
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+	var resp struct {
+		Events []AuditEvent `json:"events"`
+	}
+	if err := c.do(ctx, http.MethodGet, path, "", nil, "application/json", &resp); err != nil {
+		return nil, err
+	}
+	return resp.Events, nil
+}
+
+// Health checks that the server process is alive
+func (c *Client) Health(ctx context.Context) error {
+	var body []byte
+	return c.do(ctx, http.MethodGet, "/healthz", "", nil, "text/plain", &body)
+}
+
+// Ready checks that the server can serve requests. A failed check is returned as *Error
+// with status 503, whose message lists the checks.
+func (c *Client) Ready(ctx context.Context) (*ReadyResponse, error) {
+	var resp ReadyResponse
+	if err := c.do(ctx, http.MethodGet, "/readyz", "", nil, "application/json", &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Metrics returns the server metrics in the Prometheus text exposition format
+func (c *Client) Metrics(ctx context.Context) ([]byte, error) {
+	var metrics []byte
+	if err := c.do(ctx, http.MethodGet, "/metrics", "", nil, "text/plain", &metrics); err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
+
+// OpenAPI returns the OpenAPI document describing the server's endpoints
+func (c *Client) OpenAPI(ctx context.Context) ([]byte, error) {
+	var doc []byte
+	if err := c.do(ctx, http.MethodGet, "/openapi.json", "", nil, "application/json", &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// postJSON sends body as JSON and decodes the JSON response into v
+func (c *Client) postJSON(ctx context.Context, path string, body, v any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	return c.do(ctx, http.MethodPost, path, "application/json", bytes.NewReader(data), "application/json", v)
+}
+
+// postZip sends body as JSON and returns the ZIP response
+func (c *Client) postZip(ctx context.Context, path string, body any) ([]byte, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	var zip []byte
+	if err := c.do(ctx, http.MethodPost, path, "application/json", bytes.NewReader(data), "application/zip", &zip); err != nil {
+		return nil, err
+	}
+	return zip, nil
+}
+
+// postMultipart uploads the files and formData, encoded as JSON, as a multipart form and
+// returns the ZIP response, like the web UI does
+func (c *Client) postMultipart(ctx context.Context, path string, files map[string]string, formData any) ([]byte, error) {
+	data, err := json.Marshal(formData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	body := new(bytes.Buffer)
+	form := multipart.NewWriter(body)
+	for name, content := range files {
+		part, err := form.CreateFormFile(name, name+".pem")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+		if _, err := io.WriteString(part, content); err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+	if err := form.WriteField("formData", string(data)); err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	if err := form.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	var zip []byte
+	if err := c.do(ctx, http.MethodPost, path, form.FormDataContentType(), body, "application/zip", &zip); err != nil {
+		return nil, err
+	}
+	return zip, nil
+}
+
+// do sends the request and decodes the response into v, or copies it when v is a *[]byte.
+// Non-2xx responses are returned as *Error.
+func (c *Client) do(ctx context.Context, method, path, contentType string, body io.Reader, accept string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", accept)
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &Error{Status: resp.StatusCode}
+		// The /api/v1 endpoints answer with a JSON error, the others with plain text
+		if json.Unmarshal(data, apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = string(data)
+		}
+		apiErr.Status = resp.StatusCode
+		return apiErr
+	}
+
+	if raw, ok := v.(*[]byte); ok {
+		*raw = data
+		return nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
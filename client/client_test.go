@@ -0,0 +1,245 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pvormste/certgen/assets"
+)
+
+// notCovered lists the documented operations the client leaves out on purpose
+var notCovered = map[string]string{
+	"index":           "web UI page",
+	"static":          "web UI assets",
+	"mcp":             "MCP endpoint, for MCP clients",
+	"generateCAZip":   "web UI variant of POST /api/v1/ca, which GenerateCAZip uses",
+	"generateCertZip": "web UI variant of POST /api/v1/cert, which GenerateCertZip uses",
+	"inspectPEM":      "web UI variant of POST /api/v1/inspect, which Inspect uses",
+}
+
+type specParameter struct {
+	Name string `json:"name"`
+	In   string `json:"in"`
+}
+
+type specOperation struct {
+	OperationID string          `json:"operationId"`
+	Parameters  []specParameter `json:"parameters"`
+	RequestBody struct {
+		Content map[string]struct {
+			Schema struct {
+				Required []string `json:"required"`
+			} `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Content map[string]json.RawMessage `json:"content"`
+	} `json:"responses"`
+}
+
+type specDocument struct {
+	Paths      map[string]map[string]specOperation `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func loadSpec(t *testing.T) specDocument {
+	t.Helper()
+	var doc specDocument
+	if err := json.Unmarshal(assets.OpenAPISpec, &doc); err != nil {
+		t.Fatalf("OpenAPI document is not valid JSON: %v", err)
+	}
+	return doc
+}
+
+// TestClientMatchesSpec calls every client method against a server that checks each request
+// against the OpenAPI document, and checks that every documented operation is called
+func TestClientMatchesSpec(t *testing.T) {
+	doc := loadSpec(t)
+	called := map[string]bool{}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op, ok := doc.Paths[r.URL.EscapedPath()][strings.ToLower(r.Method)]
+		if !ok {
+			t.Errorf("%s %s is not documented", r.Method, r.URL.EscapedPath())
+			http.NotFound(w, r)
+			return
+		}
+		called[op.OperationID] = true
+
+		for name := range r.URL.Query() {
+			if !slices.Contains(op.Parameters, specParameter{Name: name, In: "query"}) {
+				t.Errorf("%s: query parameter %s is not documented", op.OperationID, name)
+			}
+		}
+
+		if len(op.RequestBody.Content) > 0 {
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			content, ok := op.RequestBody.Content[mediaType]
+			if err != nil || !ok {
+				t.Errorf("%s: request content type %q is not documented", op.OperationID, r.Header.Get("Content-Type"))
+			}
+			if mediaType == "multipart/form-data" {
+				if err := r.ParseMultipartForm(1 << 20); err != nil {
+					t.Errorf("%s: invalid multipart form: %v", op.OperationID, err)
+				}
+				for _, field := range content.Schema.Required {
+					if r.MultipartForm.Value[field] == nil && r.MultipartForm.File[field] == nil {
+						t.Errorf("%s: required form field %s is missing", op.OperationID, field)
+					}
+				}
+			}
+		}
+
+		status, accept := 0, r.Header.Get("Accept")
+		for code, response := range op.Responses {
+			if _, ok := response.Content[accept]; ok && strings.HasPrefix(code, "2") {
+				status, _ = strconv.Atoi(code)
+			}
+		}
+		if status == 0 {
+			t.Errorf("%s: no success response of type %s is documented", op.OperationID, accept)
+		}
+
+		w.Header().Set("Content-Type", accept)
+		w.WriteHeader(status)
+		if strings.Contains(accept, "json") {
+			w.Write([]byte("{}"))
+		}
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	c := NewClient(ts.URL)
+	calls := map[string]func() error{
+		"GenerateCA":        func() error { _, err := c.GenerateCA(ctx, FormData{}); return err },
+		"GenerateCAZip":     func() error { _, err := c.GenerateCAZip(ctx, FormData{}); return err },
+		"GenerateCert":      func() error { _, err := c.GenerateCert(ctx, CertRequest{}); return err },
+		"GenerateCertZip":   func() error { _, err := c.GenerateCertZip(ctx, CertRequest{}); return err },
+		"GenerateBrokenZip": func() error { _, err := c.GenerateBrokenZip(ctx, CertRequest{}); return err },
+		"GenerateSSHCAZip":  func() error { _, err := c.GenerateSSHCAZip(ctx, SSHFormData{}); return err },
+		"SignSSHCertZip":    func() error { _, err := c.SignSSHCertZip(ctx, SSHCertRequest{}); return err },
+		"Inspect":           func() error { _, err := c.Inspect(ctx, ""); return err },
+		"JWKS":              func() error { _, err := c.JWKS(ctx, ""); return err },
+		"RandomData ca":     func() error { _, err := c.RandomData(ctx, "ca"); return err },
+		"RandomData server": func() error { _, err := c.RandomData(ctx, "server"); return err },
+		"RandomData client": func() error { _, err := c.RandomData(ctx, "client"); return err },
+		"Audit": func() error {
+			_, err := c.Audit(ctx, AuditQuery{Action: "download", User: "u", CA: "ca", Limit: 1})
+			return err
+		},
+		"Health":  func() error { return c.Health(ctx) },
+		"Ready":   func() error { _, err := c.Ready(ctx); return err },
+		"Metrics": func() error { _, err := c.Metrics(ctx); return err },
+		"OpenAPI": func() error { _, err := c.OpenAPI(ctx); return err },
+	}
+	for name, call := range calls {
+		if err := call(); err != nil {
+			t.Errorf("%s() error = %v", name, err)
+		}
+	}
+
+	for _, operations := range doc.Paths {
+		for _, op := range operations {
+			_, skipped := notCovered[op.OperationID]
+			if !called[op.OperationID] && !skipped {
+				t.Errorf("Operation %s is not covered by the client", op.OperationID)
+			}
+			if called[op.OperationID] && skipped {
+				t.Errorf("Operation %s is covered, remove it from notCovered", op.OperationID)
+			}
+		}
+	}
+}
+
+func TestRandomDataEscapesKind(t *testing.T) {
+	var path string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.EscapedPath()
+		w.Write([]byte("{}"))
+	}))
+	defer ts.Close()
+
+	if _, err := NewClient(ts.URL).RandomData(context.Background(), "../api/v1/audit"); err != nil {
+		t.Fatalf("RandomData() error = %v", err)
+	}
+	if path != "/gen/random/..%2Fapi%2Fv1%2Faudit" {
+		t.Errorf("Requested %s, want the kind as a single escaped path segment", path)
+	}
+}
+
+func TestClientSchemas(t *testing.T) {
+	doc := loadSpec(t)
+
+	schemas := map[string]any{
+		"Attribute":         Attribute{},
+		"NameConstraints":   NameConstraints{},
+		"Extension":         Extension{},
+		"CertificatePolicy": CertificatePolicy{},
+		"FormData":          FormData{},
+		"SSHFormData":       SSHFormData{},
+		"JWK":               JWK{},
+		"JWKS":              JWKS{},
+		"CAResponse":        CAResponse{},
+		"CertResponse":      CertResponse{},
+		"ExtensionInfo":     ExtensionInfo{},
+		"CertInfo":          CertInfo{},
+		"APIFieldError":     FieldError{},
+		"APIError":          Error{},
+		"RandomData":        RandomData{},
+		"AuditEvent":        AuditEvent{},
+		"ReadyResponse":     ReadyResponse{},
+	}
+	// omitted lists the documented properties the client leaves out on purpose
+	omitted := map[string][]string{
+		"FormData": {"isClient"}, // legacy alias of certType "client"
+	}
+
+	for name, v := range schemas {
+		schema, ok := doc.Components.Schemas[name]
+		if !ok {
+			t.Errorf("Schema %s is not documented", name)
+			continue
+		}
+		var properties []string
+		for property := range schema.Properties {
+			if !slices.Contains(omitted[name], property) {
+				properties = append(properties, property)
+			}
+		}
+		slices.Sort(properties)
+
+		if want := jsonFields(reflect.TypeOf(v)); !slices.Equal(properties, want) {
+			t.Errorf("Schema %s has properties %v, the client type %v", name, properties, want)
+		}
+	}
+}
+
+// jsonFields returns the sorted JSON property names of a struct, including embedded structs
+func jsonFields(typ reflect.Type) []string {
+	var names []string
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Anonymous {
+			names = append(names, jsonFields(field.Type)...)
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,269 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Attribute is an additional subject attribute identified by its dotted OID
+type Attribute struct {
+	OID   string `json:"oid"`
+	Value string `json:"value"`
+}
+
+// NameConstraints restricts the names a CA may issue certificates for
+type NameConstraints struct {
+	PermittedDNSDomains     []string `json:"permittedDnsDomains,omitempty"`
+	ExcludedDNSDomains      []string `json:"excludedDnsDomains,omitempty"`
+	PermittedIPRanges       []string `json:"permittedIpRanges,omitempty"`
+	ExcludedIPRanges        []string `json:"excludedIpRanges,omitempty"`
+	PermittedEmailAddresses []string `json:"permittedEmailAddresses,omitempty"`
+	ExcludedEmailAddresses  []string `json:"excludedEmailAddresses,omitempty"`
+	PermittedURIDomains     []string `json:"permittedUriDomains,omitempty"`
+	ExcludedURIDomains      []string `json:"excludedUriDomains,omitempty"`
+}
+
+// Extension is a custom X.509 extension. Type selects how Value is encoded: der (hex DER,
+// the default), utf8, ia5, printable, bmp, octets, integer, boolean, null or oid.
+type Extension struct {
+	OID      string `json:"oid"`
+	Critical bool   `json:"critical,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Value    string `json:"value,omitempty"`
+}
+
+// CertificatePolicy is a certificate policy OID with optional CPS URIs
+type CertificatePolicy struct {
+	OID     string   `json:"oid"`
+	CPSURIs []string `json:"cpsUris,omitempty"`
+}
+
+// FormData holds the certificate fields accepted by the CA and certificate endpoints
+type FormData struct {
+	Organization string `json:"organization,omitempty"`
+	CommonName   string `json:"commonName,omitempty"`
+	Country      string `json:"country,omitempty"`
+	Locality     string `json:"locality,omitempty"`
+	ExpiryDays   int    `json:"expiryDays,omitempty"`
+	// Validity (e.g. "15m", "6h", "90d") and an explicit NotBefore/NotAfter window override ExpiryDays
+	Validity  string     `json:"validity,omitempty"`
+	NotBefore *time.Time `json:"notBefore,omitempty"`
+	NotAfter  *time.Time `json:"notAfter,omitempty"`
+	// CertType is "server", "client" or "peer"
+	CertType       string   `json:"certType,omitempty"`
+	DNSNames       []string `json:"dnsNames,omitempty"`
+	IPAddresses    []string `json:"ipAddresses,omitempty"`
+	URIs           []string `json:"uris,omitempty"`
+	EmailAddresses []string `json:"emailAddresses,omitempty"`
+	SPIFFEID       string   `json:"spiffeId,omitempty"`
+
+	OrganizationalUnit string      `json:"organizationalUnit,omitempty"`
+	Province           string      `json:"province,omitempty"`
+	StreetAddress      string      `json:"streetAddress,omitempty"`
+	PostalCode         string      `json:"postalCode,omitempty"`
+	SerialNumber       string      `json:"serialNumber,omitempty"`
+	ExtraAttributes    []Attribute `json:"extraAttributes,omitempty"`
+	DN                 string      `json:"dn,omitempty"`
+
+	// NameConstraints only applies to CA certificates
+	NameConstraints *NameConstraints `json:"nameConstraints,omitempty"`
+
+	KeyUsage    []string `json:"keyUsage,omitempty"`
+	ExtKeyUsage []string `json:"extKeyUsage,omitempty"`
+
+	// SerialStrategy is "random", "sequential" or "specified"
+	SerialStrategy string `json:"serialStrategy,omitempty"`
+	Serial         string `json:"serial,omitempty"`
+
+	Extensions          []Extension         `json:"extensions,omitempty"`
+	CertificatePolicies []CertificatePolicy `json:"certificatePolicies,omitempty"`
+	MustStaple          bool                `json:"mustStaple,omitempty"`
+
+	// Defects selects the deliberate flaws of GenerateBrokenZip, each at most once; all when empty
+	Defects []string `json:"defects,omitempty"`
+}
+
+// CertRequest is a certificate request signed by the PEM encoded CA
+type CertRequest struct {
+	FormData
+	CACert string `json:"caCert"`
+	CAKey  string `json:"caKey"`
+}
+
+// SSHFormData holds the fields of SSH CA generation and certificate signing
+type SSHFormData struct {
+	// Comment is stored in the generated public key
+	Comment string `json:"comment,omitempty"`
+	// HostPatterns restricts the known_hosts line of a new CA
+	HostPatterns []string `json:"hostPatterns,omitempty"`
+
+	// CertType is "user" or "host"
+	CertType   string   `json:"certType,omitempty"`
+	KeyID      string   `json:"keyId,omitempty"`
+	Principals []string `json:"principals,omitempty"`
+	// Validity (e.g. "8h", "30d") and an explicit ValidAfter/ValidBefore window
+	Validity        string            `json:"validity,omitempty"`
+	ValidAfter      *time.Time        `json:"validAfter,omitempty"`
+	ValidBefore     *time.Time        `json:"validBefore,omitempty"`
+	CriticalOptions map[string]string `json:"criticalOptions,omitempty"`
+	// Extensions of user certificates: the ssh-keygen defaults when nil, none when empty
+	Extensions map[string]string `json:"extensions"`
+	Serial     uint64            `json:"serial,omitempty"`
+	// PublicKey is an authorized_keys encoded key to certify; a key pair is generated when empty
+	PublicKey string `json:"publicKey,omitempty"`
+}
+
+// SSHCertRequest is an SSH certificate request signed by the OpenSSH encoded CA private key
+type SSHCertRequest struct {
+	SSHFormData
+	CAKey string `json:"-"`
+}
+
+// JWK is a JSON Web Key with the certificate chain in X5C
+type JWK struct {
+	Kty     string   `json:"kty"`
+	Crv     string   `json:"crv,omitempty"`
+	X       string   `json:"x,omitempty"`
+	Y       string   `json:"y,omitempty"`
+	D       string   `json:"d,omitempty"`
+	N       string   `json:"n,omitempty"`
+	E       string   `json:"e,omitempty"`
+	Kid     string   `json:"kid"`
+	Use     string   `json:"use"`
+	Alg     string   `json:"alg"`
+	X5C     []string `json:"x5c"`
+	X5TS256 string   `json:"x5t#S256"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []*JWK `json:"keys"`
+}
+
+// CAResponse is a generated CA certificate and private key
+type CAResponse struct {
+	Certificate string `json:"certificate"`
+	PrivateKey  string `json:"privateKey"`
+	PrivateJWK  *JWK   `json:"privateJwk"`
+	PublicJWKS  *JWKS  `json:"publicJwks"`
+}
+
+// CertResponse is a generated client, server or peer certificate
+type CertResponse struct {
+	Certificate  string `json:"certificate"`
+	PrivateKey   string `json:"privateKey"`
+	UnifiedPEM   string `json:"unifiedPEM"`
+	ChainPEM     string `json:"chainPEM"`
+	FullChainPEM string `json:"fullChainPEM"`
+	PrivateJWK   *JWK   `json:"privateJwk"`
+	PublicJWKS   *JWKS  `json:"publicJwks"`
+}
+
+// ExtensionInfo describes a single extension of an inspected certificate
+type ExtensionInfo struct {
+	OID      string `json:"oid"`
+	Name     string `json:"name,omitempty"`
+	Critical bool   `json:"critical"`
+	// Value is the hex encoded DER value of the extension
+	Value string `json:"value"`
+}
+
+// CertInfo is the summary of an inspected certificate
+type CertInfo struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serialNumber"`
+	NotBefore    time.Time `json:"notBefore"`
+	NotAfter     time.Time `json:"notAfter"`
+	IsCA         bool      `json:"isCa"`
+	KeyUsage     []string  `json:"keyUsage,omitempty"`
+	ExtKeyUsage  []string  `json:"extKeyUsage,omitempty"`
+	SubjectKeyID string    `json:"subjectKeyId,omitempty"`
+	// PublicKeyFingerprint is the SHA-256 of the public key, which identifies a CA in policies
+	PublicKeyFingerprint string              `json:"publicKeyFingerprint"`
+	AuthorityKeyID       string              `json:"authorityKeyId,omitempty"`
+	DNSNames             []string            `json:"dnsNames,omitempty"`
+	IPAddresses          []string            `json:"ipAddresses,omitempty"`
+	URIs                 []string            `json:"uris,omitempty"`
+	EmailAddresses       []string            `json:"emailAddresses,omitempty"`
+	CertificatePolicies  []CertificatePolicy `json:"certificatePolicies,omitempty"`
+	MustStaple           bool                `json:"mustStaple"`
+	Extensions           []ExtensionInfo     `json:"extensions"`
+}
+
+// RandomData is random form data for the web UI's "Random" buttons
+type RandomData struct {
+	Organization string   `json:"organization"`
+	CommonName   string   `json:"commonName"`
+	Country      string   `json:"country"`
+	Locality     string   `json:"locality"`
+	ExpiryDays   int      `json:"expiryDays"`
+	DNSNames     []string `json:"dnsNames,omitempty"`
+	IPAddresses  []string `json:"ipAddresses,omitempty"`
+}
+
+// ReadyResponse is the result of the server's readiness checks
+type ReadyResponse struct {
+	Status string `json:"status"`
+	// Checks maps every check to "ok" or its error
+	Checks map[string]string `json:"checks"`
+}
+
+// AuditQuery selects audit events; zero fields match every event
+type AuditQuery struct {
+	Action string
+	User   string
+	// CA is the fingerprint of a CA, matching events of the CA and certificates it signed
+	CA    string
+	Since time.Time
+	Until time.Time
+	// Limit returns only the most recent events, 100 when zero
+	Limit int
+}
+
+// AuditEvent is a single entry of the server's audit log
+type AuditEvent struct {
+	Seq         uint64     `json:"seq"`
+	Time        time.Time  `json:"time"`
+	Action      string     `json:"action"`
+	Source      string     `json:"source"`
+	User        string     `json:"user,omitempty"`
+	AuthMethod  string     `json:"authMethod,omitempty"`
+	RemoteAddr  string     `json:"remoteAddr,omitempty"`
+	Operation   string     `json:"operation,omitempty"`
+	CA          string     `json:"ca,omitempty"`
+	Fingerprint string     `json:"fingerprint,omitempty"`
+	Subject     string     `json:"subject,omitempty"`
+	Serial      string     `json:"serial,omitempty"`
+	Names       []string   `json:"names,omitempty"`
+	NotAfter    *time.Time `json:"notAfter,omitempty"`
+	Profile     string     `json:"profile,omitempty"`
+	Defect      string     `json:"defect,omitempty"`
+	File        string     `json:"file,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	Repeated    int        `json:"repeated,omitempty"`
+	PrevHash    string     `json:"prevHash,omitempty"`
+	Hash        string     `json:"hash,omitempty"`
+}
+
+// FieldError describes a single invalid field of a rejected request
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is returned for every non-successful response. Fields lists the invalid fields of
+// a rejected configuration.
+type Error struct {
+	Status  int          `json:"status"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("certgen: HTTP %d", e.Status)
+	}
+	return fmt.Sprintf("certgen: HTTP %d: %s", e.Status, strings.TrimSpace(e.Message))
+}
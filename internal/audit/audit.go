@@ -0,0 +1,453 @@
+// Package audit records who created CAs, issued certificates and downloaded keys in an
+// append-only JSON lines file. Every event carries the SHA-256 hash of its predecessor, so
+// edited, removed or reordered lines are detected when the log is opened or queried.
+package audit
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pvormste/certgen/certificate"
+	"github.com/pvormste/certgen/internal/auth"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// ErrTampered is returned when the hash chain of the log is broken
+var ErrTampered = errors.New("audit log hash chain is broken")
+
+// Action is the kind of an audited event
+type Action string
+
+const (
+	ActionCreateCA     Action = "ca.create"
+	ActionIssue        Action = "cert.issue"
+	ActionCreateSSHCA  Action = "ssh-ca.create"
+	ActionSignSSH      Action = "ssh-cert.sign"
+	ActionDownload     Action = "download"
+	ActionAuthFailure  Action = "auth.failure"
+	ActionAccessDenied Action = "auth.denied"
+)
+
+const (
+	// SourceHTTP marks events of the web UI and the REST API
+	SourceHTTP = "http"
+	// SourceMCP marks events of MCP tool calls
+	SourceMCP = "mcp"
+)
+
+// Event is a single line of the audit log
+type Event struct {
+	Seq    uint64    `json:"seq"`
+	Time   time.Time `json:"time"`
+	Action Action    `json:"action"`
+	Source string    `json:"source"`
+	// User and AuthMethod identify the caller when authentication is enabled
+	User       string `json:"user,omitempty"`
+	AuthMethod string `json:"authMethod,omitempty"`
+	RemoteAddr string `json:"remoteAddr,omitempty"`
+	// Operation is the request path or the MCP tool name
+	Operation string `json:"operation,omitempty"`
+
+	// CA is the fingerprint of the signing CA, Fingerprint that of the created CA or issued
+	// certificate (SHA-256 hex for X.509, "SHA256:..." for SSH keys)
+	CA          string     `json:"ca,omitempty"`
+	Fingerprint string     `json:"fingerprint,omitempty"`
+	Subject     string     `json:"subject,omitempty"`
+	Serial      string     `json:"serial,omitempty"`
+	Names       []string   `json:"names,omitempty"`
+	NotAfter    *time.Time `json:"notAfter,omitempty"`
+	// Profile is the certificate type, e.g. server or user; Defect the flaw of a broken certificate
+	Profile string `json:"profile,omitempty"`
+	Defect  string `json:"defect,omitempty"`
+	// File is the name of a downloaded archive
+	File  string `json:"file,omitempty"`
+	Error string `json:"error,omitempty"`
+	// Repeated counts the failures of the same client that were left out before this one
+	Repeated int `json:"repeated,omitempty"`
+
+	// PrevHash is the Hash of the preceding event, empty for the first one
+	PrevHash string `json:"prevHash,omitempty"`
+	// Hash is the hex SHA-256 of the event's JSON encoding without Hash
+	Hash string `json:"hash,omitempty"`
+}
+
+// CertificateEvent describes the PEM encoded certificate, signed by the CA certificate
+// caCertPEM unless it is a new CA. Unparsable certificates leave the details empty.
+func CertificateEvent(action Action, certPEM, caCertPEM []byte) Event {
+	event := Event{Action: action}
+	if len(caCertPEM) > 0 {
+		event.CA, _ = certificate.Fingerprint(caCertPEM)
+	}
+
+	info, err := certificate.Inspect(certPEM)
+	if err != nil {
+		return event
+	}
+	event.Fingerprint, _ = certificate.Fingerprint(certPEM)
+	event.Subject = info.Subject
+	event.Serial = info.SerialNumber
+	event.NotAfter = &info.NotAfter
+	for _, names := range [][]string{info.DNSNames, info.IPAddresses, info.URIs, info.EmailAddresses} {
+		event.Names = append(event.Names, names...)
+	}
+	return event
+}
+
+// SSHCertificateEvent describes an authorized_keys encoded SSH certificate signed by the CA
+// with the fingerprint. An unparsable certificate leaves the details empty.
+func SSHCertificateEvent(cert []byte, caFingerprint string) Event {
+	event := Event{Action: ActionSignSSH, CA: caFingerprint}
+
+	pub, _, _, _, err := gossh.ParseAuthorizedKey(cert)
+	if err != nil {
+		return event
+	}
+	sshCert, ok := pub.(*gossh.Certificate)
+	if !ok {
+		return event
+	}
+	event.Fingerprint = gossh.FingerprintSHA256(sshCert.Key)
+	event.Subject = sshCert.KeyId
+	event.Serial = strconv.FormatUint(sshCert.Serial, 10)
+	event.Names = sshCert.ValidPrincipals
+	if sshCert.ValidBefore != gossh.CertTimeInfinity {
+		notAfter := time.Unix(int64(sshCert.ValidBefore), 0).UTC()
+		event.NotAfter = &notAfter
+	}
+	event.Profile = "user"
+	if sshCert.CertType == gossh.HostCert {
+		event.Profile = "host"
+	}
+	return event
+}
+
+// AuthEvent describes a request rejected by auth.Authenticator: a failed authentication for
+// auth.ErrUnauthenticated and a denied permission otherwise
+func AuthEvent(err error) Event {
+	action := ActionAccessDenied
+	if errors.Is(err, auth.ErrUnauthenticated) {
+		action = ActionAuthFailure
+	}
+	return Event{Action: action, Error: err.Error()}
+}
+
+type remoteAddrKey struct{}
+
+// WithRemoteAddr returns a context carrying the client address, recorded for events that
+// do not set RemoteAddr themselves
+func WithRemoteAddr(ctx context.Context, addr string) context.Context {
+	return context.WithValue(ctx, remoteAddrKey{}, addr)
+}
+
+// RemoteAddrFromContext returns the client address stored by WithRemoteAddr, or ""
+func RemoteAddrFromContext(ctx context.Context) string {
+	addr, _ := ctx.Value(remoteAddrKey{}).(string)
+	return addr
+}
+
+type operationKey struct{}
+
+type operation struct {
+	source string
+	name   string
+}
+
+// WithOperation returns a context carrying the source (SourceHTTP or SourceMCP) and the
+// operation (the request path or MCP tool name), recorded for events that do not set them
+func WithOperation(ctx context.Context, source, name string) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation{source: source, name: name})
+}
+
+// Log is an audit log file. A nil *Log records nothing.
+type Log struct {
+	mu   sync.Mutex
+	file logFile
+	path string
+	seq  uint64
+	last string
+	// size is the length of the complete events in the file
+	size int64
+	// broken is set when a failed write could not be undone; no further events are written
+	broken error
+}
+
+// logFile is the part of *os.File a Log writes to
+type logFile interface {
+	io.WriteCloser
+	Sync() error
+	Truncate(size int64) error
+	Stat() (os.FileInfo, error)
+}
+
+// Open opens the audit log at path, creating it if needed. The existing events are verified
+// so new events continue an intact chain.
+func Open(path string) (*Log, error) {
+	l := &Log{path: path}
+
+	f, err := os.Open(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	default:
+		last, err := readEvents(f, func(Event) {})
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		l.seq, l.last = last.Seq, last.Hash
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	l.file, l.size = file, info.Size()
+	return l, nil
+}
+
+// Close closes the log file
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// Check reports whether the log file is still in place, for readiness probes
+func (l *Log) Check() error {
+	if l == nil {
+		return nil
+	}
+	opened, err := l.file.Stat()
+	if err != nil {
+		return fmt.Errorf("audit log: %w", err)
+	}
+	current, err := os.Stat(l.path)
+	if err != nil {
+		return fmt.Errorf("audit log: %w", err)
+	}
+	if !os.SameFile(opened, current) {
+		return fmt.Errorf("audit log %s was moved or replaced; restart the server to write to the new file", l.path)
+	}
+	return nil
+}
+
+// Record appends the event to the log. Time defaults to now; the user, authentication method,
+// remote address, source and operation default to those stored in ctx.
+func (l *Log) Record(ctx context.Context, event Event) error {
+	if l == nil {
+		return nil
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	if p := auth.PrincipalFromContext(ctx); p != nil && event.User == "" {
+		event.User = p.Name
+		event.AuthMethod = string(p.Method)
+	}
+	if event.RemoteAddr == "" {
+		event.RemoteAddr = RemoteAddrFromContext(ctx)
+	}
+	if op, ok := ctx.Value(operationKey{}).(operation); ok {
+		if event.Source == "" {
+			event.Source = op.source
+		}
+		if event.Operation == "" {
+			event.Operation = op.name
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.broken != nil {
+		return fmt.Errorf("audit log is unusable after a failed write: %w", l.broken)
+	}
+
+	event.Seq = l.seq + 1
+	event.PrevHash = l.last
+	event.Hash = ""
+	hash, err := hashEvent(event)
+	if err != nil {
+		return err
+	}
+	event.Hash = hash
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit event: %w", err)
+	}
+	line = append(line, '\n')
+	if _, err := l.file.Write(line); err != nil {
+		return l.rollback(fmt.Errorf("failed to write audit event: %w", err))
+	}
+	// Events must survive a crash right after the certificate was handed out
+	if err := l.file.Sync(); err != nil {
+		return l.rollback(fmt.Errorf("failed to write audit event: %w", err))
+	}
+
+	l.seq, l.last = event.Seq, event.Hash
+	l.size += int64(len(line))
+	return nil
+}
+
+// rollback truncates the file to its complete events after a failed write, so later events
+// do not follow a partial line and break the chain. When that fails too, the log refuses
+// further writes. l.mu must be held.
+func (l *Log) rollback(err error) error {
+	if truncErr := l.file.Truncate(l.size); truncErr != nil {
+		l.broken = errors.Join(err, truncErr)
+		return l.broken
+	}
+	return err
+}
+
+// Filter selects events in Query. Zero fields match every event.
+type Filter struct {
+	Action Action
+	User   string
+	// CA matches both the signing CA and the fingerprint of created CAs; hex fingerprints
+	// may be given in any case and with colons, as printed by openssl
+	CA    string
+	Since time.Time
+	Until time.Time
+	// Limit returns only the most recent matching events
+	Limit int
+}
+
+// Query verifies the whole log and returns the matching events, oldest first. A broken
+// chain is reported as ErrTampered.
+func (l *Log) Query(filter Filter) ([]Event, error) {
+	if l == nil {
+		return nil, errors.New("audit log is not enabled")
+	}
+
+	// Only the events complete when the query starts are read, so Record can append while
+	// the file is scanned without the lock
+	l.mu.Lock()
+	size := l.size
+	l.mu.Unlock()
+
+	f, err := os.Open(l.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer f.Close()
+
+	if !strings.HasPrefix(filter.CA, "SHA256:") {
+		filter.CA = strings.ToLower(strings.ReplaceAll(filter.CA, ":", ""))
+	}
+
+	// With a limit, only the most recent matches are kept in a ring buffer, so memory does
+	// not grow with the log
+	var events []Event
+	var matched int
+	_, err = readEvents(io.LimitReader(f, size), func(event Event) {
+		if !filter.matches(event) {
+			return
+		}
+		if filter.Limit > 0 && len(events) == filter.Limit {
+			events[matched%filter.Limit] = event
+		} else {
+			events = append(events, event)
+		}
+		matched++
+	})
+	if err != nil {
+		return nil, err
+	}
+	if filter.Limit > 0 && matched > filter.Limit {
+		// Rotate the ring buffer so the oldest kept event comes first
+		start := matched % filter.Limit
+		events = slices.Concat(events[start:], events[:start])
+	}
+	return events, nil
+}
+
+func (f Filter) matches(event Event) bool {
+	switch {
+	case f.Action != "" && event.Action != f.Action:
+		return false
+	case f.User != "" && event.User != f.User:
+		return false
+	case f.CA != "" && event.CA != f.CA && !(event.CA == "" && event.Fingerprint == f.CA):
+		return false
+	case !f.Since.IsZero() && event.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && event.Time.After(f.Until):
+		return false
+	}
+	return true
+}
+
+// Verify checks the hash chain of a log and returns the number of events
+func Verify(r io.Reader) (int, error) {
+	var n int
+	_, err := readEvents(r, func(Event) { n++ })
+	return n, err
+}
+
+// readEvents verifies and decodes the events of a log, calling fn for each, and returns the last one
+func readEvents(r io.Reader, fn func(Event)) (Event, error) {
+	var last Event
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return last, fmt.Errorf("%w: line %d: %v", ErrTampered, line, err)
+		}
+
+		hash := event.Hash
+		event.Hash = ""
+		computed, err := hashEvent(event)
+		if err != nil {
+			return last, err
+		}
+		switch {
+		case event.Seq != last.Seq+1:
+			return last, fmt.Errorf("%w: line %d: sequence number %d follows %d", ErrTampered, line, event.Seq, last.Seq)
+		case event.PrevHash != last.Hash:
+			return last, fmt.Errorf("%w: line %d: previous hash does not match", ErrTampered, line)
+		case hash != computed:
+			return last, fmt.Errorf("%w: line %d: hash does not match the event", ErrTampered, line)
+		}
+		event.Hash = hash
+
+		fn(event)
+		last = event
+	}
+	if err := scanner.Err(); err != nil {
+		return last, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return last, nil
+}
+
+// hashEvent returns the hex SHA-256 of the event's JSON encoding, which excludes the empty Hash
+func hashEvent(event Event) (string, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode audit event: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,305 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/pvormste/certgen/certgentest"
+	"github.com/pvormste/certgen/internal/auth"
+)
+
+func TestLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	pki := certgentest.New(t)
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Name: "alice", Method: auth.MethodToken})
+	ctx = WithRemoteAddr(ctx, "192.0.2.1:4242")
+	issued := CertificateEvent(ActionIssue, pki.Server.CertPEM, pki.CA.CertPEM)
+	issued.Source = SourceMCP
+	events := []Event{
+		{Action: ActionAuthFailure, Source: SourceHTTP, Error: "authentication required"},
+		issued,
+		{Action: ActionDownload, Source: SourceHTTP, File: "server-certificate.zip"},
+	}
+	if err := l.Record(context.Background(), events[0]); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	for _, event := range events[1:] {
+		if err := l.Record(ctx, event); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+	l.Close()
+
+	// Reopening continues the chain
+	l, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open() of an existing log error = %v", err)
+	}
+	defer l.Close()
+	if err := l.Record(ctx, Event{Action: ActionCreateCA, Source: SourceHTTP}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	all, err := l.Query(Filter{})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(all) != 4 || all[3].Seq != 4 || all[3].PrevHash != all[2].Hash {
+		t.Fatalf("Query() = %+v, want 4 chained events", all)
+	}
+	if all[1].User != "alice" || all[1].AuthMethod != "token" || all[1].RemoteAddr != "192.0.2.1:4242" {
+		t.Errorf("Caller was not taken from the context: %+v", all[1])
+	}
+	if all[1].Subject != "CN=localhost" || all[1].Serial == "" || all[1].CA == "" || len(all[1].Names) == 0 {
+		t.Errorf("Certificate details missing: %+v", all[1])
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []uint64
+	}{
+		{name: "action", filter: Filter{Action: ActionIssue}, want: []uint64{2}},
+		{name: "user", filter: Filter{User: "alice"}, want: []uint64{2, 3, 4}},
+		{name: "ca", filter: Filter{CA: all[1].CA}, want: []uint64{2}},
+		{name: "limit", filter: Filter{Limit: 2}, want: []uint64{3, 4}},
+		{name: "until", filter: Filter{Until: all[0].Time.Add(-time.Second)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := l.Query(tt.filter)
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			var seqs []uint64
+			for _, event := range events {
+				seqs = append(seqs, event.Seq)
+			}
+			if len(seqs) != len(tt.want) || (len(seqs) > 0 && seqs[0] != tt.want[0]) {
+				t.Errorf("Query() returned events %v, want %v", seqs, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryWhileRecording(t *testing.T) {
+	l, err := Open(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer l.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			if err := l.Record(context.Background(), Event{Action: ActionCreateCA, Source: SourceHTTP}); err != nil {
+				t.Errorf("Record() error = %v", err)
+				return
+			}
+		}
+	}()
+
+	// Queries only read complete events, however far Record got
+	for {
+		if _, err := l.Query(Filter{}); err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+		select {
+		case <-done:
+			return
+		default:
+		}
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	for _, user := range []string{"alice", "bob", "carol"} {
+		if err := l.Record(context.Background(), Event{Action: ActionCreateCA, Source: SourceHTTP, User: user}); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+	l.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := Verify(bytes.NewReader(data)); err != nil || n != 3 {
+		t.Fatalf("Verify() = %d, %v, want 3 events", n, err)
+	}
+
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	tampered := map[string][]byte{
+		"edited":  bytes.Replace(data, []byte(`"user":"bob"`), []byte(`"user":"eve"`), 1),
+		"removed": append(append([]byte{}, lines[0]...), lines[2]...),
+		"swapped": append(append(append([]byte{}, lines[1]...), lines[0]...), lines[2]...),
+	}
+	for name, data := range tampered {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Verify(bytes.NewReader(data)); !errors.Is(err, ErrTampered) {
+				t.Errorf("Verify() error = %v, want ErrTampered", err)
+			}
+			if err := os.WriteFile(path, data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Open(path); !errors.Is(err, ErrTampered) {
+				t.Errorf("Open() error = %v, want ErrTampered", err)
+			}
+		})
+	}
+}
+
+func TestAuthorizeRecordsDenials(t *testing.T) {
+	l, err := Open(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer l.Close()
+
+	a, err := auth.New(auth.Config{
+		ClientCertificates: true,
+		Roles:              map[string]auth.Role{"team": {Permissions: []auth.Permission{auth.PermissionIssue}, CAs: []string{"abcd"}}},
+	})
+	if err != nil {
+		t.Fatalf("auth.New() error = %v", err)
+	}
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Name: "cert:dev", Method: auth.MethodClientCertificate, Roles: []string{"team"}})
+	ctx = WithOperation(WithRemoteAddr(ctx, "192.0.2.7:1234"), SourceMCP, "generate_ca")
+
+	if err := Authorize(ctx, a, l, auth.PermissionIssue); err != nil {
+		t.Errorf("Authorize() error = %v", err)
+	}
+	if err := Authorize(ctx, a, l, auth.PermissionCreateCA); !errors.Is(err, auth.ErrForbidden) {
+		t.Errorf("Authorize() error = %v, want ErrForbidden", err)
+	}
+	if err := AuthorizeFingerprint(ctx, a, l, "AB:CD"); err != nil {
+		t.Errorf("AuthorizeFingerprint() error = %v", err)
+	}
+	if err := AuthorizeFingerprint(ctx, a, l, "0123"); !errors.Is(err, auth.ErrForbidden) {
+		t.Errorf("AuthorizeFingerprint() error = %v, want ErrForbidden", err)
+	}
+
+	events, err := l.Query(Filter{})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Got %d events, want the 2 denials: %+v", len(events), events)
+	}
+	for _, event := range events {
+		if event.Action != ActionAccessDenied || event.Source != SourceMCP || event.Operation != "generate_ca" || event.User != "cert:dev" || event.RemoteAddr != "192.0.2.7:1234" {
+			t.Errorf("Unexpected denial event: %+v", event)
+		}
+	}
+	if events[1].CA != "0123" {
+		t.Errorf("Denial event CA = %q, want 0123", events[1].CA)
+	}
+}
+
+// failingFile writes only half of the next event and fails
+type failingFile struct {
+	logFile
+	fail bool
+}
+
+func (f *failingFile) Write(p []byte) (int, error) {
+	if f.fail {
+		f.fail = false
+		n, _ := f.logFile.Write(p[:len(p)/2])
+		return n, errors.New("disk full")
+	}
+	return f.logFile.Write(p)
+}
+
+func TestRecordUndoesPartialWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	file := &failingFile{logFile: l.file}
+	l.file = file
+
+	if err := l.Record(context.Background(), Event{Action: ActionCreateCA, Source: SourceHTTP}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	file.fail = true
+	if err := l.Record(context.Background(), Event{Action: ActionIssue, Source: SourceHTTP}); err == nil {
+		t.Fatal("Record() succeeded despite a failed write")
+	}
+	if err := l.Record(context.Background(), Event{Action: ActionDownload, Source: SourceHTTP}); err != nil {
+		t.Fatalf("Record() after a failed write error = %v", err)
+	}
+	l.Close()
+
+	l, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open() after a failed write error = %v", err)
+	}
+	defer l.Close()
+	events, err := l.Query(Filter{})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(events) != 2 || events[1].Action != ActionDownload || events[1].Seq != 2 {
+		t.Errorf("Query() = %+v, want the two written events", events)
+	}
+}
+
+func TestQueryLimit(t *testing.T) {
+	l, err := Open(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer l.Close()
+	for i := 0; i < 8; i++ {
+		action := ActionIssue
+		if i%2 == 1 {
+			action = ActionDownload
+		}
+		if err := l.Record(context.Background(), Event{Action: action, Source: SourceHTTP}); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		filter Filter
+		want   []uint64
+	}{
+		{filter: Filter{Limit: 3}, want: []uint64{6, 7, 8}},
+		{filter: Filter{Action: ActionIssue, Limit: 3}, want: []uint64{3, 5, 7}},
+		{filter: Filter{Action: ActionIssue, Limit: 4}, want: []uint64{1, 3, 5, 7}},
+		{filter: Filter{Action: ActionDownload, Limit: 10}, want: []uint64{2, 4, 6, 8}},
+	}
+	for _, tt := range tests {
+		events, err := l.Query(tt.filter)
+		if err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+		var seqs []uint64
+		for _, event := range events {
+			seqs = append(seqs, event.Seq)
+		}
+		if !slices.Equal(seqs, tt.want) {
+			t.Errorf("Query(%+v) returned events %v, want %v", tt.filter, seqs, tt.want)
+		}
+	}
+}
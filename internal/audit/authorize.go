@@ -0,0 +1,46 @@
+package audit
+
+import (
+	"context"
+	"log"
+
+	"github.com/pvormste/certgen/certificate"
+	"github.com/pvormste/certgen/internal/auth"
+)
+
+// Authorize checks with a that the caller in ctx has the permission and records a denial in l.
+// The HTTP handlers and the MCP tools share it, so both audit denials alike.
+func Authorize(ctx context.Context, a *auth.Authenticator, l *Log, permission auth.Permission) error {
+	err := a.Authorize(ctx, permission)
+	if err != nil {
+		recordDenial(ctx, l, AuthEvent(err))
+	}
+	return err
+}
+
+// AuthorizeCA checks with a that the caller in ctx may issue certificates from the PEM encoded
+// CA certificate and records a denial in l
+func AuthorizeCA(ctx context.Context, a *auth.Authenticator, l *Log, caCertPEM []byte) error {
+	// An unparsable CA only matches roles allowing any CA; signing fails on it later anyway
+	fingerprint, _ := certificate.Fingerprint(caCertPEM)
+	return AuthorizeFingerprint(ctx, a, l, fingerprint)
+}
+
+// AuthorizeFingerprint checks with a that the caller in ctx may issue certificates from the CA
+// with the fingerprint (see auth.Role) and records a denial in l
+func AuthorizeFingerprint(ctx context.Context, a *auth.Authenticator, l *Log, fingerprint string) error {
+	err := a.AuthorizeCA(ctx, fingerprint)
+	if err != nil {
+		event := AuthEvent(err)
+		event.CA = fingerprint
+		recordDenial(ctx, l, event)
+	}
+	return err
+}
+
+// recordDenial records a denied request. Failures are only logged: the request is rejected anyway.
+func recordDenial(ctx context.Context, l *Log, event Event) {
+	if err := l.Record(ctx, event); err != nil {
+		log.Printf("Failed to write audit event: %v", err)
+	}
+}
@@ -0,0 +1,325 @@
+// Package auth authenticates requests to the certgen HTTP server and MCP endpoint and
+// authorizes them with role-based permissions
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+var (
+	// ErrUnauthenticated is returned when a request carries no valid credentials
+	ErrUnauthenticated = errors.New("authentication required")
+	// ErrForbidden is returned when the authenticated user lacks a permission
+	ErrForbidden = errors.New("permission denied")
+)
+
+// Permission is an action a role may perform
+type Permission string
+
+const (
+	// PermissionCreateCA allows generating X.509 and SSH CAs
+	PermissionCreateCA Permission = "create-ca"
+	// PermissionIssue allows signing certificates, including broken and SSH certificates, with
+	// the CAs listed in the role
+	PermissionIssue Permission = "issue"
+	// PermissionInspect allows inspecting certificates, converting them to JWKS and fetching random form data
+	PermissionInspect Permission = "inspect"
+	// PermissionAudit allows querying the audit log
+	PermissionAudit Permission = "audit"
+)
+
+// Method is how a principal authenticated
+type Method string
+
+const (
+	MethodToken             Method = "token"
+	MethodBasic             Method = "basic"
+	MethodClientCertificate Method = "client-certificate"
+	MethodOIDC              Method = "oidc"
+)
+
+// Role grants permissions. CAs lists the SHA-256 fingerprints of the X.509 CAs (see
+// certificate.Fingerprint) and SSH CAs ("SHA256:..." as printed by ssh-keygen -l) the role
+// may issue from; "*" allows any CA.
+type Role struct {
+	Permissions []Permission `json:"permissions"`
+	CAs         []string     `json:"cas,omitempty"`
+}
+
+// Token is a static API token, sent as "Authorization: Bearer <token>"
+type Token struct {
+	User string `json:"user"`
+	// SHA256 is the hex SHA-256 hash of the token, so the configuration holds no secrets
+	SHA256 string `json:"sha256"`
+}
+
+// OIDCConfig accepts ID or access tokens signed by an OpenID Connect provider as bearer tokens
+type OIDCConfig struct {
+	// Issuer is the provider URL; its discovery document names the JWKS used to verify tokens
+	Issuer string `json:"issuer"`
+	// Audience must be contained in the aud claim, usually the client ID
+	Audience string `json:"audience"`
+	// UsernameClaim names the user, "sub" by default
+	UsernameClaim string `json:"usernameClaim,omitempty"`
+	// RolesClaim optionally names a string array claim with role names
+	RolesClaim string `json:"rolesClaim,omitempty"`
+}
+
+// Config configures the enabled authentication methods, the roles and the users' roles
+type Config struct {
+	Tokens []Token `json:"tokens,omitempty"`
+	// HtpasswdFile enables HTTP basic authentication against bcrypt or {SHA} hashes
+	HtpasswdFile string `json:"htpasswdFile,omitempty"`
+	// ClientCertificates authenticates users by the Common Name of a verified TLS client certificate
+	ClientCertificates bool        `json:"clientCertificates,omitempty"`
+	OIDC               *OIDCConfig `json:"oidc,omitempty"`
+
+	Roles map[string]Role `json:"roles"`
+	// Users maps principal names to role names. Names are prefixed with the authentication
+	// method, so a certificate CN cannot claim the roles of a token user of the same name:
+	// "token:<user>", "basic:<user>", "cert:<common name>" and "oidc:<issuer>/<user>".
+	Users map[string][]string `json:"users"`
+}
+
+// LoadConfig reads a JSON encoded Config
+func LoadConfig(r io.Reader) (*Config, error) {
+	var config Config
+	if err := json.NewDecoder(r).Decode(&config); err != nil {
+		return nil, fmt.Errorf("failed to decode auth config: %w", err)
+	}
+	return &config, nil
+}
+
+// Principal is an authenticated user
+type Principal struct {
+	// Name is the user name prefixed with the authentication method, as in Config.Users
+	Name   string
+	Method Method
+	Roles  []string
+}
+
+// Authenticator authenticates requests and checks permissions. A nil Authenticator disables
+// authentication: every request is allowed.
+type Authenticator struct {
+	roles    map[string]Role
+	users    map[string][]string
+	tokens   []Token
+	htpasswd map[string]string
+	certs    bool
+	oidc     *oidcVerifier
+}
+
+// New validates the configuration and creates an Authenticator
+func New(config Config) (*Authenticator, error) {
+	a := &Authenticator{
+		roles: config.Roles,
+		users: config.Users,
+		certs: config.ClientCertificates,
+	}
+
+	for roleName, role := range config.Roles {
+		for _, permission := range role.Permissions {
+			switch permission {
+			case PermissionCreateCA, PermissionIssue, PermissionInspect, PermissionAudit:
+			default:
+				return nil, fmt.Errorf("role %s: unknown permission %q", roleName, permission)
+			}
+		}
+	}
+	for user, roleNames := range config.Users {
+		if !hasMethodPrefix(user) {
+			return nil, fmt.Errorf("user %s: prefix the name with the authentication method (token:, basic:, cert: or oidc:<issuer>/)", user)
+		}
+		for _, roleName := range roleNames {
+			if _, ok := config.Roles[roleName]; !ok {
+				return nil, fmt.Errorf("user %s: unknown role %q", user, roleName)
+			}
+		}
+	}
+
+	for i, token := range config.Tokens {
+		hash, err := hex.DecodeString(token.SHA256)
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("tokens[%d]: sha256 must be a hex encoded SHA-256 hash", i)
+		}
+		if token.User == "" {
+			return nil, fmt.Errorf("tokens[%d]: user is required", i)
+		}
+		a.tokens = append(a.tokens, Token{User: token.User, SHA256: strings.ToLower(token.SHA256)})
+	}
+
+	if config.HtpasswdFile != "" {
+		htpasswd, err := loadHtpasswd(config.HtpasswdFile)
+		if err != nil {
+			return nil, err
+		}
+		a.htpasswd = htpasswd
+	}
+
+	if config.OIDC != nil {
+		verifier, err := newOIDCVerifier(*config.OIDC)
+		if err != nil {
+			return nil, err
+		}
+		a.oidc = verifier
+	}
+
+	if len(a.tokens) == 0 && a.htpasswd == nil && !a.certs && a.oidc == nil {
+		return nil, errors.New("no authentication method configured")
+	}
+	return a, nil
+}
+
+// Authenticate identifies the user of a request by bearer token (static or OIDC), basic
+// credentials or, without any Authorization header, a verified client certificate
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+			return a.authenticateBearer(r.Context(), token)
+		}
+		if user, password, ok := r.BasicAuth(); ok && a.htpasswd != nil {
+			if !verifyHtpasswd(a.htpasswd[user], password) {
+				return nil, fmt.Errorf("%w: invalid user name or password", ErrUnauthenticated)
+			}
+			return a.principal(MethodBasic, user, nil), nil
+		}
+		return nil, fmt.Errorf("%w: unsupported authorization scheme", ErrUnauthenticated)
+	}
+
+	if a.certs && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		if cn := r.TLS.VerifiedChains[0][0].Subject.CommonName; cn != "" {
+			return a.principal(MethodClientCertificate, cn, nil), nil
+		}
+	}
+	return nil, ErrUnauthenticated
+}
+
+func (a *Authenticator) authenticateBearer(ctx context.Context, token string) (*Principal, error) {
+	hash := sha256.Sum256([]byte(token))
+	presented := hex.EncodeToString(hash[:])
+	for _, t := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(t.SHA256)) == 1 {
+			return a.principal(MethodToken, t.User, nil), nil
+		}
+	}
+
+	if a.oidc != nil && strings.Count(token, ".") == 2 {
+		user, roles, err := a.oidc.verify(ctx, token)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrUnauthenticated, err)
+		}
+		return a.principal(MethodOIDC, a.oidc.config.Issuer+"/"+user, roles), nil
+	}
+	return nil, fmt.Errorf("%w: invalid token", ErrUnauthenticated)
+}
+
+// methodPrefixes are the prefixes of principal names per authentication method
+var methodPrefixes = map[Method]string{
+	MethodToken:             "token:",
+	MethodBasic:             "basic:",
+	MethodClientCertificate: "cert:",
+	MethodOIDC:              "oidc:",
+}
+
+func hasMethodPrefix(name string) bool {
+	for _, prefix := range methodPrefixes {
+		if rest, ok := strings.CutPrefix(name, prefix); ok && rest != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// principal combines the configured roles of a user with roles asserted by the identity provider
+func (a *Authenticator) principal(method Method, user string, providerRoles []string) *Principal {
+	name := methodPrefixes[method] + user
+	roles := slices.Clone(a.users[name])
+	for _, role := range providerRoles {
+		if _, ok := a.roles[role]; ok && !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	return &Principal{Name: name, Method: method, Roles: roles}
+}
+
+// Challenge returns the WWW-Authenticate header for unauthenticated requests
+func (a *Authenticator) Challenge() string {
+	if a.htpasswd != nil {
+		return `Basic realm="certgen", charset="UTF-8"`
+	}
+	return `Bearer realm="certgen"`
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the authenticated principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal stored by WithPrincipal, or nil
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// Authorize checks that the principal in ctx has the permission
+func (a *Authenticator) Authorize(ctx context.Context, permission Permission) error {
+	if a == nil {
+		return nil
+	}
+	p := PrincipalFromContext(ctx)
+	if p == nil {
+		return ErrUnauthenticated
+	}
+	for _, roleName := range p.Roles {
+		if slices.Contains(a.roles[roleName].Permissions, permission) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s may not %s", ErrForbidden, p.Name, permission)
+}
+
+// AuthorizeCA checks that the principal in ctx may issue certificates from the CA with the
+// given fingerprint (see Role.CAs)
+func (a *Authenticator) AuthorizeCA(ctx context.Context, fingerprint string) error {
+	if a == nil {
+		return nil
+	}
+	p := PrincipalFromContext(ctx)
+	if p == nil {
+		return ErrUnauthenticated
+	}
+	fingerprint = normalizeFingerprint(fingerprint)
+	for _, roleName := range p.Roles {
+		role := a.roles[roleName]
+		if !slices.Contains(role.Permissions, PermissionIssue) {
+			continue
+		}
+		for _, ca := range role.CAs {
+			if ca == "*" || (fingerprint != "" && normalizeFingerprint(ca) == fingerprint) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: %s may not issue from this CA", ErrForbidden, p.Name)
+}
+
+// normalizeFingerprint lowercases hex fingerprints and strips colons, like certificate.LoadPolicySet.
+// SSH fingerprints ("SHA256:<base64>") are case sensitive and kept as is.
+func normalizeFingerprint(fingerprint string) string {
+	if strings.HasPrefix(fingerprint, "SHA256:") {
+		return fingerprint
+	}
+	return strings.ToLower(strings.ReplaceAll(fingerprint, ":", ""))
+}
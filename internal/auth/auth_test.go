@@ -0,0 +1,322 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pvormste/certgen/certgentest"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthenticate(t *testing.T) {
+	pki := certgentest.New(t)
+	oidc := certgentest.NewOIDCProvider(t)
+
+	tokenHash := sha256.Sum256([]byte("ci-secret"))
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("alice-password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shaHash := sha1.Sum([]byte("bob-password"))
+	htpasswdFile := filepath.Join(t.TempDir(), "users.htpasswd")
+	htpasswd := "# team\nalice:" + string(bcryptHash) + "\nbob:{SHA}" + base64.StdEncoding.EncodeToString(shaHash[:]) + "\n"
+	if err := os.WriteFile(htpasswdFile, []byte(htpasswd), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := New(Config{
+		Tokens:             []Token{{User: "ci", SHA256: hex.EncodeToString(tokenHash[:])}},
+		HtpasswdFile:       htpasswdFile,
+		ClientCertificates: true,
+		OIDC:               &OIDCConfig{Issuer: oidc.Issuer, Audience: "certgen", RolesClaim: "roles"},
+		Roles: map[string]Role{
+			"issuer": {Permissions: []Permission{PermissionIssue}, CAs: []string{"*"}},
+			"viewer": {Permissions: []Permission{PermissionInspect}},
+		},
+		Users: map[string][]string{"token:ci": {"issuer"}, "basic:alice": {"viewer"}, "cert:alice": {"issuer"}},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		setup      func(r *http.Request)
+		wantUser   string
+		wantMethod Method
+		wantRoles  []string
+	}{
+		{
+			name:       "static token",
+			setup:      func(r *http.Request) { r.Header.Set("Authorization", "Bearer ci-secret") },
+			wantUser:   "token:ci",
+			wantMethod: MethodToken,
+			wantRoles:  []string{"issuer"},
+		},
+		{
+			name:       "bcrypt password",
+			setup:      func(r *http.Request) { r.SetBasicAuth("alice", "alice-password") },
+			wantUser:   "basic:alice",
+			wantMethod: MethodBasic,
+			wantRoles:  []string{"viewer"},
+		},
+		{
+			name:       "SHA password",
+			setup:      func(r *http.Request) { r.SetBasicAuth("bob", "bob-password") },
+			wantUser:   "basic:bob",
+			wantMethod: MethodBasic,
+		},
+		{
+			name: "client certificate",
+			setup: func(r *http.Request) {
+				r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{pki.Client.Certificate, pki.CA.Certificate}}}
+			},
+			wantUser:   "cert:certgentest client",
+			wantMethod: MethodClientCertificate,
+		},
+		{
+			// The roles of cert:alice are not granted to an OIDC user named alice
+			name: "oidc token for a user configured for another method",
+			setup: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+oidc.Token(t, map[string]any{"sub": "alice", "aud": "certgen"}))
+			},
+			wantUser:   "oidc:" + oidc.Issuer + "/alice",
+			wantMethod: MethodOIDC,
+		},
+		{
+			name: "oidc token",
+			setup: func(r *http.Request) {
+				token := oidc.Token(t, map[string]any{"sub": "carol", "aud": []string{"certgen"}, "roles": []string{"issuer", "unknown"}})
+				r.Header.Set("Authorization", "Bearer "+token)
+			},
+			wantUser:   "oidc:" + oidc.Issuer + "/carol",
+			wantMethod: MethodOIDC,
+			wantRoles:  []string{"issuer"},
+		},
+		{name: "wrong token", setup: func(r *http.Request) { r.Header.Set("Authorization", "Bearer nope") }},
+		{name: "wrong password", setup: func(r *http.Request) { r.SetBasicAuth("alice", "bob-password") }},
+		{name: "unknown user", setup: func(r *http.Request) { r.SetBasicAuth("mallory", "") }},
+		{name: "no credentials", setup: func(r *http.Request) {}},
+		{
+			name: "oidc token for another audience",
+			setup: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+oidc.Token(t, map[string]any{"sub": "carol", "aud": "other"}))
+			},
+		},
+		{
+			name: "expired oidc token",
+			setup: func(r *http.Request) {
+				token := oidc.Token(t, map[string]any{"sub": "carol", "aud": "certgen", "exp": time.Now().Add(-time.Hour).Unix()})
+				r.Header.Set("Authorization", "Bearer "+token)
+			},
+		},
+		{
+			name: "tampered oidc token",
+			setup: func(r *http.Request) {
+				token := oidc.Token(t, map[string]any{"sub": "carol", "aud": "certgen"})
+				header, _, _ := strings.Cut(token, ".")
+				_, signature, _ := strings.Cut(token[len(header)+1:], ".")
+				claims := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin","aud":"certgen","exp":9999999999}`))
+				r.Header.Set("Authorization", "Bearer "+header+"."+claims+"."+signature)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			tt.setup(r)
+
+			p, err := a.Authenticate(r)
+			if tt.wantUser == "" {
+				if !errors.Is(err, ErrUnauthenticated) {
+					t.Errorf("Authenticate() = %+v, %v, want ErrUnauthenticated", p, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate() error = %v", err)
+			}
+			if p.Name != tt.wantUser || p.Method != tt.wantMethod || strings.Join(p.Roles, ",") != strings.Join(tt.wantRoles, ",") {
+				t.Errorf("Authenticate() = %+v, want %s via %s with roles %v", p, tt.wantUser, tt.wantMethod, tt.wantRoles)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	a, err := New(Config{
+		ClientCertificates: true,
+		Roles: map[string]Role{
+			"admin":  {Permissions: []Permission{PermissionCreateCA, PermissionIssue, PermissionInspect}, CAs: []string{"*"}},
+			"team-a": {Permissions: []Permission{PermissionIssue}, CAs: []string{"AB:CD:EF", "SHA256:sshCAkey"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	admin := WithPrincipal(context.Background(), &Principal{Name: "root", Roles: []string{"admin"}})
+	teamA := WithPrincipal(context.Background(), &Principal{Name: "dev", Roles: []string{"team-a"}})
+
+	if err := a.Authorize(admin, PermissionCreateCA); err != nil {
+		t.Errorf("Authorize(admin, create-ca) error = %v", err)
+	}
+	if err := a.Authorize(teamA, PermissionCreateCA); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Authorize(team-a, create-ca) error = %v, want ErrForbidden", err)
+	}
+	if err := a.Authorize(context.Background(), PermissionInspect); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Authorize() without principal error = %v, want ErrUnauthenticated", err)
+	}
+
+	if err := a.AuthorizeCA(admin, "0123"); err != nil {
+		t.Errorf("AuthorizeCA(admin) error = %v", err)
+	}
+	if err := a.AuthorizeCA(teamA, "abcdef"); err != nil {
+		t.Errorf("AuthorizeCA(team-a, own CA) error = %v", err)
+	}
+	if err := a.AuthorizeCA(teamA, "SHA256:sshCAkey"); err != nil {
+		t.Errorf("AuthorizeCA(team-a, own SSH CA) error = %v", err)
+	}
+	if err := a.AuthorizeCA(teamA, "0123"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("AuthorizeCA(team-a, other CA) error = %v, want ErrForbidden", err)
+	}
+
+	var disabled *Authenticator
+	if err := disabled.Authorize(context.Background(), PermissionCreateCA); err != nil {
+		t.Errorf("nil Authenticator denied a request: %v", err)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{name: "no method", config: Config{}},
+		{name: "unknown role", config: Config{ClientCertificates: true, Users: map[string][]string{"cert:alice": {"admin"}}}},
+		{name: "user without method", config: Config{ClientCertificates: true, Roles: map[string]Role{"admin": {}}, Users: map[string][]string{"alice": {"admin"}}}},
+		{name: "unknown permission", config: Config{ClientCertificates: true, Roles: map[string]Role{"admin": {Permissions: []Permission{"revoke"}}}}},
+		{name: "plain token", config: Config{Tokens: []Token{{User: "ci", SHA256: "secret"}}}},
+		{name: "missing htpasswd", config: Config{HtpasswdFile: "missing.htpasswd"}},
+		{name: "oidc without audience", config: Config{OIDC: &OIDCConfig{Issuer: "https://idp.test"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.config); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestOIDCRefreshDoesNotBlockKnownKeys(t *testing.T) {
+	release := make(chan struct{})
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte(`{"keys":[]}`))
+	}))
+	defer provider.Close()
+	defer close(release)
+
+	v, err := newOIDCVerifier(OIDCConfig{Issuer: provider.URL, Audience: "certgen"})
+	if err != nil {
+		t.Fatalf("newOIDCVerifier() error = %v", err)
+	}
+	v.jwksURI = provider.URL + "/jwks"
+	v.keys = map[string]crypto.PublicKey{"known": nil}
+
+	go v.key(context.Background(), "unknown")
+	for {
+		v.mu.Lock()
+		refreshing := v.refreshing != nil
+		v.mu.Unlock()
+		if refreshing {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := v.key(context.Background(), "known")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("key() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("key() for a known key waited for the JWKS refresh")
+	}
+}
+
+func TestOIDCRefreshOutlivesRequest(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		<-release
+		w.Write([]byte(`{"keys":[]}`))
+	}))
+	defer provider.Close()
+
+	v, err := newOIDCVerifier(OIDCConfig{Issuer: provider.URL, Audience: "certgen"})
+	if err != nil {
+		t.Fatalf("newOIDCVerifier() error = %v", err)
+	}
+	v.jwksURI = provider.URL + "/jwks"
+
+	if _, err := v.key(context.Background(), "unknown"); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("key() error = %v, want the failed fetch", err)
+	}
+
+	// The failed fetch must not delay the next refresh
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := v.key(ctx, "unknown")
+		done <- err
+	}()
+	for requests.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("key() error = %v, want context.Canceled", err)
+	}
+
+	// The refresh completes although the request that started it is gone
+	close(release)
+	for {
+		v.mu.Lock()
+		refreshing, refreshed, refreshErr := v.refreshing != nil, v.refreshed, v.refreshErr
+		v.mu.Unlock()
+		if !refreshing {
+			if refreshErr != nil || refreshed.IsZero() {
+				t.Errorf("refresh finished with error %v at %v, want a successful refresh", refreshErr, refreshed)
+			}
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
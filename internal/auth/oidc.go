@@ -0,0 +1,293 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pvormste/certgen/certificate"
+)
+
+const (
+	// oidcLeeway tolerates clock skew between certgen and the identity provider
+	oidcLeeway = time.Minute
+	// oidcRefreshInterval limits how often unknown key IDs trigger a JWKS refresh
+	oidcRefreshInterval = 30 * time.Second
+	// oidcFetchTimeout bounds a JWKS refresh, including the discovery of the JWKS URI
+	oidcFetchTimeout = 10 * time.Second
+)
+
+// oidcVerifier verifies JWTs issued by an OpenID Connect provider against the keys it publishes
+type oidcVerifier struct {
+	config OIDCConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	jwksURI   string
+	keys      map[string]crypto.PublicKey
+	refreshed time.Time
+	// refreshErr is the error of the last JWKS refresh, if it failed
+	refreshErr error
+	// refreshing is closed when the running JWKS refresh finishes; nil when none runs
+	refreshing chan struct{}
+}
+
+func newOIDCVerifier(config OIDCConfig) (*oidcVerifier, error) {
+	issuer, err := url.Parse(config.Issuer)
+	if err != nil || (issuer.Scheme != "https" && issuer.Scheme != "http") || issuer.Host == "" {
+		return nil, fmt.Errorf("oidc: issuer must be an http(s) URL")
+	}
+	if config.Audience == "" {
+		return nil, fmt.Errorf("oidc: audience is required")
+	}
+	if config.UsernameClaim == "" {
+		config.UsernameClaim = "sub"
+	}
+	return &oidcVerifier{config: config, client: &http.Client{Timeout: oidcFetchTimeout}}, nil
+}
+
+// verify checks the signature and claims of a JWT and returns the user name and the roles from RolesClaim
+func (v *oidcVerifier) verify(ctx context.Context, token string) (string, []string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return "", nil, fmt.Errorf("malformed token header: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, errors.New("malformed token signature")
+	}
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return "", nil, err
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return "", nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+	if iss, _ := claims["iss"].(string); iss != v.config.Issuer {
+		return "", nil, fmt.Errorf("unexpected issuer %q", iss)
+	}
+	if !hasAudience(claims["aud"], v.config.Audience) {
+		return "", nil, errors.New("token is not intended for this audience")
+	}
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.After(time.Unix(int64(exp), 0).Add(oidcLeeway)) {
+		return "", nil, errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(oidcLeeway).Before(time.Unix(int64(nbf), 0)) {
+		return "", nil, errors.New("token not yet valid")
+	}
+
+	user, _ := claims[v.config.UsernameClaim].(string)
+	if user == "" {
+		return "", nil, fmt.Errorf("token has no %s claim", v.config.UsernameClaim)
+	}
+	var roles []string
+	if v.config.RolesClaim != "" {
+		values, _ := claims[v.config.RolesClaim].([]any)
+		for _, value := range values {
+			if role, ok := value.(string); ok {
+				roles = append(roles, role)
+			}
+		}
+	}
+	return user, roles, nil
+}
+
+// key returns the provider key with the ID, refreshing the JWKS when the key is unknown. The
+// provider is fetched without holding v.mu, so a slow provider does not block requests with
+// known keys; concurrent requests for unknown keys wait for the running refresh. The refresh
+// does not depend on the context of the request that started it, so a cancelled request
+// neither aborts it for the others waiting nor spends the refresh interval on a failed fetch.
+func (v *oidcVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	if key, ok := v.keys[kid]; ok {
+		v.mu.Unlock()
+		return key, nil
+	}
+	refreshing := v.refreshing
+	if refreshing == nil {
+		if time.Since(v.refreshed) < oidcRefreshInterval {
+			v.mu.Unlock()
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		refreshing = make(chan struct{})
+		v.refreshing = refreshing
+		go v.refresh(context.WithoutCancel(ctx), v.jwksURI, refreshing)
+	}
+	v.mu.Unlock()
+
+	select {
+	case <-refreshing:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	return v.knownKey(kid)
+}
+
+// refresh fetches the JWKS within oidcFetchTimeout and closes done when finished. Only a
+// successful fetch starts a new refresh interval.
+func (v *oidcVerifier) refresh(ctx context.Context, jwksURI string, done chan struct{}) {
+	ctx, cancel := context.WithTimeout(ctx, oidcFetchTimeout)
+	defer cancel()
+	keys, jwksURI, err := v.fetchKeys(ctx, jwksURI)
+
+	v.mu.Lock()
+	if err == nil {
+		v.jwksURI = jwksURI
+		v.keys = keys
+		v.refreshed = time.Now()
+	}
+	v.refreshErr = err
+	v.refreshing = nil
+	v.mu.Unlock()
+	close(done)
+}
+
+// knownKey returns the key with the ID from the last fetched JWKS, or the error of the last
+// refresh when it failed
+func (v *oidcVerifier) knownKey(kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	if v.refreshErr != nil {
+		return nil, v.refreshErr
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// fetchKeys fetches the provider's JWKS, discovering its URI first when jwksURI is empty
+func (v *oidcVerifier) fetchKeys(ctx context.Context, jwksURI string) (map[string]crypto.PublicKey, string, error) {
+	if jwksURI == "" {
+		var discovery struct {
+			Issuer  string `json:"issuer"`
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := v.getJSON(ctx, strings.TrimSuffix(v.config.Issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+			return nil, "", fmt.Errorf("oidc discovery failed: %w", err)
+		}
+		if discovery.Issuer != v.config.Issuer || discovery.JWKSURI == "" {
+			return nil, "", fmt.Errorf("oidc discovery document does not match issuer %s", v.config.Issuer)
+		}
+		jwksURI = discovery.JWKSURI
+	}
+
+	var jwks certificate.JWKS
+	if err := v.getJSON(ctx, jwksURI, &jwks); err != nil {
+		return nil, "", fmt.Errorf("failed to fetch oidc keys: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		// Keys of unsupported types are skipped; tokens signed with them are rejected as unknown
+		if key, err := jwk.PublicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	return keys, jwksURI, nil
+}
+
+func (v *oidcVerifier) getJSON(ctx context.Context, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// verifySignature verifies a JWS signature for the ES* and RS* algorithms
+func verifySignature(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "ES256", "RS256":
+		hash = crypto.SHA256
+	case "ES384", "RS384":
+		hash = crypto.SHA384
+	case "ES512", "RS512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported signature algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		// ES256, ES384 and ES512 are bound to P-256, P-384 and P-521
+		bits := key.Curve.Params().BitSize
+		size := (bits + 7) / 8
+		if alg != fmt.Sprintf("ES%d", min(bits, 512)) || len(signature) != 2*size {
+			return errors.New("invalid token signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errors.New("invalid token signature")
+		}
+	case *rsa.PublicKey:
+		if alg[:2] != "RS" || rsa.VerifyPKCS1v15(key, hash, digest, signature) != nil {
+			return errors.New("invalid token signature")
+		}
+	default:
+		return errors.New("invalid token signature")
+	}
+	return nil
+}
+
+func decodeSegment(segment string, out any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// hasAudience reports whether the aud claim, a string or an array of strings, contains audience
+func hasAudience(aud any, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []any:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
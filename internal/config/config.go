@@ -0,0 +1,635 @@
+// Package config loads the server configuration from a YAML file, environment variables and
+// command line flags, each overriding the previous ones, and validates it.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pvormste/certgen/certificate"
+	"github.com/pvormste/certgen/internal/auth"
+	"github.com/pvormste/certgen/internal/ratelimit"
+	"github.com/pvormste/certgen/internal/server"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvFile is the environment variable naming the config file when -config is not given
+const EnvFile = "CERTGEN_CONFIG"
+
+// Config holds all server settings
+type Config struct {
+	// Addr is the listen address, e.g. ":8443"
+	Addr string `yaml:"addr"`
+
+	TLS       TLS       `yaml:"tls"`
+	Storage   Storage   `yaml:"storage"`
+	HTTP      HTTP      `yaml:"http"`
+	Limits    Limits    `yaml:"limits"`
+	Defaults  Defaults  `yaml:"defaults"`
+	Endpoints Endpoints `yaml:"endpoints"`
+
+	// AuthFile is a JSON file configuring authentication (see auth.LoadConfig)
+	AuthFile string `yaml:"authFile"`
+	// PolicyFile is a JSON file with issuance policies keyed by CA fingerprint; Policies holds
+	// the same map inline. Only one of them may be set.
+	PolicyFile string         `yaml:"policyFile"`
+	Policies   map[string]any `yaml:"policies"`
+}
+
+// TLS configures HTTPS
+type TLS struct {
+	// Enabled serves HTTPS; it is implied by CertFile and KeyFile
+	Enabled      bool     `yaml:"enabled"`
+	CertFile     string   `yaml:"certFile"`
+	KeyFile      string   `yaml:"keyFile"`
+	Hosts        []string `yaml:"hosts"`
+	CADir        string   `yaml:"caDir"`
+	RedirectAddr string   `yaml:"redirectAddr"`
+	ClientCAFile string   `yaml:"clientCAFile"`
+}
+
+// Storage holds the paths of files the server writes
+type Storage struct {
+	SerialFile string `yaml:"serialFile"`
+	AuditLog   string `yaml:"auditLog"`
+}
+
+// HTTP holds the timeouts and size limits of the HTTP server
+type HTTP struct {
+	ReadTimeout     time.Duration `yaml:"readTimeout"`
+	WriteTimeout    time.Duration `yaml:"writeTimeout"`
+	IdleTimeout     time.Duration `yaml:"idleTimeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+	MaxBodyBytes    int64         `yaml:"maxBodyBytes"`
+}
+
+// Limits protects shared instances from excessive certificate and key generation
+type Limits struct {
+	// ClientRate and ClientBurst limit generation requests per second per client address
+	ClientRate  float64 `yaml:"clientRate"`
+	ClientBurst int     `yaml:"clientBurst"`
+	// UserRate and UserBurst limit generation requests per second per authenticated user
+	UserRate  float64 `yaml:"userRate"`
+	UserBurst int     `yaml:"userBurst"`
+	// MaxConcurrentGenerations bounds the generations running at once; QueueTimeout is how
+	// long a request waits for its turn
+	MaxConcurrentGenerations int           `yaml:"maxConcurrentGenerations"`
+	QueueTimeout             time.Duration `yaml:"queueTimeout"`
+}
+
+// Defaults fill in what certificate requests leave empty
+type Defaults struct {
+	KeyAlgorithm       string `yaml:"keyAlgorithm"`
+	Validity           string `yaml:"validity"`
+	Organization       string `yaml:"organization"`
+	OrganizationalUnit string `yaml:"organizationalUnit"`
+	Country            string `yaml:"country"`
+	Province           string `yaml:"province"`
+	Locality           string `yaml:"locality"`
+}
+
+// Endpoints selects the groups of endpoints the server serves
+type Endpoints struct {
+	UI  bool `yaml:"ui"`
+	API bool `yaml:"api"`
+	MCP bool `yaml:"mcp"`
+	// ACME is rejected by Validate, since certgen has no ACME server
+	ACME bool `yaml:"acme"`
+}
+
+// Default returns the configuration used for settings that are not configured
+func Default() Config {
+	opts := server.DefaultHTTPOptions()
+	return Config{
+		Addr:    ":80",
+		Storage: Storage{SerialFile: "serials.json"},
+		HTTP: HTTP{
+			ReadTimeout:     opts.ReadTimeout,
+			WriteTimeout:    opts.WriteTimeout,
+			IdleTimeout:     opts.IdleTimeout,
+			ShutdownTimeout: opts.ShutdownTimeout,
+			MaxBodyBytes:    opts.MaxBodyBytes,
+		},
+		Limits: Limits{
+			ClientRate:               2,
+			ClientBurst:              20,
+			UserRate:                 5,
+			UserBurst:                50,
+			MaxConcurrentGenerations: runtime.NumCPU(),
+			QueueTimeout:             10 * time.Second,
+		},
+		Endpoints: Endpoints{UI: true, API: true, MCP: true},
+	}
+}
+
+// Load builds the configuration from the defaults, the config file named by the -config flag
+// or EnvFile, the environment and the command line args, and validates it. The flags are
+// registered on fs, which reports usage errors as configured.
+func Load(fs *flag.FlagSet, args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	c := Default()
+	configFile := fs.String("config", "", "YAML config file (env "+EnvFile+")")
+	for _, s := range c.settings() {
+		if s.flag != "" {
+			fs.Var(s.value, s.flag, s.usage+envHint(s.env))
+		}
+	}
+	// The first pass only finds the config file; flags are applied again after it
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	path := *configFile
+	if path == "" {
+		path, _ = lookupEnv(EnvFile)
+	}
+
+	c = Default()
+	if path != "" {
+		if err := c.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.applyEnv(lookupEnv); err != nil {
+		return nil, err
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// loadFile overrides the configuration with the settings in a YAML file. Unknown keys are errors.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv overrides the configuration with the environment variables that are set
+func (c *Config) applyEnv(lookupEnv func(string) (string, bool)) error {
+	var errs []error
+	addr, _ := lookupEnv("ADDR")
+	if addr != "" {
+		if err := checkPortEnv(addr, lookupEnv); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, s := range c.settings() {
+		// PORT is a shorthand for ADDR, so the more specific ADDR wins when both name the same port
+		if s.env == "" || (s.env == "PORT" && addr != "") {
+			continue
+		}
+		if value, ok := lookupEnv(s.env); ok && value != "" {
+			if err := s.value.Set(value); err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s: %w", s.env, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkPortEnv rejects a PORT environment variable naming another port than the address
+// in ADDR, instead of letting one silently override the other
+func checkPortEnv(addr string, lookupEnv func(string) (string, bool)) error {
+	port, ok := lookupEnv("PORT")
+	if !ok || port == "" {
+		return nil
+	}
+	if _, addrPort, err := net.SplitHostPort(addr); err == nil && addrPort == strings.TrimPrefix(port, ":") {
+		return nil
+	}
+	return fmt.Errorf("ADDR %q and PORT %q conflict, set only one of them", addr, port)
+}
+
+// Validate checks the configuration and reports every invalid setting at once, named by its
+// key in the config file
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(key string, err error) {
+		errs = append(errs, fmt.Errorf("%s: %w", key, err))
+	}
+
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		invalid("addr", err)
+	}
+
+	if c.TLS.CertFile != "" || c.TLS.KeyFile != "" {
+		if c.TLS.CertFile == "" || c.TLS.KeyFile == "" {
+			invalid("tls", errors.New("certFile and keyFile must be set together"))
+		}
+	} else if !c.TLS.Enabled && (c.TLS.RedirectAddr != "" || c.TLS.ClientCAFile != "") {
+		invalid("tls", errors.New("redirectAddr and clientCAFile require TLS to be enabled"))
+	}
+	if c.TLS.RedirectAddr != "" {
+		if _, _, err := net.SplitHostPort(c.TLS.RedirectAddr); err != nil {
+			invalid("tls.redirectAddr", err)
+		}
+	}
+	for _, file := range []struct{ key, path string }{
+		{"tls.certFile", c.TLS.CertFile},
+		{"tls.keyFile", c.TLS.KeyFile},
+		{"tls.clientCAFile", c.TLS.ClientCAFile},
+	} {
+		if file.path == "" {
+			continue
+		}
+		if _, err := os.Stat(file.path); err != nil {
+			invalid(file.key, err)
+		}
+	}
+
+	// Client certificates are only verified, and so only authenticate, with a client CA
+	if authConfig, err := c.authConfig(); err != nil {
+		invalid("authFile", err)
+	} else if authConfig != nil && authConfig.ClientCertificates && c.TLS.ClientCAFile == "" {
+		invalid("tls.clientCAFile", errors.New("must be set when the auth file enables clientCertificates"))
+	}
+
+	for _, timeout := range []struct {
+		key string
+		d   time.Duration
+	}{
+		{"http.readTimeout", c.HTTP.ReadTimeout},
+		{"http.writeTimeout", c.HTTP.WriteTimeout},
+		{"http.idleTimeout", c.HTTP.IdleTimeout},
+		{"http.shutdownTimeout", c.HTTP.ShutdownTimeout},
+	} {
+		if timeout.d < 0 {
+			invalid(timeout.key, errors.New("must not be negative"))
+		}
+	}
+	if c.HTTP.MaxBodyBytes < 0 {
+		invalid("http.maxBodyBytes", errors.New("must not be negative"))
+	}
+
+	for _, limit := range []struct {
+		key   string
+		value float64
+	}{
+		{"limits.clientRate", c.Limits.ClientRate},
+		{"limits.clientBurst", float64(c.Limits.ClientBurst)},
+		{"limits.userRate", c.Limits.UserRate},
+		{"limits.userBurst", float64(c.Limits.UserBurst)},
+		{"limits.maxConcurrentGenerations", float64(c.Limits.MaxConcurrentGenerations)},
+		{"limits.queueTimeout", float64(c.Limits.QueueTimeout)},
+	} {
+		if math.IsNaN(limit.value) || math.IsInf(limit.value, 0) {
+			invalid(limit.key, errors.New("must be a finite number"))
+		} else if limit.value < 0 {
+			invalid(limit.key, errors.New("must not be negative"))
+		}
+	}
+
+	var validationErr *certificate.ValidationError
+	if err := c.CertificateDefaults().Validate(); errors.As(err, &validationErr) {
+		for _, field := range validationErr.Fields {
+			invalid("defaults."+field.Field, field.Err)
+		}
+	}
+
+	if c.Endpoints.ACME {
+		invalid("endpoints.acme", errors.New("certgen does not implement ACME"))
+	}
+	if !c.Endpoints.UI && !c.Endpoints.API && !c.Endpoints.MCP {
+		invalid("endpoints", errors.New("at least one of ui, api and mcp must be enabled"))
+	}
+
+	if c.PolicyFile != "" && len(c.Policies) > 0 {
+		invalid("policies", errors.New("policyFile and policies are mutually exclusive"))
+	} else if _, err := c.PolicySet(); err != nil && len(c.Policies) > 0 {
+		invalid("policies", err)
+	} else if err != nil {
+		invalid("policyFile", err)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+}
+
+// TLSEnabled reports whether the server serves HTTPS
+func (c *Config) TLSEnabled() bool {
+	return c.TLS.Enabled || c.TLS.CertFile != "" || c.TLS.KeyFile != ""
+}
+
+// HTTPOptions returns the server's HTTP options with the configured timeouts and limits
+func (c *Config) HTTPOptions() server.HTTPOptions {
+	opts := server.DefaultHTTPOptions()
+	opts.ReadTimeout = c.HTTP.ReadTimeout
+	opts.WriteTimeout = c.HTTP.WriteTimeout
+	opts.IdleTimeout = c.HTTP.IdleTimeout
+	opts.ShutdownTimeout = c.HTTP.ShutdownTimeout
+	opts.MaxBodyBytes = c.HTTP.MaxBodyBytes
+	return opts
+}
+
+// RateLimits returns the configured generation limits, or nil when all of them are disabled
+func (c *Config) RateLimits() *ratelimit.Limits {
+	return ratelimit.New(ratelimit.Config{
+		ClientRate:    c.Limits.ClientRate,
+		ClientBurst:   c.Limits.ClientBurst,
+		UserRate:      c.Limits.UserRate,
+		UserBurst:     c.Limits.UserBurst,
+		MaxConcurrent: c.Limits.MaxConcurrentGenerations,
+		QueueTimeout:  c.Limits.QueueTimeout,
+	})
+}
+
+// ServerEndpoints returns the endpoint groups the server serves
+func (c *Config) ServerEndpoints() server.Endpoints {
+	return server.Endpoints{UI: c.Endpoints.UI, API: c.Endpoints.API, MCP: c.Endpoints.MCP}
+}
+
+// TLSOptions returns the server's TLS options
+func (c *Config) TLSOptions() server.TLSOptions {
+	return server.TLSOptions{
+		CertFile:     c.TLS.CertFile,
+		KeyFile:      c.TLS.KeyFile,
+		Hostnames:    c.TLS.Hosts,
+		ListenAddr:   c.Addr,
+		CADir:        c.TLS.CADir,
+		RedirectAddr: c.TLS.RedirectAddr,
+		ClientCAFile: c.TLS.ClientCAFile,
+	}
+}
+
+// CertificateDefaults returns the defaults for certificate requests
+func (c *Config) CertificateDefaults() certificate.Defaults {
+	return certificate.Defaults{
+		KeyAlgorithm:       c.Defaults.KeyAlgorithm,
+		Organization:       c.Defaults.Organization,
+		OrganizationalUnit: c.Defaults.OrganizationalUnit,
+		Country:            c.Defaults.Country,
+		Province:           c.Defaults.Province,
+		Locality:           c.Defaults.Locality,
+		Validity:           c.Defaults.Validity,
+	}
+}
+
+// PolicySet returns the inline policies or loads the policy file. It returns nil when no
+// policies are configured.
+func (c *Config) PolicySet() (certificate.PolicySet, error) {
+	if len(c.Policies) > 0 {
+		// The policies are defined by their JSON encoding, which YAML maps translate to
+		data, err := json.Marshal(c.Policies)
+		if err != nil {
+			return nil, err
+		}
+		return certificate.LoadPolicySet(bytes.NewReader(data))
+	}
+	if c.PolicyFile == "" {
+		return nil, nil
+	}
+
+	f, err := os.Open(c.PolicyFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return certificate.LoadPolicySet(f)
+}
+
+// Authenticator loads the auth file. It returns nil when authentication is not configured.
+func (c *Config) Authenticator() (*auth.Authenticator, error) {
+	authConfig, err := c.authConfig()
+	if err != nil || authConfig == nil {
+		return nil, err
+	}
+	return auth.New(*authConfig)
+}
+
+// authConfig reads the auth file; nil when none is set
+func (c *Config) authConfig() (*auth.Config, error) {
+	if c.AuthFile == "" {
+		return nil, nil
+	}
+
+	f, err := os.Open(c.AuthFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return auth.LoadConfig(f)
+}
+
+// setting is a configuration value that can be set by a flag and an environment variable
+type setting struct {
+	flag, env, usage string
+	value            flag.Value
+}
+
+// settings lists the flags and environment variables overriding the config file
+func (c *Config) settings() []setting {
+	return []setting{
+		{"addr", "ADDR", "HTTP service address", (*stringValue)(&c.Addr)},
+		{"", "PORT", "", (*portValue)(&c.Addr)},
+		{"tls", "TLS", "Serve HTTPS, with a self-issued certificate unless -tls-cert and -tls-key are given", (*boolValue)(&c.TLS.Enabled)},
+		{"tls-cert", "TLS_CERT_FILE", "PEM certificate file for HTTPS (implies -tls)", (*stringValue)(&c.TLS.CertFile)},
+		{"tls-key", "TLS_KEY_FILE", "PEM private key file for HTTPS (implies -tls)", (*stringValue)(&c.TLS.KeyFile)},
+		{"tls-hosts", "TLS_HOSTS", "Comma separated host name patterns self-issued certificates are limited to (the listen host, or localhost and the machine's host name, when empty)", (*listValue)(&c.TLS.Hosts)},
+		{"tls-ca-dir", "TLS_CA_DIR", "Directory keeping the self-issued CA across restarts (in memory when empty)", (*stringValue)(&c.TLS.CADir)},
+		{"redirect-addr", "REDIRECT_ADDR", "Plain HTTP address redirecting to HTTPS, e.g. :80", (*stringValue)(&c.TLS.RedirectAddr)},
+		{"client-ca", "CLIENT_CA_FILE", "PEM file with CA certificates; clients must present a certificate issued by one of them", (*stringValue)(&c.TLS.ClientCAFile)},
+		{"serial-file", "SERIAL_FILE", "JSON file persisting sequential serial numbers per CA (the sequential strategy is refused when empty)", (*stringValue)(&c.Storage.SerialFile)},
+		{"audit-log", "AUDIT_LOG", "Append-only JSON lines file recording CA creation, issuance, downloads and rejected requests (no audit log when empty)", (*stringValue)(&c.Storage.AuditLog)},
+		{"auth-file", "AUTH_FILE", "JSON file configuring authentication methods, roles and users (no authentication when empty)", (*stringValue)(&c.AuthFile)},
+		{"policy-file", "POLICY_FILE", "JSON file with issuance policies keyed by CA fingerprint", (*stringValue)(&c.PolicyFile)},
+		{"read-timeout", "READ_TIMEOUT", "Maximum duration for reading a request including its body (0 disables it)", (*durationValue)(&c.HTTP.ReadTimeout)},
+		{"write-timeout", "WRITE_TIMEOUT", "Maximum duration for writing a response (0 disables it)", (*durationValue)(&c.HTTP.WriteTimeout)},
+		{"idle-timeout", "IDLE_TIMEOUT", "Maximum time to keep an idle keep-alive connection open (0 disables it)", (*durationValue)(&c.HTTP.IdleTimeout)},
+		{"shutdown-timeout", "SHUTDOWN_TIMEOUT", "Time to let running requests finish after SIGINT or SIGTERM (0 waits indefinitely)", (*durationValue)(&c.HTTP.ShutdownTimeout)},
+		{"max-body-bytes", "MAX_BODY_BYTES", "Maximum size of a request body in bytes (0 disables the limit)", (*int64Value)(&c.HTTP.MaxBodyBytes)},
+		{"rate-limit-client", "RATE_LIMIT_CLIENT", "Generation requests per second per client address (0 disables the limit)", (*float64Value)(&c.Limits.ClientRate)},
+		{"rate-limit-client-burst", "RATE_LIMIT_CLIENT_BURST", "Generation requests a client address may make at once before the rate applies", (*intValue)(&c.Limits.ClientBurst)},
+		{"rate-limit-user", "RATE_LIMIT_USER", "Generation requests per second per authenticated user (0 disables the limit)", (*float64Value)(&c.Limits.UserRate)},
+		{"rate-limit-user-burst", "RATE_LIMIT_USER_BURST", "Generation requests a user may make at once before the rate applies", (*intValue)(&c.Limits.UserBurst)},
+		{"max-concurrent-generations", "MAX_CONCURRENT_GENERATIONS", "Certificate and key generations running at once (0 disables the limit)", (*intValue)(&c.Limits.MaxConcurrentGenerations)},
+		{"generation-queue-timeout", "GENERATION_QUEUE_TIMEOUT", "Time a generation request waits for its turn before it is rejected (0 waits indefinitely)", (*durationValue)(&c.Limits.QueueTimeout)},
+		{"default-key-algorithm", "DEFAULT_KEY_ALGORITHM", "Key algorithm of generated keys (only ecdsa-p384)", (*stringValue)(&c.Defaults.KeyAlgorithm)},
+		{"default-validity", "DEFAULT_VALIDITY", "Validity of certificates requested without one, e.g. 90d", (*stringValue)(&c.Defaults.Validity)},
+		{"default-organization", "DEFAULT_ORGANIZATION", "Organization of certificates requested without one", (*stringValue)(&c.Defaults.Organization)},
+		{"default-organizational-unit", "DEFAULT_ORGANIZATIONAL_UNIT", "Organizational unit of certificates requested without one", (*stringValue)(&c.Defaults.OrganizationalUnit)},
+		{"default-country", "DEFAULT_COUNTRY", "Country of certificates requested without one", (*stringValue)(&c.Defaults.Country)},
+		{"default-province", "DEFAULT_PROVINCE", "Province of certificates requested without one", (*stringValue)(&c.Defaults.Province)},
+		{"default-locality", "DEFAULT_LOCALITY", "Locality of certificates requested without one", (*stringValue)(&c.Defaults.Locality)},
+		{"ui", "ENABLE_UI", "Serve the web UI", (*boolValue)(&c.Endpoints.UI)},
+		{"api", "ENABLE_API", "Serve the REST API", (*boolValue)(&c.Endpoints.API)},
+		{"mcp", "ENABLE_MCP", "Serve the MCP server", (*boolValue)(&c.Endpoints.MCP)},
+		{"acme", "ENABLE_ACME", "Serve ACME (not implemented, rejected when enabled)", (*boolValue)(&c.Endpoints.ACME)},
+	}
+}
+
+func envHint(env string) string {
+	if env == "" {
+		return ""
+	}
+	return " (env " + env + ")"
+}
+
+type stringValue string
+
+func (v *stringValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return string(*v)
+}
+
+func (v *stringValue) Set(s string) error {
+	*v = stringValue(s)
+	return nil
+}
+
+// portValue sets a listen address from a port number such as "9595" or ":9595"
+type portValue string
+
+func (v *portValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return string(*v)
+}
+
+func (v *portValue) Set(s string) error {
+	port := strings.TrimPrefix(s, ":")
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return fmt.Errorf("invalid port %q", s)
+	}
+	*v = portValue(":" + port)
+	return nil
+}
+
+type boolValue bool
+
+func (v *boolValue) String() string {
+	if v == nil {
+		return "false"
+	}
+	return strconv.FormatBool(bool(*v))
+}
+
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("invalid boolean %q", s)
+	}
+	*v = boolValue(b)
+	return nil
+}
+
+func (v *boolValue) IsBoolFlag() bool { return true }
+
+type durationValue time.Duration
+
+func (v *durationValue) String() string {
+	if v == nil {
+		return "0s"
+	}
+	return time.Duration(*v).String()
+}
+
+func (v *durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+type int64Value int64
+
+func (v *int64Value) String() string {
+	if v == nil {
+		return "0"
+	}
+	return strconv.FormatInt(int64(*v), 10)
+}
+
+func (v *int64Value) Set(s string) error {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid integer %q", s)
+	}
+	*v = int64Value(n)
+	return nil
+}
+
+type intValue int
+
+func (v *intValue) String() string {
+	if v == nil {
+		return "0"
+	}
+	return strconv.Itoa(int(*v))
+}
+
+func (v *intValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("invalid integer %q", s)
+	}
+	*v = intValue(n)
+	return nil
+}
+
+type float64Value float64
+
+func (v *float64Value) String() string {
+	if v == nil {
+		return "0"
+	}
+	return strconv.FormatFloat(float64(*v), 'g', -1, 64)
+}
+
+func (v *float64Value) Set(s string) error {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %q", s)
+	}
+	*v = float64Value(f)
+	return nil
+}
+
+// listValue is a comma separated list; setting it replaces the list
+type listValue []string
+
+func (v *listValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return strings.Join(*v, ",")
+}
+
+func (v *listValue) Set(s string) error {
+	*v = nil
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*v = append(*v, item)
+		}
+	}
+	return nil
+}
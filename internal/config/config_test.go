@@ -0,0 +1,233 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok
+	}
+}
+
+func load(args []string, vars map[string]string) (*Config, error) {
+	fs := flag.NewFlagSet("certgen", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return Load(fs, args, env(vars))
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, "certgen.yaml", `
+addr: ":8000"
+http:
+  readTimeout: 5s
+  maxBodyBytes: 1024
+limits:
+  clientRate: 0.5
+  queueTimeout: 1s
+defaults:
+  organization: File Org
+  validity: 90d
+endpoints:
+  mcp: false
+storage:
+  serialFile: data/serials.json
+tls:
+  enabled: true
+  hosts: [localhost]
+policies:
+  "*":
+    maxValidity: 30d
+`)
+
+	c, err := load([]string{"-addr", ":9000", "-tls-hosts", "a.test, b.test"}, map[string]string{
+		EnvFile:                path,
+		"PORT":                 "8500",
+		"DEFAULT_ORGANIZATION": "Env Org",
+		"ENABLE_API":           "false",
+		"RATE_LIMIT_USER":      "0",
+	})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if c.Addr != ":9000" {
+		t.Errorf("Addr = %q, want the flag to win", c.Addr)
+	}
+	if c.Defaults.Organization != "Env Org" || c.Defaults.Validity != "90d" {
+		t.Errorf("Defaults = %+v, want the environment to override the file", c.Defaults)
+	}
+	if c.HTTP.ReadTimeout != 5*time.Second || c.HTTP.WriteTimeout != Default().HTTP.WriteTimeout || c.HTTP.MaxBodyBytes != 1024 {
+		t.Errorf("HTTP = %+v, want file values over defaults", c.HTTP)
+	}
+	if c.Limits.ClientRate != 0.5 || c.Limits.UserRate != 0 || c.Limits.QueueTimeout != time.Second || c.Limits.ClientBurst != Default().Limits.ClientBurst {
+		t.Errorf("Limits = %+v", c.Limits)
+	}
+	if !c.Endpoints.UI || c.Endpoints.API || c.Endpoints.MCP {
+		t.Errorf("Endpoints = %+v", c.Endpoints)
+	}
+	if !c.TLSEnabled() || strings.Join(c.TLS.Hosts, ",") != "a.test,b.test" {
+		t.Errorf("TLS = %+v", c.TLS)
+	}
+	if c.Storage.SerialFile != "data/serials.json" {
+		t.Errorf("SerialFile = %q", c.Storage.SerialFile)
+	}
+	policies, err := c.PolicySet()
+	if err != nil || policies["*"] == nil || policies["*"].MaxValidity != "30d" {
+		t.Errorf("PolicySet() = %v, %v", policies, err)
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	c, err := load(nil, map[string]string{"PORT": ":8080"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if c.Addr != ":8080" || c.TLSEnabled() || !c.Endpoints.UI || !c.Endpoints.API || !c.Endpoints.MCP {
+		t.Errorf("Load() = %+v", c)
+	}
+	if policies, err := c.PolicySet(); policies != nil || err != nil {
+		t.Errorf("PolicySet() = %v, %v, want none", policies, err)
+	}
+}
+
+func TestLoadAddrAndPort(t *testing.T) {
+	c, err := load(nil, map[string]string{"ADDR": "127.0.0.1:8080", "PORT": "8080"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if c.Addr != "127.0.0.1:8080" {
+		t.Errorf("Addr = %q, want ADDR to keep its host when PORT names the same port", c.Addr)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		// authFile is the content of the auth file passed in AUTH_FILE
+		authFile string
+		args     []string
+		env      map[string]string
+		want     []string
+	}{
+		{
+			name: "unknown key",
+			file: "adress: :80\n",
+			want: []string{"field adress not found"},
+		},
+		{
+			name: "invalid environment variable",
+			env:  map[string]string{"TLS": "maybe", "READ_TIMEOUT": "soon"},
+			want: []string{"invalid TLS", "invalid READ_TIMEOUT"},
+		},
+		{
+			name: "invalid settings",
+			file: `
+tls:
+  certFile: server.crt
+  redirectAddr: ":80"
+http:
+  idleTimeout: -1s
+limits:
+  clientBurst: -1
+defaults:
+  keyAlgorithm: rsa-2048
+  country: Germany
+  validity: soon
+endpoints:
+  ui: false
+  api: false
+  mcp: false
+  acme: true
+policyFile: policies.json
+policies:
+  "*": {}
+`,
+			want: []string{
+				"tls: certFile and keyFile must be set together",
+				"tls.certFile:",
+				"http.idleTimeout: must not be negative",
+				"limits.clientBurst: must not be negative",
+				"defaults.keyAlgorithm:",
+				"defaults.country:",
+				"defaults.validity:",
+				"endpoints.acme: certgen does not implement ACME",
+				"endpoints: at least one of ui, api and mcp must be enabled",
+				"policies: policyFile and policies are mutually exclusive",
+			},
+		},
+		{
+			name: "ADDR and PORT naming different ports",
+			env:  map[string]string{"ADDR": "127.0.0.1:8080", "PORT": "9090"},
+			want: []string{`ADDR "127.0.0.1:8080" and PORT "9090" conflict`},
+		},
+		{
+			name: "non-finite rates",
+			env:  map[string]string{"RATE_LIMIT_CLIENT": "NaN", "RATE_LIMIT_USER": "+Inf"},
+			want: []string{"limits.clientRate: must be a finite number", "limits.userRate: must be a finite number"},
+		},
+		{
+			name: "redirect without TLS",
+			args: []string{"-redirect-addr", ":80"},
+			want: []string{"require TLS to be enabled"},
+		},
+		{
+			name: "invalid policy",
+			file: "policies:\n  \"*\":\n    maxValidity: forever\n",
+			want: []string{"policies: policy *: maxValidity"},
+		},
+		{
+			name:     "client certificates without client CA",
+			authFile: `{"clientCertificates": true}`,
+			args:     []string{"-tls"},
+			want:     []string{"tls.clientCAFile: must be set when the auth file enables clientCertificates"},
+		},
+		{
+			name:     "invalid auth file",
+			authFile: `{"clientCertificates": "yes"}`,
+			want:     []string{"authFile: failed to decode auth config"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars := map[string]string{}
+			for name, value := range tt.env {
+				vars[name] = value
+			}
+			if tt.file != "" {
+				vars[EnvFile] = writeFile(t, "certgen.yaml", tt.file)
+			}
+			if tt.authFile != "" {
+				vars["AUTH_FILE"] = writeFile(t, "auth.json", tt.authFile)
+			}
+
+			_, err := load(tt.args, vars)
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Error lacks %q:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
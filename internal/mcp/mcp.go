@@ -2,17 +2,31 @@ package mcp
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
-	"github.com/pvormste/certgen/internal/certificate"
+	"github.com/pvormste/certgen/certificate"
+	"github.com/pvormste/certgen/internal/audit"
+	"github.com/pvormste/certgen/internal/auth"
+	"github.com/pvormste/certgen/internal/metrics"
+	"github.com/pvormste/certgen/internal/ratelimit"
+	"github.com/pvormste/certgen/internal/ssh"
 )
 
 // CAResponse represents the JSON response for CA certificate generation.
 type CAResponse struct {
-	Certificate string `json:"certificate"`
-	PrivateKey  string `json:"privateKey"`
+	Certificate string            `json:"certificate"`
+	PrivateKey  string            `json:"privateKey"`
+	PrivateJWK  *certificate.JWK  `json:"privateJwk"`
+	PublicJWKS  *certificate.JWKS `json:"publicJwks"`
 }
 
 // CertResponse represents the JSON response for server/client certificate generation.
@@ -22,22 +36,182 @@ type CertResponse struct {
 	UnifiedPEM   string `json:"unifiedPEM"`
 	ChainPEM     string `json:"chainPEM"`
 	FullChainPEM string `json:"fullChainPEM"`
+	// PrivateJWK includes the private key and the leaf and CA certificates in x5c
+	PrivateJWK *certificate.JWK  `json:"privateJwk"`
+	PublicJWKS *certificate.JWKS `json:"publicJwks"`
+}
+
+// BrokenCertResponse represents a single deliberately invalid certificate.
+type BrokenCertResponse struct {
+	Defect      string `json:"defect"`
+	Description string `json:"description"`
+	Certificate string `json:"certificate"`
+	PrivateKey  string `json:"privateKey"`
+	ChainPEM    string `json:"chainPEM"`
+}
+
+// BrokenCertsResponse represents the JSON response for broken certificate generation.
+type BrokenCertsResponse struct {
+	Certificates []BrokenCertResponse `json:"certificates"`
+}
+
+// SSHCAResponse represents the JSON response for SSH CA generation.
+type SSHCAResponse struct {
+	PrivateKey         string `json:"privateKey"`
+	PublicKey          string `json:"publicKey"`
+	AuthorizedKeysLine string `json:"authorizedKeysLine"`
+	KnownHostsLine     string `json:"knownHostsLine"`
+}
+
+// SSHCertResponse represents the JSON response for SSH certificate signing.
+type SSHCertResponse struct {
+	Certificate string `json:"certificate"`
+	PublicKey   string `json:"publicKey"`
+	PrivateKey  string `json:"privateKey,omitempty"`
+	// KeyFileName is the default OpenSSH file name for the key, e.g. id_ed25519
+	KeyFileName string `json:"keyFileName"`
+}
+
+// issuer holds the state shared by the tools that sign certificates with a caller-provided CA.
+type issuer struct {
+	policies certificate.PolicySet
+	serials  certificate.SerialCounter
+	defaults certificate.Defaults
+	auth     *auth.Authenticator
+	audit    *audit.Log
+	limits   *ratelimit.Limits
+	metrics  *metrics.Registry
+}
+
+// expiryDays returns the expiryDays argument. It defaults to 365 unless the server has a default
+// validity, which then applies instead.
+func (iss *issuer) expiryDays(req mcp.CallToolRequest) int {
+	if iss.defaults.Validity != "" {
+		return req.GetInt("expiryDays", 0)
+	}
+	return req.GetInt("expiryDays", 365)
+}
+
+// toolPermissions maps tool names to the permission needed to call them.
+var toolPermissions = map[string]auth.Permission{
+	"generate_ca":                  auth.PermissionCreateCA,
+	"generate_ssh_ca":              auth.PermissionCreateCA,
+	"generate_server_certificate":  auth.PermissionIssue,
+	"generate_client_certificate":  auth.PermissionIssue,
+	"generate_peer_certificate":    auth.PermissionIssue,
+	"generate_broken_certificates": auth.PermissionIssue,
+	"sign_ssh_certificate":         auth.PermissionIssue,
+	"inspect_certificate":          auth.PermissionInspect,
+}
+
+// Options configures the MCP server. The zero value serves every tool to everyone, without
+// policies, sequential serials, defaults, audit log, limits or metrics.
+type Options struct {
+	// Policies applies the issuance policy of a CA to the certificates it signs.
+	Policies certificate.PolicySet
+	// Serials counts sequential serial numbers; without it they are rejected.
+	Serials certificate.SerialCounter
+	// Defaults fill in the subject attributes and validity a call leaves empty.
+	Defaults certificate.Defaults
+	// Auth requires tool calls to have the permission of the tool and, for issuing tools,
+	// permission to use the CA. The caller is taken from the context set up by the HTTP
+	// authentication middleware.
+	Auth *auth.Authenticator
+	// Audit records created CAs, issued certificates and denied calls.
+	Audit *audit.Log
+	// Limits bounds the tools generating keys.
+	Limits *ratelimit.Limits
+	// Metrics counts tool calls and generated certificates.
+	Metrics *metrics.Registry
 }
 
 // NewServer creates and configures a new MCP server with certificate generation tools.
-func NewServer() *server.MCPServer {
+func NewServer(opts Options) *server.MCPServer {
+	iss := &issuer{policies: opts.Policies, serials: opts.Serials, defaults: opts.Defaults, auth: opts.Auth, audit: opts.Audit, limits: opts.Limits, metrics: opts.Metrics}
+
 	s := server.NewMCPServer("Certgen", "1.0.0",
 		server.WithToolCapabilities(true),
+		server.WithToolHandlerMiddleware(auditContext),
+		server.WithToolHandlerMiddleware(iss.countToolCalls),
+		server.WithToolHandlerMiddleware(iss.authorizeTool),
+		server.WithToolHandlerMiddleware(iss.limitTool),
 	)
 
 	// Register tools
-	s.AddTool(generateCATool(), handleGenerateCA)
-	s.AddTool(generateServerCertTool(), handleGenerateServerCert)
-	s.AddTool(generateClientCertTool(), handleGenerateClientCert)
+	s.AddTool(generateCATool(), iss.handleGenerateCA)
+	s.AddTool(generateServerCertTool(), iss.handleGenerateServerCert)
+	s.AddTool(generateClientCertTool(), iss.handleGenerateClientCert)
+	s.AddTool(generatePeerCertTool(), iss.handleGeneratePeerCert)
+	s.AddTool(generateBrokenCertsTool(), iss.handleGenerateBrokenCerts)
+	s.AddTool(inspectCertTool(), handleInspectCert)
+	s.AddTool(generateSSHCATool(), iss.handleGenerateSSHCA)
+	s.AddTool(signSSHCertTool(), iss.handleSignSSHCert)
 
 	return s
 }
 
+// auditContext is a tool middleware marking the audit events of a call with the tool name.
+func auditContext(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return next(audit.WithOperation(ctx, audit.SourceMCP, req.Params.Name), req)
+	}
+}
+
+// countToolCalls is a tool middleware counting calls and error results per tool, including
+// calls rejected by authorizeTool.
+func (iss *issuer) countToolCalls(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := next(ctx, req)
+		iss.metrics.ObserveToolCall(req.Params.Name, err != nil || (result != nil && result.IsError))
+		return result, err
+	}
+}
+
+// authorizeTool is a tool middleware rejecting calls the caller lacks the permission for.
+func (iss *issuer) authorizeTool(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if err := audit.Authorize(ctx, iss.auth, iss.audit, toolPermissions[req.Params.Name]); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return next(ctx, req)
+	}
+}
+
+// limitTool is a tool middleware running tools that generate keys within the rate limits of the
+// client and user and the bound on concurrent generations. Calls over a limit get a tool error
+// telling when to retry.
+func (iss *issuer) limitTool(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if toolPermissions[req.Params.Name] == auth.PermissionInspect {
+			return next(ctx, req)
+		}
+
+		var user string
+		if p := auth.PrincipalFromContext(ctx); p != nil {
+			user = p.Name
+		}
+		release, err := iss.limits.Acquire(ctx, audit.RemoteAddrFromContext(ctx), user)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		defer release()
+		return next(ctx, req)
+	}
+}
+
+// errAuditFailed is the tool error of calls whose audit event could not be written.
+var errAuditFailed = errors.New("failed to write the audit event, so the result is withheld")
+
+// record writes an audit event for the tool call. Results must not be returned when it fails,
+// so nothing is handed out unaudited.
+func (iss *issuer) record(ctx context.Context, event audit.Event) error {
+	if err := iss.audit.Record(ctx, event); err != nil {
+		log.Printf("Failed to write audit event: %v", err)
+		return errAuditFailed
+	}
+	return nil
+}
+
 // generateCATool defines the generate_ca tool schema.
 func generateCATool() mcp.Tool {
 	return mcp.NewTool("generate_ca",
@@ -52,15 +226,80 @@ func generateCATool() mcp.Tool {
 		),
 		mcp.WithString("country",
 			mcp.Required(),
-			mcp.Description("Country code (e.g., US, DE, UK)"),
+			mcp.Description("ISO 3166-1 alpha-2 country code (e.g., US, DE, GB)"),
 		),
 		mcp.WithString("locality",
 			mcp.Required(),
 			mcp.Description("City or locality name"),
 		),
 		mcp.WithNumber("expiryDays",
-			mcp.Required(),
-			mcp.Description("Number of days the certificate is valid"),
+			mcp.Description("Number of days the certificate is valid (default 365 or the server's default validity, ignored when validity or notAfter is set)"),
+		),
+		mcp.WithString("validity",
+			mcp.Description("Validity duration such as 15m, 6h or 90d; overrides expiryDays"),
+		),
+		mcp.WithString("notBefore",
+			mcp.Description("Explicit start of the validity period (RFC 3339, e.g. 2025-01-01T00:00:00Z); defaults to now minus a few minutes to tolerate clock skew"),
+		),
+		mcp.WithString("notAfter",
+			mcp.Description("Explicit end of the validity period (RFC 3339); overrides validity and expiryDays"),
+		),
+		mcp.WithString("organizationalUnit",
+			mcp.Description("Organizational Unit (OU)"),
+		),
+		mcp.WithString("province",
+			mcp.Description("State or province name"),
+		),
+		mcp.WithString("streetAddress",
+			mcp.Description("Street address"),
+		),
+		mcp.WithString("postalCode",
+			mcp.Description("Postal code"),
+		),
+		mcp.WithString("serialNumber",
+			mcp.Description("Subject serialNumber attribute (not the certificate serial number)"),
+		),
+		mcp.WithString("dn",
+			mcp.Description("RFC 4514 distinguished name (e.g., CN=foo,OU=bar,O=baz); attributes given here take precedence over the individual fields and may use dotted OIDs as attribute types"),
+		),
+		mcp.WithString("extraAttributes",
+			mcp.Description("JSON array of additional subject attributes, e.g. [{\"oid\":\"1.3.6.1.4.1.99999.2\",\"value\":\"extra\"}]; standard types such as O or C must use their own fields"),
+		),
+		mcp.WithString("serial",
+			mcp.Description("Certificate serial number in decimal or hex (0x2a or 00:2a:ff); a random 128-bit serial is used when empty"),
+		),
+		mcp.WithString("extensions",
+			mcp.Description("JSON array of custom extensions, e.g. [{\"oid\":\"1.3.6.1.4.1.311.20.2\",\"type\":\"bmp\",\"value\":\"WebServer\"}]. type is der (hex DER, default), utf8, ia5, printable, bmp, octets (hex), integer, boolean, null or oid; critical marks the extension critical. Extensions certgen sets itself (key identifiers, key usage, SANs, basic constraints, name constraints, certificate policies, extended key usage, TLS feature) are rejected"),
+		),
+		mcp.WithString("certificatePolicies",
+			mcp.Description("JSON array of certificate policies with optional CPS URIs, e.g. [{\"oid\":\"2.23.140.1.2.1\",\"cpsUris\":[\"https://pki.example/cps\"]}]"),
+		),
+		mcp.WithBoolean("mustStaple",
+			mcp.Description("Add the TLS feature extension requiring OCSP stapling (Must-Staple)"),
+		),
+		mcp.WithString("permittedDnsDomains",
+			mcp.Description("Comma-separated DNS domains the CA may issue for (e.g., internal.example matches the domain and its subdomains, .internal.example only subdomains)"),
+		),
+		mcp.WithString("excludedDnsDomains",
+			mcp.Description("Comma-separated DNS domains the CA must never issue for"),
+		),
+		mcp.WithString("permittedIpRanges",
+			mcp.Description("Comma-separated CIDR ranges the CA may issue for (e.g., 10.0.0.0/8)"),
+		),
+		mcp.WithString("excludedIpRanges",
+			mcp.Description("Comma-separated CIDR ranges the CA must never issue for"),
+		),
+		mcp.WithString("permittedEmailAddresses",
+			mcp.Description("Comma-separated mailboxes or email domains the CA may issue for"),
+		),
+		mcp.WithString("excludedEmailAddresses",
+			mcp.Description("Comma-separated mailboxes or email domains the CA must never issue for"),
+		),
+		mcp.WithString("permittedUriDomains",
+			mcp.Description("Comma-separated URI hosts the CA may issue for (.example.com for subdomains)"),
+		),
+		mcp.WithString("excludedUriDomains",
+			mcp.Description("Comma-separated URI hosts the CA must never issue for"),
 		),
 	)
 }
@@ -82,20 +321,69 @@ func generateServerCertTool() mcp.Tool {
 			mcp.Description("Organization name for the server certificate"),
 		),
 		mcp.WithString("commonName",
-			mcp.Required(),
-			mcp.Description("Common Name (CN) for the server certificate"),
+			mcp.Description("Common Name (CN) for the server certificate (may be omitted when spiffeId is set)"),
 		),
 		mcp.WithString("country",
 			mcp.Required(),
-			mcp.Description("Country code (e.g., US, DE, UK)"),
+			mcp.Description("ISO 3166-1 alpha-2 country code (e.g., US, DE, GB)"),
 		),
 		mcp.WithString("locality",
 			mcp.Required(),
 			mcp.Description("City or locality name"),
 		),
 		mcp.WithNumber("expiryDays",
-			mcp.Required(),
-			mcp.Description("Number of days the certificate is valid"),
+			mcp.Description("Number of days the certificate is valid (default 365 or the server's default validity, ignored when validity or notAfter is set)"),
+		),
+		mcp.WithString("validity",
+			mcp.Description("Validity duration such as 15m, 6h or 90d; overrides expiryDays"),
+		),
+		mcp.WithString("notBefore",
+			mcp.Description("Explicit start of the validity period (RFC 3339, e.g. 2025-01-01T00:00:00Z); defaults to now minus a few minutes to tolerate clock skew"),
+		),
+		mcp.WithString("notAfter",
+			mcp.Description("Explicit end of the validity period (RFC 3339); overrides validity and expiryDays"),
+		),
+		mcp.WithString("organizationalUnit",
+			mcp.Description("Organizational Unit (OU)"),
+		),
+		mcp.WithString("province",
+			mcp.Description("State or province name"),
+		),
+		mcp.WithString("streetAddress",
+			mcp.Description("Street address"),
+		),
+		mcp.WithString("postalCode",
+			mcp.Description("Postal code"),
+		),
+		mcp.WithString("serialNumber",
+			mcp.Description("Subject serialNumber attribute (not the certificate serial number)"),
+		),
+		mcp.WithString("dn",
+			mcp.Description("RFC 4514 distinguished name (e.g., CN=foo,OU=bar,O=baz); attributes given here take precedence over the individual fields and may use dotted OIDs as attribute types"),
+		),
+		mcp.WithString("extraAttributes",
+			mcp.Description("JSON array of additional subject attributes, e.g. [{\"oid\":\"1.3.6.1.4.1.99999.2\",\"value\":\"extra\"}]; standard types such as O or C must use their own fields"),
+		),
+		mcp.WithString("keyUsage",
+			mcp.Description("Comma-separated key usages overriding the type's default (digitalSignature, plus keyAgreement for server and peer): digitalSignature, contentCommitment, keyEncipherment, dataEncipherment, keyAgreement, encipherOnly, decipherOnly"),
+		),
+		mcp.WithString("extKeyUsage",
+			mcp.Description("Comma-separated extended key usages overriding the defaults of the certificate type: any, serverAuth, clientAuth, codeSigning, emailProtection, ipsecEndSystem, ipsecTunnel, ipsecUser, timeStamping, ocspSigning or dotted OIDs"),
+		),
+		mcp.WithString("serialStrategy",
+			mcp.Description("How the certificate serial number is chosen: random (default), sequential (per CA counter) or specified (uses serial)"),
+		),
+		mcp.WithString("serial",
+			mcp.Description("Certificate serial number in decimal or hex (0x2a or 00:2a:ff), required for serialStrategy specified"),
+		),
+		mcp.WithString("extensions",
+			mcp.Description("JSON array of custom extensions, e.g. [{\"oid\":\"1.3.6.1.4.1.311.20.2\",\"type\":\"bmp\",\"value\":\"WebServer\"}]. type is der (hex DER, default), utf8, ia5, printable, bmp, octets (hex), integer, boolean, null or oid; critical marks the extension critical. Extensions certgen sets itself (key identifiers, key usage, SANs, basic constraints, name constraints, certificate policies, extended key usage, TLS feature) are rejected"),
+		),
+		mcp.WithString("certificatePolicies",
+			mcp.Description("JSON array of certificate policies with optional CPS URIs, e.g. [{\"oid\":\"2.23.140.1.2.1\",\"cpsUris\":[\"https://pki.example/cps\"]}]"),
+		),
+		mcp.WithBoolean("mustStaple",
+			mcp.Description("Add the TLS feature extension requiring OCSP stapling (Must-Staple)"),
 		),
 		mcp.WithString("dnsNames",
 			mcp.Description("Comma-separated list of DNS names (e.g., localhost,example.com)"),
@@ -103,6 +391,15 @@ func generateServerCertTool() mcp.Tool {
 		mcp.WithString("ipAddresses",
 			mcp.Description("Comma-separated list of IP addresses (e.g., 127.0.0.1,192.168.1.1)"),
 		),
+		mcp.WithString("uris",
+			mcp.Description("Comma-separated list of URI SANs (e.g., https://example.com/service)"),
+		),
+		mcp.WithString("emailAddresses",
+			mcp.Description("Comma-separated list of email address SANs (e.g., alice@example.com)"),
+		),
+		mcp.WithString("spiffeId",
+			mcp.Description("SPIFFE ID (e.g., spiffe://example.org/ns/default/sa/web); builds an X.509-SVID with this ID as the only URI SAN, commonName may be empty"),
+		),
 	)
 }
 
@@ -123,140 +420,793 @@ func generateClientCertTool() mcp.Tool {
 			mcp.Description("Organization name for the client certificate"),
 		),
 		mcp.WithString("commonName",
+			mcp.Description("Common Name (CN) for the client certificate (may be omitted when spiffeId is set)"),
+		),
+		mcp.WithString("country",
+			mcp.Required(),
+			mcp.Description("ISO 3166-1 alpha-2 country code (e.g., US, DE, GB)"),
+		),
+		mcp.WithString("locality",
+			mcp.Required(),
+			mcp.Description("City or locality name"),
+		),
+		mcp.WithNumber("expiryDays",
+			mcp.Description("Number of days the certificate is valid (default 365 or the server's default validity, ignored when validity or notAfter is set)"),
+		),
+		mcp.WithString("validity",
+			mcp.Description("Validity duration such as 15m, 6h or 90d; overrides expiryDays"),
+		),
+		mcp.WithString("notBefore",
+			mcp.Description("Explicit start of the validity period (RFC 3339, e.g. 2025-01-01T00:00:00Z); defaults to now minus a few minutes to tolerate clock skew"),
+		),
+		mcp.WithString("notAfter",
+			mcp.Description("Explicit end of the validity period (RFC 3339); overrides validity and expiryDays"),
+		),
+		mcp.WithString("organizationalUnit",
+			mcp.Description("Organizational Unit (OU)"),
+		),
+		mcp.WithString("province",
+			mcp.Description("State or province name"),
+		),
+		mcp.WithString("streetAddress",
+			mcp.Description("Street address"),
+		),
+		mcp.WithString("postalCode",
+			mcp.Description("Postal code"),
+		),
+		mcp.WithString("serialNumber",
+			mcp.Description("Subject serialNumber attribute (not the certificate serial number)"),
+		),
+		mcp.WithString("dn",
+			mcp.Description("RFC 4514 distinguished name (e.g., CN=foo,OU=bar,O=baz); attributes given here take precedence over the individual fields and may use dotted OIDs as attribute types"),
+		),
+		mcp.WithString("extraAttributes",
+			mcp.Description("JSON array of additional subject attributes, e.g. [{\"oid\":\"1.3.6.1.4.1.99999.2\",\"value\":\"extra\"}]; standard types such as O or C must use their own fields"),
+		),
+		mcp.WithString("keyUsage",
+			mcp.Description("Comma-separated key usages overriding the type's default (digitalSignature, plus keyAgreement for server and peer): digitalSignature, contentCommitment, keyEncipherment, dataEncipherment, keyAgreement, encipherOnly, decipherOnly"),
+		),
+		mcp.WithString("extKeyUsage",
+			mcp.Description("Comma-separated extended key usages overriding the defaults of the certificate type: any, serverAuth, clientAuth, codeSigning, emailProtection, ipsecEndSystem, ipsecTunnel, ipsecUser, timeStamping, ocspSigning or dotted OIDs"),
+		),
+		mcp.WithString("serialStrategy",
+			mcp.Description("How the certificate serial number is chosen: random (default), sequential (per CA counter) or specified (uses serial)"),
+		),
+		mcp.WithString("serial",
+			mcp.Description("Certificate serial number in decimal or hex (0x2a or 00:2a:ff), required for serialStrategy specified"),
+		),
+		mcp.WithString("extensions",
+			mcp.Description("JSON array of custom extensions, e.g. [{\"oid\":\"1.3.6.1.4.1.311.20.2\",\"type\":\"bmp\",\"value\":\"WebServer\"}]. type is der (hex DER, default), utf8, ia5, printable, bmp, octets (hex), integer, boolean, null or oid; critical marks the extension critical. Extensions certgen sets itself (key identifiers, key usage, SANs, basic constraints, name constraints, certificate policies, extended key usage, TLS feature) are rejected"),
+		),
+		mcp.WithString("certificatePolicies",
+			mcp.Description("JSON array of certificate policies with optional CPS URIs, e.g. [{\"oid\":\"2.23.140.1.2.1\",\"cpsUris\":[\"https://pki.example/cps\"]}]"),
+		),
+		mcp.WithBoolean("mustStaple",
+			mcp.Description("Add the TLS feature extension requiring OCSP stapling (Must-Staple)"),
+		),
+		mcp.WithString("uris",
+			mcp.Description("Comma-separated list of URI SANs (e.g., https://example.com/service)"),
+		),
+		mcp.WithString("emailAddresses",
+			mcp.Description("Comma-separated list of email address SANs (e.g., alice@example.com)"),
+		),
+		mcp.WithString("spiffeId",
+			mcp.Description("SPIFFE ID (e.g., spiffe://example.org/ns/default/sa/web); builds an X.509-SVID with this ID as the only URI SAN, commonName may be empty"),
+		),
+	)
+}
+
+// generatePeerCertTool defines the generate_peer_certificate tool schema.
+func generatePeerCertTool() mcp.Tool {
+	return mcp.NewTool("generate_peer_certificate",
+		mcp.WithDescription("Generate a peer certificate usable for both server and client authentication (e.g. etcd, Consul, Kafka cluster members), signed by the provided CA"),
+		mcp.WithString("caCert",
+			mcp.Required(),
+			mcp.Description("PEM encoded CA certificate"),
+		),
+		mcp.WithString("caKey",
 			mcp.Required(),
-			mcp.Description("Common Name (CN) for the client certificate"),
+			mcp.Description("PEM encoded CA private key"),
+		),
+		mcp.WithString("organization",
+			mcp.Required(),
+			mcp.Description("Organization name for the peer certificate"),
+		),
+		mcp.WithString("commonName",
+			mcp.Description("Common Name (CN) for the peer certificate (may be omitted when spiffeId is set)"),
 		),
 		mcp.WithString("country",
 			mcp.Required(),
-			mcp.Description("Country code (e.g., US, DE, UK)"),
+			mcp.Description("ISO 3166-1 alpha-2 country code (e.g., US, DE, GB)"),
 		),
 		mcp.WithString("locality",
 			mcp.Required(),
 			mcp.Description("City or locality name"),
 		),
 		mcp.WithNumber("expiryDays",
+			mcp.Description("Number of days the certificate is valid (default 365 or the server's default validity, ignored when validity or notAfter is set)"),
+		),
+		mcp.WithString("validity",
+			mcp.Description("Validity duration such as 15m, 6h or 90d; overrides expiryDays"),
+		),
+		mcp.WithString("notBefore",
+			mcp.Description("Explicit start of the validity period (RFC 3339, e.g. 2025-01-01T00:00:00Z); defaults to now minus a few minutes to tolerate clock skew"),
+		),
+		mcp.WithString("notAfter",
+			mcp.Description("Explicit end of the validity period (RFC 3339); overrides validity and expiryDays"),
+		),
+		mcp.WithString("organizationalUnit",
+			mcp.Description("Organizational Unit (OU)"),
+		),
+		mcp.WithString("province",
+			mcp.Description("State or province name"),
+		),
+		mcp.WithString("streetAddress",
+			mcp.Description("Street address"),
+		),
+		mcp.WithString("postalCode",
+			mcp.Description("Postal code"),
+		),
+		mcp.WithString("serialNumber",
+			mcp.Description("Subject serialNumber attribute (not the certificate serial number)"),
+		),
+		mcp.WithString("dn",
+			mcp.Description("RFC 4514 distinguished name (e.g., CN=foo,OU=bar,O=baz); attributes given here take precedence over the individual fields and may use dotted OIDs as attribute types"),
+		),
+		mcp.WithString("extraAttributes",
+			mcp.Description("JSON array of additional subject attributes, e.g. [{\"oid\":\"1.3.6.1.4.1.99999.2\",\"value\":\"extra\"}]; standard types such as O or C must use their own fields"),
+		),
+		mcp.WithString("keyUsage",
+			mcp.Description("Comma-separated key usages overriding the type's default (digitalSignature, plus keyAgreement for server and peer): digitalSignature, contentCommitment, keyEncipherment, dataEncipherment, keyAgreement, encipherOnly, decipherOnly"),
+		),
+		mcp.WithString("extKeyUsage",
+			mcp.Description("Comma-separated extended key usages overriding the defaults of the certificate type: any, serverAuth, clientAuth, codeSigning, emailProtection, ipsecEndSystem, ipsecTunnel, ipsecUser, timeStamping, ocspSigning or dotted OIDs"),
+		),
+		mcp.WithString("serialStrategy",
+			mcp.Description("How the certificate serial number is chosen: random (default), sequential (per CA counter) or specified (uses serial)"),
+		),
+		mcp.WithString("serial",
+			mcp.Description("Certificate serial number in decimal or hex (0x2a or 00:2a:ff), required for serialStrategy specified"),
+		),
+		mcp.WithString("extensions",
+			mcp.Description("JSON array of custom extensions, e.g. [{\"oid\":\"1.3.6.1.4.1.311.20.2\",\"type\":\"bmp\",\"value\":\"WebServer\"}]. type is der (hex DER, default), utf8, ia5, printable, bmp, octets (hex), integer, boolean, null or oid; critical marks the extension critical. Extensions certgen sets itself (key identifiers, key usage, SANs, basic constraints, name constraints, certificate policies, extended key usage, TLS feature) are rejected"),
+		),
+		mcp.WithString("certificatePolicies",
+			mcp.Description("JSON array of certificate policies with optional CPS URIs, e.g. [{\"oid\":\"2.23.140.1.2.1\",\"cpsUris\":[\"https://pki.example/cps\"]}]"),
+		),
+		mcp.WithBoolean("mustStaple",
+			mcp.Description("Add the TLS feature extension requiring OCSP stapling (Must-Staple)"),
+		),
+		mcp.WithString("dnsNames",
+			mcp.Description("Comma-separated list of DNS names (e.g., etcd-0.cluster.local,localhost)"),
+		),
+		mcp.WithString("ipAddresses",
+			mcp.Description("Comma-separated list of IP addresses (e.g., 127.0.0.1,10.0.0.5)"),
+		),
+		mcp.WithString("uris",
+			mcp.Description("Comma-separated list of URI SANs (e.g., https://example.com/service)"),
+		),
+		mcp.WithString("emailAddresses",
+			mcp.Description("Comma-separated list of email address SANs (e.g., alice@example.com)"),
+		),
+		mcp.WithString("spiffeId",
+			mcp.Description("SPIFFE ID (e.g., spiffe://example.org/ns/default/sa/web); builds an X.509-SVID with this ID as the only URI SAN, commonName may be empty"),
+		),
+	)
+}
+
+// generateBrokenCertsTool defines the generate_broken_certificates tool schema.
+func generateBrokenCertsTool() mcp.Tool {
+	return mcp.NewTool("generate_broken_certificates",
+		mcp.WithDescription("Generate deliberately invalid server certificates for negative TLS client tests, one per requested defect"),
+		mcp.WithString("caCert",
+			mcp.Required(),
+			mcp.Description("PEM encoded CA certificate"),
+		),
+		mcp.WithString("caKey",
+			mcp.Required(),
+			mcp.Description("PEM encoded CA private key"),
+		),
+		mcp.WithString("defects",
+			mcp.Description("Comma-separated list of defects (expired, not-yet-valid, wrong-ca, hostname-mismatch, missing-eku, ca-as-leaf, bad-signature), each at most once; defaults to all"),
+		),
+		mcp.WithString("commonName",
 			mcp.Required(),
-			mcp.Description("Number of days the certificate is valid"),
+			mcp.Description("Common Name (CN) for the certificates"),
+		),
+		mcp.WithString("dnsNames",
+			mcp.Description("Comma-separated list of DNS names the certificates would be valid for without the defect (e.g., localhost)"),
+		),
+		mcp.WithString("ipAddresses",
+			mcp.Description("Comma-separated list of IP addresses the certificates would be valid for without the defect (e.g., 127.0.0.1)"),
+		),
+		mcp.WithNumber("expiryDays",
+			mcp.Description("Number of days the certificates are valid (default 365 or the server's default validity)"),
+		),
+	)
+}
+
+// inspectCertTool defines the inspect_certificate tool schema.
+func inspectCertTool() mcp.Tool {
+	return mcp.NewTool("inspect_certificate",
+		mcp.WithDescription("Summarize a certificate: subject, issuer, serial, validity, key identifiers, SANs, certificate policies, Must-Staple and all extensions"),
+		mcp.WithString("certificate",
+			mcp.Required(),
+			mcp.Description("PEM encoded certificate"),
+		),
+	)
+}
+
+// generateSSHCATool defines the generate_ssh_ca tool schema.
+func generateSSHCATool() mcp.Tool {
+	return mcp.NewTool("generate_ssh_ca",
+		mcp.WithDescription("Generate an Ed25519 OpenSSH certificate authority key pair, with the authorized_keys and known_hosts @cert-authority lines that trust it"),
+		mcp.WithString("comment",
+			mcp.Description("Comment stored in the public key (e.g., dev-ssh-ca)"),
+		),
+		mcp.WithString("hostPatterns",
+			mcp.Description("Comma-separated host patterns the CA is trusted for in the known_hosts line (e.g., *.dev.example); defaults to *"),
+		),
+	)
+}
+
+// signSSHCertTool defines the sign_ssh_certificate tool schema.
+func signSSHCertTool() mcp.Tool {
+	return mcp.NewTool("sign_ssh_certificate",
+		mcp.WithDescription("Sign an OpenSSH user or host certificate with an SSH CA, generating an Ed25519 key pair unless a public key is given"),
+		mcp.WithString("caKey",
+			mcp.Required(),
+			mcp.Description("OpenSSH encoded CA private key"),
+		),
+		mcp.WithString("certType",
+			mcp.Description("Certificate type: user (default) or host"),
+		),
+		mcp.WithString("principals",
+			mcp.Required(),
+			mcp.Description("Comma-separated user names (user certificates) or host names (host certificates) the certificate is valid for"),
+		),
+		mcp.WithString("keyId",
+			mcp.Description("Key ID logged by sshd when the certificate is used; defaults to the first principal"),
+		),
+		mcp.WithString("validity",
+			mcp.Description("Validity duration such as 8h or 30d"),
+		),
+		mcp.WithString("validAfter",
+			mcp.Description("Explicit start of the validity period (RFC 3339); defaults to now minus a few minutes to tolerate clock skew"),
+		),
+		mcp.WithString("validBefore",
+			mcp.Description("Explicit end of the validity period (RFC 3339); overrides validity"),
+		),
+		mcp.WithString("criticalOptions",
+			mcp.Description("JSON object of critical options for user certificates, e.g. {\"force-command\":\"/usr/bin/backup\",\"source-address\":\"10.0.0.0/8\"}"),
+		),
+		mcp.WithString("extensions",
+			mcp.Description("JSON object of extensions for user certificates, e.g. {\"permit-pty\":\"\"}; defaults to the ssh-keygen defaults (permit-pty, permit-agent-forwarding, permit-port-forwarding, permit-X11-forwarding, permit-user-rc)"),
+		),
+		mcp.WithString("serial",
+			mcp.Description("Certificate serial number as a decimal string, up to 18446744073709551615; random when omitted"),
+		),
+		mcp.WithString("publicKey",
+			mcp.Description("Existing public key to certify in authorized_keys format (e.g., the contents of id_ed25519.pub)"),
+		),
+		mcp.WithString("comment",
+			mcp.Description("Comment stored with a generated key pair"),
 		),
 	)
 }
 
 // handleGenerateCA handles the generate_ca tool call.
-func handleGenerateCA(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func (iss *issuer) handleGenerateCA(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	org := req.GetString("organization", "")
 	cn := req.GetString("commonName", "")
 	country := req.GetString("country", "")
 	locality := req.GetString("locality", "")
-	expiryDays := req.GetInt("expiryDays", 365)
+	expiryDays := iss.expiryDays(req)
+	notBefore, err := getTime(req, "notBefore")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	notAfter, err := getTime(req, "notAfter")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
 
 	config := certificate.CAConfig{
-		Organization: org,
-		CommonName:   cn,
-		Country:      country,
-		Locality:     locality,
-		ExpiryDays:   expiryDays,
+		Organization:       org,
+		CommonName:         cn,
+		Country:            country,
+		Locality:           locality,
+		ExpiryDays:         expiryDays,
+		Validity:           req.GetString("validity", ""),
+		NotBefore:          notBefore,
+		NotAfter:           notAfter,
+		OrganizationalUnit: req.GetString("organizationalUnit", ""),
+		Province:           req.GetString("province", ""),
+		StreetAddress:      req.GetString("streetAddress", ""),
+		PostalCode:         req.GetString("postalCode", ""),
+		SerialNumber:       req.GetString("serialNumber", ""),
+		DN:                 req.GetString("dn", ""),
+		Serial:             req.GetString("serial", ""),
+		MustStaple:         req.GetBool("mustStaple", false),
+	}
+	if err := getJSON(req, "extraAttributes", &config.ExtraAttributes); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := getJSON(req, "extensions", &config.Extensions); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := getJSON(req, "certificatePolicies", &config.CertificatePolicies); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 
+	config.NameConstraints = certificate.NameConstraints{
+		PermittedDNSDomains:     splitList(req.GetString("permittedDnsDomains", "")),
+		ExcludedDNSDomains:      splitList(req.GetString("excludedDnsDomains", "")),
+		PermittedIPRanges:       splitList(req.GetString("permittedIpRanges", "")),
+		ExcludedIPRanges:        splitList(req.GetString("excludedIpRanges", "")),
+		PermittedEmailAddresses: splitList(req.GetString("permittedEmailAddresses", "")),
+		ExcludedEmailAddresses:  splitList(req.GetString("excludedEmailAddresses", "")),
+		PermittedURIDomains:     splitList(req.GetString("permittedUriDomains", "")),
+		ExcludedURIDomains:      splitList(req.GetString("excludedUriDomains", "")),
+	}
+	iss.defaults.ApplyCA(&config)
+
+	if err := config.Validate(); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	start := time.Now()
 	bundle, err := certificate.GenerateCA(config)
 	if err != nil {
 		return mcp.NewToolResultError("failed to generate CA: " + err.Error()), nil
 	}
+	iss.metrics.ObserveCertificate(audit.SourceMCP, "ca", bundle.CertPEM, start)
+	if err := iss.record(ctx, audit.CertificateEvent(audit.ActionCreateCA, bundle.CertPEM, nil)); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
 
-	response := CAResponse{
-		Certificate: string(bundle.CertPEM),
-		PrivateKey:  string(bundle.KeyPEM),
+	response, err := NewCAResponse(bundle)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 
 	return mcp.NewToolResultJSON(response)
 }
 
 // handleGenerateServerCert handles the generate_server_certificate tool call.
-func handleGenerateServerCert(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func (iss *issuer) handleGenerateServerCert(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return iss.generateCertWithSANs(ctx, req, certificate.CertTypeServer)
+}
+
+// handleGeneratePeerCert handles the generate_peer_certificate tool call.
+func (iss *issuer) handleGeneratePeerCert(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return iss.generateCertWithSANs(ctx, req, certificate.CertTypePeer)
+}
+
+// generateCertWithSANs generates a server or peer certificate including DNS and IP SANs.
+func (iss *issuer) generateCertWithSANs(ctx context.Context, req mcp.CallToolRequest, certType certificate.CertType) (*mcp.CallToolResult, error) {
 	caCert := req.GetString("caCert", "")
 	caKey := req.GetString("caKey", "")
+	if err := audit.AuthorizeCA(ctx, iss.auth, iss.audit, []byte(caCert)); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
 	org := req.GetString("organization", "")
 	cn := req.GetString("commonName", "")
 	country := req.GetString("country", "")
 	locality := req.GetString("locality", "")
-	expiryDays := req.GetInt("expiryDays", 365)
-	dnsNamesStr := req.GetString("dnsNames", "")
-	ipAddressesStr := req.GetString("ipAddresses", "")
-
-	var dnsNames []string
-	if dnsNamesStr != "" {
-		dnsNames = strings.Split(dnsNamesStr, ",")
-		for i := range dnsNames {
-			dnsNames[i] = strings.TrimSpace(dnsNames[i])
-		}
+	expiryDays := iss.expiryDays(req)
+	notBefore, err := getTime(req, "notBefore")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
 	}
-
-	var ipAddresses []string
-	if ipAddressesStr != "" {
-		ipAddresses = strings.Split(ipAddressesStr, ",")
-		for i := range ipAddresses {
-			ipAddresses[i] = strings.TrimSpace(ipAddresses[i])
-		}
+	notAfter, err := getTime(req, "notAfter")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
 	}
+	dnsNames := splitList(req.GetString("dnsNames", ""))
+	ipAddresses := splitList(req.GetString("ipAddresses", ""))
+	uris := splitList(req.GetString("uris", ""))
+	emailAddresses := splitList(req.GetString("emailAddresses", ""))
+	spiffeID := req.GetString("spiffeId", "")
 
 	config := certificate.CertConfig{
-		Organization: org,
-		CommonName:   cn,
-		Country:      country,
-		Locality:     locality,
-		ExpiryDays:   expiryDays,
-		IsClient:     false,
-		DNSNames:     dnsNames,
-		IPAddresses:  ipAddresses,
+		Organization:       org,
+		CommonName:         cn,
+		Country:            country,
+		Locality:           locality,
+		ExpiryDays:         expiryDays,
+		Validity:           req.GetString("validity", ""),
+		NotBefore:          notBefore,
+		NotAfter:           notAfter,
+		Type:               certType,
+		DNSNames:           dnsNames,
+		IPAddresses:        ipAddresses,
+		URIs:               uris,
+		EmailAddresses:     emailAddresses,
+		SPIFFEID:           spiffeID,
+		OrganizationalUnit: req.GetString("organizationalUnit", ""),
+		Province:           req.GetString("province", ""),
+		StreetAddress:      req.GetString("streetAddress", ""),
+		PostalCode:         req.GetString("postalCode", ""),
+		SerialNumber:       req.GetString("serialNumber", ""),
+		DN:                 req.GetString("dn", ""),
+		KeyUsage:           splitList(req.GetString("keyUsage", "")),
+		ExtKeyUsage:        splitList(req.GetString("extKeyUsage", "")),
+		SerialStrategy:     certificate.SerialStrategy(req.GetString("serialStrategy", "")),
+		Serial:             req.GetString("serial", ""),
+		SerialCounter:      iss.serials,
+		MustStaple:         req.GetBool("mustStaple", false),
+	}
+	if err := getJSON(req, "extraAttributes", &config.ExtraAttributes); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := getJSON(req, "extensions", &config.Extensions); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := getJSON(req, "certificatePolicies", &config.CertificatePolicies); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	iss.defaults.ApplyCert(&config)
+
+	if err := config.Validate(); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	bundle, err := certificate.GenerateCert(config, []byte(caCert), []byte(caKey))
+	start := time.Now()
+	bundle, err := certificate.GenerateCertWithPolicy(config, iss.policies.Lookup([]byte(caCert)), []byte(caCert), []byte(caKey))
 	if err != nil {
-		return mcp.NewToolResultError("failed to generate server certificate: " + err.Error()), nil
+		return mcp.NewToolResultError("failed to generate " + string(certType) + " certificate: " + err.Error()), nil
+	}
+	iss.metrics.ObserveCertificate(audit.SourceMCP, string(certType), bundle.CertPEM, start)
+	if err := iss.recordIssued(ctx, bundle.CertPEM, caCert, string(certType), ""); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	response := CertResponse{
-		Certificate:  string(bundle.CertPEM),
-		PrivateKey:   string(bundle.KeyPEM),
-		UnifiedPEM:   string(bundle.UnifiedPEM()),
-		ChainPEM:     string(bundle.ChainPEM([]byte(caCert))),
-		FullChainPEM: string(bundle.FullChainPEM([]byte(caCert))),
+	response, err := NewCertResponse(bundle, []byte(caCert))
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 
 	return mcp.NewToolResultJSON(response)
 }
 
 // handleGenerateClientCert handles the generate_client_certificate tool call.
-func handleGenerateClientCert(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func (iss *issuer) handleGenerateClientCert(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	caCert := req.GetString("caCert", "")
 	caKey := req.GetString("caKey", "")
+	if err := audit.AuthorizeCA(ctx, iss.auth, iss.audit, []byte(caCert)); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
 	org := req.GetString("organization", "")
 	cn := req.GetString("commonName", "")
 	country := req.GetString("country", "")
 	locality := req.GetString("locality", "")
-	expiryDays := req.GetInt("expiryDays", 365)
+	expiryDays := iss.expiryDays(req)
+	notBefore, err := getTime(req, "notBefore")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	notAfter, err := getTime(req, "notAfter")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	uris := splitList(req.GetString("uris", ""))
+	emailAddresses := splitList(req.GetString("emailAddresses", ""))
+	spiffeID := req.GetString("spiffeId", "")
 
 	config := certificate.CertConfig{
-		Organization: org,
-		CommonName:   cn,
-		Country:      country,
-		Locality:     locality,
-		ExpiryDays:   expiryDays,
-		IsClient:     true,
+		Organization:       org,
+		CommonName:         cn,
+		Country:            country,
+		Locality:           locality,
+		ExpiryDays:         expiryDays,
+		Validity:           req.GetString("validity", ""),
+		NotBefore:          notBefore,
+		NotAfter:           notAfter,
+		Type:               certificate.CertTypeClient,
+		URIs:               uris,
+		EmailAddresses:     emailAddresses,
+		SPIFFEID:           spiffeID,
+		OrganizationalUnit: req.GetString("organizationalUnit", ""),
+		Province:           req.GetString("province", ""),
+		StreetAddress:      req.GetString("streetAddress", ""),
+		PostalCode:         req.GetString("postalCode", ""),
+		SerialNumber:       req.GetString("serialNumber", ""),
+		DN:                 req.GetString("dn", ""),
+		KeyUsage:           splitList(req.GetString("keyUsage", "")),
+		ExtKeyUsage:        splitList(req.GetString("extKeyUsage", "")),
+		SerialStrategy:     certificate.SerialStrategy(req.GetString("serialStrategy", "")),
+		Serial:             req.GetString("serial", ""),
+		SerialCounter:      iss.serials,
+		MustStaple:         req.GetBool("mustStaple", false),
+	}
+	if err := getJSON(req, "extraAttributes", &config.ExtraAttributes); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := getJSON(req, "extensions", &config.Extensions); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := getJSON(req, "certificatePolicies", &config.CertificatePolicies); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
 	}
+	iss.defaults.ApplyCert(&config)
 
-	bundle, err := certificate.GenerateCert(config, []byte(caCert), []byte(caKey))
+	if err := config.Validate(); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	start := time.Now()
+	bundle, err := certificate.GenerateCertWithPolicy(config, iss.policies.Lookup([]byte(caCert)), []byte(caCert), []byte(caKey))
 	if err != nil {
 		return mcp.NewToolResultError("failed to generate client certificate: " + err.Error()), nil
 	}
+	iss.metrics.ObserveCertificate(audit.SourceMCP, string(certificate.CertTypeClient), bundle.CertPEM, start)
+	if err := iss.recordIssued(ctx, bundle.CertPEM, caCert, string(certificate.CertTypeClient), ""); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	response, err := NewCertResponse(bundle, []byte(caCert))
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	return mcp.NewToolResultJSON(response)
+}
+
+// handleGenerateBrokenCerts handles the generate_broken_certificates tool call.
+func (iss *issuer) handleGenerateBrokenCerts(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	caCert := req.GetString("caCert", "")
+	caKey := req.GetString("caKey", "")
+	if err := audit.AuthorizeCA(ctx, iss.auth, iss.audit, []byte(caCert)); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	defects, err := certificate.ParseDefects(splitList(req.GetString("defects", "")))
+	if err != nil {
+		return mcp.NewToolResultError("defects: " + err.Error()), nil
+	}
 
-	response := CertResponse{
+	config := certificate.CertConfig{
+		CommonName:  req.GetString("commonName", ""),
+		ExpiryDays:  iss.expiryDays(req),
+		Type:        certificate.CertTypeServer,
+		DNSNames:    splitList(req.GetString("dnsNames", "")),
+		IPAddresses: splitList(req.GetString("ipAddresses", "")),
+	}
+	iss.defaults.ApplyCert(&config)
+
+	if err := iss.policies.Lookup([]byte(caCert)).Apply(&config); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	start := time.Now()
+	certs, err := certificate.GenerateBrokenCerts(config, defects, []byte(caCert), []byte(caKey))
+	if err != nil {
+		return mcp.NewToolResultError("failed to generate broken certificates: " + err.Error()), nil
+	}
+
+	var response BrokenCertsResponse
+	for _, broken := range certs {
+		iss.metrics.ObserveCertificate(audit.SourceMCP, "broken", broken.Bundle.CertPEM, start)
+		if err := iss.recordIssued(ctx, broken.Bundle.CertPEM, caCert, string(config.Type), string(broken.Defect)); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		response.Certificates = append(response.Certificates, BrokenCertResponse{
+			Defect:      string(broken.Defect),
+			Description: broken.Defect.Description(),
+			Certificate: string(broken.Bundle.CertPEM),
+			PrivateKey:  string(broken.Bundle.KeyPEM),
+			ChainPEM:    string(broken.Bundle.ChainPEM([]byte(caCert))),
+		})
+	}
+
+	return mcp.NewToolResultJSON(response)
+}
+
+// handleInspectCert handles the inspect_certificate tool call.
+func handleInspectCert(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	info, err := certificate.Inspect([]byte(req.GetString("certificate", "")))
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	return mcp.NewToolResultJSON(info)
+}
+
+// handleGenerateSSHCA handles the generate_ssh_ca tool call.
+func (iss *issuer) handleGenerateSSHCA(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	start := time.Now()
+	bundle, err := ssh.GenerateCA(ssh.CAConfig{
+		Comment:      req.GetString("comment", ""),
+		HostPatterns: splitList(req.GetString("hostPatterns", "")),
+	})
+	if err != nil {
+		return mcp.NewToolResultError("failed to generate SSH CA: " + err.Error()), nil
+	}
+	iss.metrics.ObserveSSH(audit.SourceMCP, "ssh-ca", bundle.PublicKey, start)
+	fingerprint, _ := ssh.CAFingerprint(bundle.PrivateKey)
+	if err := iss.record(ctx, audit.Event{Action: audit.ActionCreateSSHCA, Fingerprint: fingerprint, Subject: req.GetString("comment", "")}); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	response := SSHCAResponse{
+		PrivateKey:         string(bundle.PrivateKey),
+		PublicKey:          string(bundle.PublicKey),
+		AuthorizedKeysLine: string(bundle.AuthorizedKeysLine),
+		KnownHostsLine:     string(bundle.KnownHostsLine),
+	}
+
+	return mcp.NewToolResultJSON(response)
+}
+
+// handleSignSSHCert handles the sign_ssh_certificate tool call.
+func (iss *issuer) handleSignSSHCert(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	certType, err := ssh.ParseCertType(req.GetString("certType", ""))
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	validAfter, err := getTime(req, "validAfter")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	validBefore, err := getTime(req, "validBefore")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	serial, err := getUint(req, "serial")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	config := ssh.CertConfig{
+		Type:        certType,
+		KeyID:       req.GetString("keyId", ""),
+		Principals:  splitList(req.GetString("principals", "")),
+		Validity:    req.GetString("validity", ""),
+		ValidAfter:  validAfter,
+		ValidBefore: validBefore,
+		Serial:      serial,
+		PublicKey:   []byte(req.GetString("publicKey", "")),
+		Comment:     req.GetString("comment", ""),
+	}
+	if err := getJSON(req, "criticalOptions", &config.CriticalOptions); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := getJSON(req, "extensions", &config.Extensions); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := config.Validate(); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	caKey := []byte(req.GetString("caKey", ""))
+	fingerprint, _ := ssh.CAFingerprint(caKey)
+	if err := audit.AuthorizeFingerprint(ctx, iss.auth, iss.audit, fingerprint); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	start := time.Now()
+	bundle, err := ssh.SignCert(config, caKey)
+	if err != nil {
+		return mcp.NewToolResultError("failed to sign SSH certificate: " + err.Error()), nil
+	}
+	iss.metrics.ObserveSSH(audit.SourceMCP, "ssh-"+string(certType), bundle.Certificate, start)
+	if err := iss.record(ctx, audit.SSHCertificateEvent(bundle.Certificate, fingerprint)); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	response := SSHCertResponse{
+		Certificate: string(bundle.Certificate),
+		PublicKey:   string(bundle.PublicKey),
+		PrivateKey:  string(bundle.PrivateKey),
+		KeyFileName: bundle.KeyFileName(certType),
+	}
+
+	return mcp.NewToolResultJSON(response)
+}
+
+// recordIssued writes an audit event for a certificate signed by the PEM encoded CA certificate.
+func (iss *issuer) recordIssued(ctx context.Context, certPEM []byte, caCertPEM, profile, defect string) error {
+	event := audit.CertificateEvent(audit.ActionIssue, certPEM, []byte(caCertPEM))
+	event.Profile = profile
+	event.Defect = defect
+	return iss.record(ctx, event)
+}
+
+// NewCAResponse converts a CA certificate bundle into its response.
+func NewCAResponse(bundle *certificate.CertBundle) (CAResponse, error) {
+	privateJWK, err := bundle.PrivateJWK(nil)
+	if err != nil {
+		return CAResponse{}, err
+	}
+	publicJWKS, err := bundle.PublicJWKS(nil)
+	if err != nil {
+		return CAResponse{}, err
+	}
+
+	return CAResponse{
+		Certificate: string(bundle.CertPEM),
+		PrivateKey:  string(bundle.KeyPEM),
+		PrivateJWK:  privateJWK,
+		PublicJWKS:  publicJWKS,
+	}, nil
+}
+
+// NewCertResponse converts a leaf certificate bundle signed by the CA into its response.
+// The REST API returns the same shape.
+func NewCertResponse(bundle *certificate.CertBundle, caCertPEM []byte) (CertResponse, error) {
+	privateJWK, err := bundle.PrivateJWK(caCertPEM)
+	if err != nil {
+		return CertResponse{}, err
+	}
+	publicJWKS, err := bundle.PublicJWKS(caCertPEM)
+	if err != nil {
+		return CertResponse{}, err
+	}
+
+	return CertResponse{
 		Certificate:  string(bundle.CertPEM),
 		PrivateKey:   string(bundle.KeyPEM),
 		UnifiedPEM:   string(bundle.UnifiedPEM()),
-		ChainPEM:     string(bundle.ChainPEM([]byte(caCert))),
-		FullChainPEM: string(bundle.FullChainPEM([]byte(caCert))),
+		ChainPEM:     string(bundle.ChainPEM(caCertPEM)),
+		FullChainPEM: string(bundle.FullChainPEM(caCertPEM)),
+		PrivateJWK:   privateJWK,
+		PublicJWKS:   publicJWKS,
+	}, nil
+}
+
+// splitList splits a comma-separated list and trims whitespace around each entry.
+func splitList(s string) []string {
+	if s == "" {
+		return nil
 	}
+	items := strings.Split(s, ",")
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+	return items
+}
 
-	return mcp.NewToolResultJSON(response)
+// getJSON decodes an optional JSON encoded string argument into v, leaving v untouched when unset.
+func getJSON(req mcp.CallToolRequest, key string, v any) error {
+	s := req.GetString(key, "")
+	if s == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(s), v); err != nil {
+		return fmt.Errorf("%s: invalid JSON: %w", key, err)
+	}
+	return nil
 }
 
+// getUint reads an optional unsigned 64-bit integer argument, returning zero when unset. The
+// value must be a decimal string, since JSON numbers lose precision beyond 2^53.
+func getUint(req mcp.CallToolRequest, key string) (uint64, error) {
+	arg, ok := req.GetArguments()[key]
+	if !ok || arg == "" {
+		return 0, nil
+	}
+	s, ok := arg.(string)
+	if !ok {
+		return 0, fmt.Errorf("%s: must be a decimal string", key)
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: must be a decimal number between 0 and %d", key, uint64(math.MaxUint64))
+	}
+	return n, nil
+}
+
+// getTime reads an optional RFC 3339 timestamp argument, returning the zero time when unset.
+func getTime(req mcp.CallToolRequest, key string) (time.Time, error) {
+	s := req.GetString(key, "")
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: invalid RFC 3339 timestamp %q", key, s)
+	}
+	return t, nil
+}
@@ -0,0 +1,377 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pvormste/certgen/certificate"
+	"github.com/pvormste/certgen/internal/audit"
+	"github.com/pvormste/certgen/internal/metrics"
+	"github.com/pvormste/certgen/internal/ratelimit"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// callTool calls the tool through an in-process MCP client of s and returns its result
+func callTool(t *testing.T, ctx context.Context, s *server.MCPServer, name string, args map[string]any) *mcp.CallToolResult {
+	t.Helper()
+	c, err := client.NewInProcessClient(s)
+	if err != nil {
+		t.Fatalf("NewInProcessClient() error = %v", err)
+	}
+	defer c.Close()
+	if err := c.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if _, err := c.Initialize(ctx, mcp.InitializeRequest{}); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+
+	req := mcp.CallToolRequest{}
+	req.Params.Name = name
+	req.Params.Arguments = args
+	result, err := c.CallTool(ctx, req)
+	if err != nil {
+		t.Fatalf("CallTool(%s) error = %v", name, err)
+	}
+	return result
+}
+
+// resultText returns the text content of a tool result
+func resultText(t *testing.T, result *mcp.CallToolResult) string {
+	t.Helper()
+	if len(result.Content) != 1 {
+		t.Fatalf("Result has %d contents, want 1", len(result.Content))
+	}
+	text, ok := mcp.AsTextContent(result.Content[0])
+	if !ok {
+		t.Fatalf("Result content is %T, want text", result.Content[0])
+	}
+	return text.Text
+}
+
+// decodeResult decodes the JSON result of a successful tool call into v
+func decodeResult(t *testing.T, result *mcp.CallToolResult, v any) {
+	t.Helper()
+	text := resultText(t, result)
+	if result.IsError {
+		t.Fatalf("Tool call failed: %s", text)
+	}
+	if err := json.Unmarshal([]byte(text), v); err != nil {
+		t.Fatalf("Failed to decode result %s: %v", text, err)
+	}
+}
+
+// wantToolError checks that the tool call failed with an error containing want
+func wantToolError(t *testing.T, result *mcp.CallToolResult, want string) {
+	t.Helper()
+	text := resultText(t, result)
+	if !result.IsError {
+		t.Fatalf("Tool call succeeded with %s, want an error containing %q", text, want)
+	}
+	if !strings.Contains(text, want) {
+		t.Errorf("Tool error = %q, want it to contain %q", text, want)
+	}
+}
+
+func TestSignSSHCertSerial(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer(Options{})
+
+	var ca SSHCAResponse
+	decodeResult(t, callTool(t, ctx, s, "generate_ssh_ca", nil), &ca)
+
+	sign := func(serial any) *mcp.CallToolResult {
+		return callTool(t, ctx, s, "sign_ssh_certificate", map[string]any{
+			"caKey":      ca.PrivateKey,
+			"certType":   "user",
+			"principals": "alice",
+			"validity":   "1h",
+			"serial":     serial,
+		})
+	}
+
+	// Beyond 2^53, where a JSON number would be rounded
+	var cert SSHCertResponse
+	decodeResult(t, sign("18446744073709551615"), &cert)
+	pub, _, _, _, err := gossh.ParseAuthorizedKey([]byte(cert.Certificate))
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	if serial := pub.(*gossh.Certificate).Serial; serial != 18446744073709551615 {
+		t.Errorf("Serial = %d, want 18446744073709551615", serial)
+	}
+
+	wantToolError(t, sign("18446744073709551616"), "serial: must be a decimal number")
+	wantToolError(t, sign("-1"), "serial: must be a decimal number")
+	wantToolError(t, sign(float64(42)), "serial: must be a decimal string")
+}
+
+// generateCA generates a CA through the generate_ca tool of s
+func generateCA(t *testing.T, ctx context.Context, s *server.MCPServer) CAResponse {
+	t.Helper()
+	var ca CAResponse
+	decodeResult(t, callTool(t, ctx, s, "generate_ca", map[string]any{"commonName": "Test CA", "expiryDays": 1}), &ca)
+	return ca
+}
+
+func parseCert(t *testing.T, certPEM string) *x509.Certificate {
+	t.Helper()
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		t.Fatalf("No PEM block in %q", certPEM)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("ParseCertificate() error = %v", err)
+	}
+	return cert
+}
+
+func TestGeneratePeerCertificate(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer(Options{})
+	ca := generateCA(t, ctx, s)
+
+	var resp CertResponse
+	decodeResult(t, callTool(t, ctx, s, "generate_peer_certificate", map[string]any{
+		"caCert":      ca.Certificate,
+		"caKey":       ca.PrivateKey,
+		"commonName":  "etcd-1",
+		"dnsNames":    "etcd-1.internal, localhost",
+		"ipAddresses": "10.0.0.1",
+		"expiryDays":  1,
+	}), &resp)
+
+	cert := parseCert(t, resp.Certificate)
+	if !slices.Equal(cert.ExtKeyUsage, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}) {
+		t.Errorf("ExtKeyUsage = %v, want server and client authentication", cert.ExtKeyUsage)
+	}
+	if !slices.Equal(cert.DNSNames, []string{"etcd-1.internal", "localhost"}) || len(cert.IPAddresses) != 1 || cert.IPAddresses[0].String() != "10.0.0.1" {
+		t.Errorf("SANs = %v %v, want the requested names", cert.DNSNames, cert.IPAddresses)
+	}
+	if cert.Subject.CommonName != "etcd-1" || cert.Issuer.CommonName != "Test CA" {
+		t.Errorf("Subject %s, issuer %s", cert.Subject, cert.Issuer)
+	}
+	if !strings.HasSuffix(resp.ChainPEM, ca.Certificate) || resp.PrivateKey == "" {
+		t.Error("Expected the private key and a chain ending with the CA certificate")
+	}
+}
+
+func TestExtraAttributes(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer(Options{})
+	ca := generateCA(t, ctx, s)
+
+	hasExtra := func(cert *x509.Certificate) bool {
+		return slices.ContainsFunc(cert.Subject.Names, func(name pkix.AttributeTypeAndValue) bool {
+			return name.Type.String() == "1.3.6.1.4.1.99999.2" && name.Value == "extra"
+		})
+	}
+	extra := `[{"oid":"1.3.6.1.4.1.99999.2","value":"extra"}]`
+
+	var caResp CAResponse
+	decodeResult(t, callTool(t, ctx, s, "generate_ca", map[string]any{"commonName": "Extra CA", "extraAttributes": extra}), &caResp)
+	if cert := parseCert(t, caResp.Certificate); !hasExtra(cert) {
+		t.Errorf("CA subject %v lacks the extra attribute", cert.Subject.Names)
+	}
+
+	for _, tool := range []string{"generate_server_certificate", "generate_client_certificate", "generate_peer_certificate"} {
+		args := map[string]any{
+			"caCert": ca.Certificate, "caKey": ca.PrivateKey, "commonName": "app.example", "dnsNames": "app.example",
+			"extraAttributes": extra,
+		}
+		var resp CertResponse
+		decodeResult(t, callTool(t, ctx, s, tool, args), &resp)
+		if cert := parseCert(t, resp.Certificate); !hasExtra(cert) {
+			t.Errorf("%s: subject %v lacks the extra attribute", tool, cert.Subject.Names)
+		}
+
+		args["extraAttributes"] = `[{"oid":"O","value":"x"}]`
+		wantToolError(t, callTool(t, ctx, s, tool, args), "extraAttributes[0].oid")
+		args["extraAttributes"] = "{"
+		wantToolError(t, callTool(t, ctx, s, tool, args), "extraAttributes: invalid JSON")
+	}
+}
+
+func TestGenerateBrokenCertificates(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer(Options{})
+	ca := generateCA(t, ctx, s)
+
+	call := func(defects string) *mcp.CallToolResult {
+		return callTool(t, ctx, s, "generate_broken_certificates", map[string]any{
+			"caCert":     ca.Certificate,
+			"caKey":      ca.PrivateKey,
+			"defects":    defects,
+			"commonName": "localhost",
+			"dnsNames":   "localhost",
+		})
+	}
+
+	var resp BrokenCertsResponse
+	decodeResult(t, call("expired, missing-eku"), &resp)
+	if len(resp.Certificates) != 2 {
+		t.Fatalf("Got %d certificates, want 2", len(resp.Certificates))
+	}
+	expired, missingEKU := resp.Certificates[0], resp.Certificates[1]
+	if expired.Defect != "expired" || expired.Description == "" || !parseCert(t, expired.Certificate).NotAfter.Before(time.Now()) {
+		t.Errorf("Unexpected expired certificate: %+v", expired)
+	}
+	if missingEKU.Defect != "missing-eku" || slices.Contains(parseCert(t, missingEKU.Certificate).ExtKeyUsage, x509.ExtKeyUsageServerAuth) {
+		t.Errorf("Unexpected missing-eku certificate: %+v", missingEKU)
+	}
+
+	decodeResult(t, call(""), &resp)
+	if len(resp.Certificates) != len(certificate.Defects) {
+		t.Errorf("Got %d certificates without defects, want all %d", len(resp.Certificates), len(certificate.Defects))
+	}
+
+	wantToolError(t, call("expired,bogus"), `defects: unknown defect "bogus"`)
+	wantToolError(t, call("expired,expired"), `defects: defect "expired" is listed more than once`)
+}
+
+func TestSSHTools(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer(Options{})
+
+	var ca SSHCAResponse
+	decodeResult(t, callTool(t, ctx, s, "generate_ssh_ca", map[string]any{"comment": "test", "hostPatterns": "*.example.com"}), &ca)
+	if !strings.HasPrefix(ca.KnownHostsLine, "@cert-authority *.example.com ssh-ed25519 ") {
+		t.Errorf("KnownHostsLine = %q", ca.KnownHostsLine)
+	}
+	if !strings.HasPrefix(ca.AuthorizedKeysLine, "cert-authority ssh-ed25519 ") {
+		t.Errorf("AuthorizedKeysLine = %q", ca.AuthorizedKeysLine)
+	}
+	caKey, err := gossh.ParsePrivateKey([]byte(ca.PrivateKey))
+	if err != nil {
+		t.Fatalf("Failed to parse the CA key: %v", err)
+	}
+
+	var resp SSHCertResponse
+	decodeResult(t, callTool(t, ctx, s, "sign_ssh_certificate", map[string]any{
+		"caKey":      ca.PrivateKey,
+		"certType":   "host",
+		"principals": "web.example.com",
+		"validity":   "1h",
+	}), &resp)
+	pub, _, _, _, err := gossh.ParseAuthorizedKey([]byte(resp.Certificate))
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	cert := pub.(*gossh.Certificate)
+	if cert.CertType != gossh.HostCert || !slices.Equal(cert.ValidPrincipals, []string{"web.example.com"}) {
+		t.Errorf("Certificate type %d for %v, want a host certificate for web.example.com", cert.CertType, cert.ValidPrincipals)
+	}
+	if !bytes.Equal(cert.SignatureKey.Marshal(), caKey.PublicKey().Marshal()) {
+		t.Error("Certificate is not signed by the CA")
+	}
+	if resp.PrivateKey == "" || resp.KeyFileName != "ssh_host_ed25519_key" {
+		t.Errorf("Expected a generated host key, got file name %q", resp.KeyFileName)
+	}
+
+	sign := func(args map[string]any) *mcp.CallToolResult {
+		args["validity"] = "1h"
+		return callTool(t, ctx, s, "sign_ssh_certificate", args)
+	}
+	wantToolError(t, sign(map[string]any{"caKey": ca.PrivateKey, "certType": "robot", "principals": "alice"}), `unknown SSH certificate type "robot"`)
+	wantToolError(t, sign(map[string]any{"caKey": ca.PrivateKey, "certType": "user"}), "principals: at least one principal is required")
+	wantToolError(t, sign(map[string]any{"caKey": ca.PrivateKey, "certType": "user", "principals": "alice", "criticalOptions": "{"}), "criticalOptions: invalid JSON")
+	wantToolError(t, sign(map[string]any{"caKey": "not a key", "certType": "user", "principals": "alice"}), "invalid SSH CA key")
+}
+
+func TestToolValidationErrors(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer(Options{})
+	ca := generateCA(t, ctx, s)
+
+	wantToolError(t, callTool(t, ctx, s, "generate_ca", map[string]any{"commonName": "CA", "country": "Germany"}), "country:")
+	wantToolError(t, callTool(t, ctx, s, "generate_ca", map[string]any{"commonName": "CA", "notBefore": "tomorrow"}), `notBefore: invalid RFC 3339 timestamp "tomorrow"`)
+	wantToolError(t, callTool(t, ctx, s, "generate_server_certificate", map[string]any{
+		"caCert": "junk", "caKey": ca.PrivateKey, "commonName": "a.example", "dnsNames": "a.example",
+	}), "invalid CA")
+	wantToolError(t, callTool(t, ctx, s, "generate_server_certificate", map[string]any{
+		"caCert": ca.Certificate, "caKey": ca.PrivateKey, "commonName": "a.example", "ipAddresses": "not-an-ip",
+	}), "ipAddresses")
+	wantToolError(t, callTool(t, ctx, s, "generate_client_certificate", map[string]any{
+		"caCert": ca.Certificate, "caKey": ca.PrivateKey, "commonName": "alice", "serialStrategy": "sequential",
+	}), "sequential")
+}
+
+func TestToolMetrics(t *testing.T) {
+	ctx := context.Background()
+	registry := metrics.NewRegistry()
+	s := NewServer(Options{Metrics: registry})
+	generateCA(t, ctx, s)
+	callTool(t, ctx, s, "generate_ca", map[string]any{"commonName": "CA", "country": "Germany"})
+
+	if got := registry.ToolCalls.Value("generate_ca"); got != 2 {
+		t.Errorf("generate_ca calls = %v, want 2", got)
+	}
+	if got := registry.ToolErrors.Value("generate_ca"); got != 1 {
+		t.Errorf("generate_ca errors = %v, want 1", got)
+	}
+	if got := registry.CertificatesIssued.Value("ca", "ecdsa-p384", "mcp"); got != 1 {
+		t.Errorf("CAs issued = %v, want 1", got)
+	}
+}
+
+func TestToolPolicyErrors(t *testing.T) {
+	ctx := context.Background()
+	ca := generateCA(t, ctx, NewServer(Options{}))
+	fingerprint, err := certificate.PublicKeyFingerprint([]byte(ca.Certificate))
+	if err != nil {
+		t.Fatalf("PublicKeyFingerprint() error = %v", err)
+	}
+	s := NewServer(Options{Policies: certificate.PolicySet{fingerprint: {
+		MaxValidity:        "24h",
+		AllowedProfiles:    []certificate.CertType{certificate.CertTypeServer},
+		AllowedSANPatterns: []string{"*.internal.example"},
+	}}})
+
+	server := func(args map[string]any) *mcp.CallToolResult {
+		args["caCert"], args["caKey"] = ca.Certificate, ca.PrivateKey
+		return callTool(t, ctx, s, "generate_server_certificate", args)
+	}
+
+	var resp CertResponse
+	decodeResult(t, server(map[string]any{"commonName": "api.internal.example", "dnsNames": "api.internal.example", "validity": "12h"}), &resp)
+	if cert := parseCert(t, resp.Certificate); cert.DNSNames[0] != "api.internal.example" {
+		t.Errorf("DNSNames = %v", cert.DNSNames)
+	}
+
+	wantToolError(t, server(map[string]any{"commonName": "api.internal.example", "dnsNames": "api.internal.example", "expiryDays": 30}),
+		"validity: rejected by issuance policy: validity exceeds the maximum of 24h")
+	wantToolError(t, server(map[string]any{"commonName": "api.internal.example", "dnsNames": "api.example.com", "validity": "1h"}),
+		`dnsNames[0]: rejected by issuance policy: "api.example.com" does not match any allowed SAN pattern`)
+	wantToolError(t, callTool(t, ctx, s, "generate_client_certificate", map[string]any{
+		"caCert": ca.Certificate, "caKey": ca.PrivateKey, "commonName": "alice", "validity": "1h",
+	}), `type: rejected by issuance policy: certificate type "client" is not allowed`)
+	wantToolError(t, callTool(t, ctx, s, "generate_broken_certificates", map[string]any{
+		"caCert": ca.Certificate, "caKey": ca.PrivateKey, "commonName": "api.example.com", "dnsNames": "api.example.com",
+	}), "rejected by issuance policy")
+}
+
+func TestToolRateLimits(t *testing.T) {
+	ctx := audit.WithRemoteAddr(context.Background(), "192.0.2.1:1234")
+	s := NewServer(Options{Limits: ratelimit.New(ratelimit.Config{ClientRate: 0.001, ClientBurst: 1})})
+
+	decodeResult(t, callTool(t, ctx, s, "generate_ssh_ca", nil), &SSHCAResponse{})
+	wantToolError(t, callTool(t, ctx, s, "generate_ssh_ca", nil), "rate limit exceeded, retry after")
+	wantToolError(t, callTool(t, ctx, s, "generate_ca", map[string]any{"commonName": "CA"}), "rate limit exceeded, retry after")
+
+	// Other clients and tools that generate no keys are not limited
+	other := audit.WithRemoteAddr(context.Background(), "192.0.2.2:1234")
+	decodeResult(t, callTool(t, other, s, "generate_ssh_ca", nil), &SSHCAResponse{})
+	wantToolError(t, callTool(t, ctx, s, "inspect_certificate", map[string]any{"certificate": "junk"}), "failed to decode")
+}
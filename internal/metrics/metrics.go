@@ -0,0 +1,342 @@
+// Package metrics collects certgen's Prometheus metrics and serves them in the Prometheus
+// text exposition format. Each Registry holds its own collectors; a server passes its
+// registry to its MCP tools, so the HTTP endpoints and the MCP tools count into the same
+// series.
+package metrics
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// expiryWindows are the periods reported by certgen_certificates_expiring
+var expiryWindows = []struct {
+	label string
+	days  int64
+}{
+	{"7d", 7},
+	{"30d", 30},
+}
+
+// Registry holds the collectors of a server. A nil Registry records nothing.
+type Registry struct {
+	// CertificatesIssued counts created CAs and issued certificates
+	CertificatesIssued *CounterVec
+	// GenerationSeconds measures how long creating a CA or issuing a certificate took
+	GenerationSeconds *HistogramVec
+	// HTTPRequests and HTTPErrors count requests and 4xx/5xx responses per route
+	HTTPRequests *CounterVec
+	HTTPErrors   *CounterVec
+	// ToolCalls and ToolErrors count MCP tool calls and calls returning an error result
+	ToolCalls  *CounterVec
+	ToolErrors *CounterVec
+
+	expiring *expiryInventory
+}
+
+// NewRegistry creates a Registry with all collectors empty
+func NewRegistry() *Registry {
+	return &Registry{
+		CertificatesIssued: newCounterVec("certgen_certificates_issued_total",
+			"Created CAs and issued certificates by type, key algorithm and source (http or mcp)",
+			"type", "key_algorithm", "source"),
+		GenerationSeconds: newHistogramVec("certgen_generation_duration_seconds",
+			"Time to generate and sign a certificate, by type",
+			[]float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+			"type"),
+		HTTPRequests: newCounterVec("certgen_http_requests_total",
+			"HTTP requests by route",
+			"endpoint"),
+		HTTPErrors: newCounterVec("certgen_http_errors_total",
+			"HTTP responses with a 4xx or 5xx status by route and status code",
+			"endpoint", "code"),
+		ToolCalls: newCounterVec("certgen_mcp_tool_calls_total",
+			"MCP tool calls by tool",
+			"tool"),
+		ToolErrors: newCounterVec("certgen_mcp_tool_errors_total",
+			"MCP tool calls that returned an error by tool",
+			"tool"),
+		expiring: &expiryInventory{days: make(map[int64]int)},
+	}
+}
+
+// Handler serves all metrics of the registry
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var buf bytes.Buffer
+		if r != nil {
+			for _, c := range []interface{ write(io.Writer) }{
+				r.CertificatesIssued, r.GenerationSeconds, r.HTTPRequests, r.HTTPErrors, r.ToolCalls, r.ToolErrors, r.expiring,
+			} {
+				c.write(&buf)
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(buf.Bytes())
+	})
+}
+
+// ObserveRequest counts a request to the route and, when status is 4xx or 5xx, the error
+func (r *Registry) ObserveRequest(endpoint string, status int) {
+	if r == nil {
+		return
+	}
+	r.HTTPRequests.Inc(endpoint)
+	if status >= 400 {
+		r.HTTPErrors.Inc(endpoint, strconv.Itoa(status))
+	}
+}
+
+// ObserveToolCall counts a call of the MCP tool and, when failed, the error
+func (r *Registry) ObserveToolCall(tool string, failed bool) {
+	if r == nil {
+		return
+	}
+	r.ToolCalls.Inc(tool)
+	if failed {
+		r.ToolErrors.Inc(tool)
+	}
+}
+
+// ObserveCertificate counts a PEM encoded certificate of the type (e.g. "ca" or "server")
+// generated since start and adds it to the expiry inventory
+func (r *Registry) ObserveCertificate(source, certType string, certPEM []byte, start time.Time) {
+	if r == nil {
+		return
+	}
+	algorithm := "unknown"
+	if block, _ := pem.Decode(certPEM); block != nil {
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			algorithm = keyAlgorithm(cert.PublicKey)
+			r.expiring.add(cert.NotAfter)
+		}
+	}
+	r.observe(source, certType, algorithm, start)
+}
+
+// ObserveSSH counts an SSH CA ("ssh-ca") or an authorized_keys encoded SSH certificate
+// ("ssh-user", "ssh-host") created since start. key is the CA's public key or the certificate.
+func (r *Registry) ObserveSSH(source, certType string, key []byte, start time.Time) {
+	if r == nil {
+		return
+	}
+	algorithm := "unknown"
+	if pub, _, _, _, err := gossh.ParseAuthorizedKey(key); err == nil {
+		if cert, ok := pub.(*gossh.Certificate); ok {
+			pub = cert.Key
+			if cert.ValidBefore != gossh.CertTimeInfinity {
+				r.expiring.add(time.Unix(int64(cert.ValidBefore), 0))
+			}
+		}
+		if cryptoPub, ok := pub.(gossh.CryptoPublicKey); ok {
+			algorithm = keyAlgorithm(cryptoPub.CryptoPublicKey())
+		}
+	}
+	r.observe(source, certType, algorithm, start)
+}
+
+func (r *Registry) observe(source, certType, algorithm string, start time.Time) {
+	r.CertificatesIssued.Inc(certType, algorithm, source)
+	r.GenerationSeconds.Observe(time.Since(start).Seconds(), certType)
+}
+
+// keyAlgorithm names a public key like issuance policies do, e.g. "ecdsa-p384" or "rsa-2048"
+func keyAlgorithm(pub crypto.PublicKey) string {
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		return "ecdsa-p" + strconv.Itoa(pub.Curve.Params().BitSize)
+	case *rsa.PublicKey:
+		return "rsa-" + strconv.Itoa(pub.N.BitLen())
+	case ed25519.PublicKey:
+		return "ed25519"
+	default:
+		return "unknown"
+	}
+}
+
+// CounterVec is a counter partitioned by labels
+type CounterVec struct {
+	name, help string
+	labels     []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{name: name, help: help, labels: labels, values: make(map[string]float64)}
+}
+
+// Inc increments the counter with the label values, given in the order of the label names
+func (c *CounterVec) Inc(values ...string) {
+	key := labelPairs(c.labels, values)
+	c.mu.Lock()
+	c.values[key]++
+	c.mu.Unlock()
+}
+
+// Value returns the current value of the counter with the label values
+func (c *CounterVec) Value(values ...string) float64 {
+	key := labelPairs(c.labels, values)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[key]
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s{%s} %s\n", c.name, key, formatFloat(c.values[key]))
+	}
+}
+
+// HistogramVec is a histogram partitioned by labels
+type HistogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	series map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return &HistogramVec{name: name, help: help, labels: labels, buckets: buckets, series: make(map[string]*histogram)}
+}
+
+// Observe adds a value to the histogram with the label values
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	key := labelPairs(h.labels, values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, bound := range h.buckets {
+		if v <= bound {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", h.name, key, formatFloat(bound), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", h.name, key, s.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", h.name, key, formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", h.name, key, s.count)
+	}
+}
+
+// expiryInventory counts the certificates observed by a registry by the day they expire, so
+// memory stays bounded by the number of distinct expiry days. certgen stores no certificates,
+// so the inventory starts empty with every process and misses certificates issued before.
+type expiryInventory struct {
+	mu   sync.Mutex
+	days map[int64]int
+}
+
+func (e *expiryInventory) add(notAfter time.Time) {
+	e.mu.Lock()
+	e.days[unixDay(notAfter)]++
+	e.mu.Unlock()
+}
+
+// count returns the number of certificates expiring within the given days, dropping expired ones
+func (e *expiryInventory) count(now time.Time, within int64) int {
+	today := unixDay(now)
+	var n int
+	for day, count := range e.days {
+		switch {
+		case day < today:
+			delete(e.days, day)
+		case day <= today+within:
+			n += count
+		}
+	}
+	return n
+}
+
+func (e *expiryInventory) write(w io.Writer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	const name = "certgen_certificates_expiring"
+	fmt.Fprintf(w, "# HELP %s Certificates issued by this process since it started that expire within the window; certificates issued before the last restart are not counted\n# TYPE %s gauge\n", name, name)
+	now := time.Now()
+	for _, window := range expiryWindows {
+		fmt.Fprintf(w, "%s{within=%q} %d\n", name, window.label, e.count(now, window.days))
+	}
+}
+
+func unixDay(t time.Time) int64 {
+	return t.Unix() / 86400
+}
+
+// labelPairs renders label names and values as name="value" pairs
+func labelPairs(names, values []string) string {
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		var value string
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs[i] = name + `="` + escapeLabel(value) + `"`
+	}
+	return strings.Join(pairs, ",")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pvormste/certgen/certificate"
+	"github.com/pvormste/certgen/internal/ssh"
+)
+
+func TestCounterVec(t *testing.T) {
+	c := newCounterVec("test_total", "Test counter", "endpoint", "code")
+	c.Inc("/a", "404")
+	c.Inc("/a", "404")
+	c.Inc(`/b"\`, "500")
+
+	var buf bytes.Buffer
+	c.write(&buf)
+	want := "# HELP test_total Test counter\n# TYPE test_total counter\n" +
+		"test_total{endpoint=\"/a\",code=\"404\"} 2\n" +
+		"test_total{endpoint=\"/b\\\"\\\\\",code=\"500\"} 1\n"
+	if buf.String() != want {
+		t.Errorf("write() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestHistogramVec(t *testing.T) {
+	h := newHistogramVec("test_seconds", "Test histogram", []float64{0.1, 1}, "type")
+	h.Observe(0.05, "ca")
+	h.Observe(0.5, "ca")
+	h.Observe(5, "ca")
+
+	var buf bytes.Buffer
+	h.write(&buf)
+	for _, line := range []string{
+		`test_seconds_bucket{type="ca",le="0.1"} 1`,
+		`test_seconds_bucket{type="ca",le="1"} 2`,
+		`test_seconds_bucket{type="ca",le="+Inf"} 3`,
+		`test_seconds_sum{type="ca"} 5.55`,
+		`test_seconds_count{type="ca"} 3`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("Histogram output lacks %q:\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestExpiryInventory(t *testing.T) {
+	now := time.Now()
+	e := &expiryInventory{days: make(map[int64]int)}
+	e.add(now.Add(-48 * time.Hour))
+	e.add(now.Add(3 * 24 * time.Hour))
+	e.add(now.Add(20 * 24 * time.Hour))
+	e.add(now.Add(365 * 24 * time.Hour))
+
+	if n := e.count(now, 7); n != 1 {
+		t.Errorf("count(7d) = %d, want 1", n)
+	}
+	if n := e.count(now, 30); n != 2 {
+		t.Errorf("count(30d) = %d, want 2", n)
+	}
+	if len(e.days) != 3 {
+		t.Errorf("Expired certificates were not dropped: %v", e.days)
+	}
+}
+
+func TestObserve(t *testing.T) {
+	ca, err := certificate.NewCA(certificate.WithCommonName("Metrics CA"))
+	if err != nil {
+		t.Fatalf("NewCA() error = %v", err)
+	}
+	sshCA, err := ssh.GenerateCA(ssh.CAConfig{})
+	if err != nil {
+		t.Fatalf("ssh.GenerateCA() error = %v", err)
+	}
+
+	r := NewRegistry()
+	r.ObserveCertificate("http", "ca", ca.CertPEM, time.Now())
+	if got := r.CertificatesIssued.Value("ca", "ecdsa-p384", "http"); got != 1 {
+		t.Errorf("CA count = %v, want 1", got)
+	}
+	r.ObserveSSH("mcp", "ssh-ca", sshCA.PublicKey, time.Now())
+	if got := r.CertificatesIssued.Value("ssh-ca", "ed25519", "mcp"); got != 1 {
+		t.Errorf("SSH CA count = %v, want 1", got)
+	}
+	if got := NewRegistry().CertificatesIssued.Value("ca", "ecdsa-p384", "http"); got != 0 {
+		t.Errorf("New registry counts %v CAs, want 0", got)
+	}
+
+	// A nil registry records nothing and serves no metrics
+	var disabled *Registry
+	disabled.ObserveCertificate("http", "ca", ca.CertPEM, time.Now())
+	disabled.ObserveRequest("/metrics", 500)
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, name := range []string{
+		"# TYPE certgen_certificates_issued_total counter",
+		"# TYPE certgen_generation_duration_seconds histogram",
+		`certgen_generation_duration_seconds_count{type="ca"}`,
+		`certgen_certificates_expiring{within="30d"}`,
+	} {
+		if !strings.Contains(rec.Body.String(), name) {
+			t.Errorf("Metrics lack %q", name)
+		}
+	}
+}
@@ -18,7 +18,7 @@ type OrgData struct {
 
 // Predefined organization combinations
 var organizations = []OrgData{
-	{Suffix: "Ltd.", Country: "UK", Locality: "London"},
+	{Suffix: "Ltd.", Country: "GB", Locality: "London"},
 	{Suffix: "GmbH", Country: "DE", Locality: "Berlin"},
 	{Suffix: "Inc.", Country: "US", Locality: "New York"},
 	{Suffix: "S.A.", Country: "FR", Locality: "Paris"},
@@ -0,0 +1,258 @@
+// Package ratelimit protects shared instances from clients generating more keys than the
+// machine can handle: token buckets limit the rate per client address and per user, and a
+// semaphore bounds the number of generations running at once.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// ipv6PrefixBits is the prefix length of the IPv6 networks sharing a client limit
+	ipv6PrefixBits = 64
+	// maxBuckets bounds the keys with a bucket of their own per limiter
+	maxBuckets = 100000
+	// overflowKey is the key of the bucket shared by keys beyond maxBuckets; it is no valid
+	// client address or user name
+	overflowKey = "\x00overflow"
+)
+
+// ErrLimited is wrapped by every *LimitError
+var ErrLimited = errors.New("too many requests")
+
+// LimitError is returned when a request exceeds a limit
+type LimitError struct {
+	Reason string
+	// RetryAfter is when the request would be accepted again
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s, retry after %ds", e.Reason, e.RetryAfterSeconds())
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimited
+}
+
+// RetryAfterSeconds rounds RetryAfter up to whole seconds for the Retry-After header
+func (e *LimitError) RetryAfterSeconds() int {
+	return max(1, int(math.Ceil(e.RetryAfter.Seconds())))
+}
+
+// Config configures Limits. Zero values disable the respective limit.
+type Config struct {
+	// ClientRate and ClientBurst limit the generation requests per second of each client address;
+	// IPv6 clients are limited per /64 network
+	ClientRate  float64
+	ClientBurst int
+	// UserRate and UserBurst limit the generation requests per second of each authenticated user
+	UserRate  float64
+	UserBurst int
+	// MaxConcurrent bounds the generations running at once; QueueTimeout is how long a request
+	// waits for a free slot before it is rejected
+	MaxConcurrent int
+	QueueTimeout  time.Duration
+}
+
+// Limits enforces a Config. A nil Limits allows everything.
+type Limits struct {
+	clients *limiter
+	users   *limiter
+
+	slots        chan struct{}
+	queueTimeout time.Duration
+}
+
+// New creates Limits enforcing the config, or returns nil when it disables every limit
+func New(config Config) *Limits {
+	l := &Limits{
+		clients:      newLimiter(config.ClientRate, config.ClientBurst),
+		users:        newLimiter(config.UserRate, config.UserBurst),
+		queueTimeout: config.QueueTimeout,
+	}
+	if config.MaxConcurrent > 0 {
+		l.slots = make(chan struct{}, config.MaxConcurrent)
+	}
+	if l.clients == nil && l.users == nil && l.slots == nil {
+		return nil
+	}
+	return l
+}
+
+// Acquire admits a generation request from the client address and user (empty when
+// anonymous). It checks their rate limits and waits for a free generation slot. On success
+// the caller must call release once the generation is done; otherwise the error is a
+// *LimitError or the error of ctx.
+func (l *Limits) Acquire(ctx context.Context, client, user string) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	if wait := l.take(clientKey(client), user, time.Now()); wait > 0 {
+		return nil, &LimitError{Reason: "rate limit exceeded", RetryAfter: wait}
+	}
+
+	if l.slots == nil {
+		return func() {}, nil
+	}
+	var timeout <-chan time.Time
+	if l.queueTimeout > 0 {
+		timer := time.NewTimer(l.queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return func() { <-l.slots }, nil
+	case <-timeout:
+		return nil, &LimitError{Reason: "too many certificates are being generated", RetryAfter: time.Second}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// take removes a token from the buckets of the client and, unless anonymous, the user. It
+// returns zero on success and otherwise how long it takes until both have a token. Both
+// limiters are locked at once, so a request rejected by one does not use up a token of the
+// other.
+func (l *Limits) take(client, user string, now time.Time) time.Duration {
+	users := l.users
+	if user == "" {
+		users = nil
+	}
+	l.clients.lock()
+	defer l.clients.unlock()
+	users.lock()
+	defer users.unlock()
+
+	clientBucket, userBucket := l.clients.bucket(client, now), users.bucket(user, now)
+	if wait := max(l.clients.wait(clientBucket), users.wait(userBucket)); wait > 0 {
+		return wait
+	}
+	clientBucket.take()
+	userBucket.take()
+	return 0
+}
+
+// Host strips the port from a client address such as http.Request.RemoteAddr, so all
+// connections of a client share its limit
+func Host(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// clientKey is the rate limit key of a client address: its IPv4 address, or the /64 prefix
+// of its IPv6 address, as a single IPv6 client is commonly assigned a whole /64
+func clientKey(addr string) string {
+	host := Host(addr)
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return host
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.String()
+	}
+	prefix := net.IPNet{IP: ip.Mask(net.CIDRMask(ipv6PrefixBits, 128)), Mask: net.CIDRMask(ipv6PrefixBits, 128)}
+	return prefix.String()
+}
+
+// limiter keeps a token bucket per key. Buckets that have refilled completely are dropped,
+// since a new bucket behaves the same. At most maxBuckets keys get their own bucket; further
+// keys share one overflow bucket until buckets are dropped, so bursts of new addresses
+// neither exhaust memory nor escape the limit.
+type limiter struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastPrune time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (b *bucket) take() {
+	if b != nil {
+		b.tokens--
+	}
+}
+
+func newLimiter(rate float64, burst int) *limiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &limiter{rate: rate, burst: float64(max(burst, 1)), buckets: make(map[string]*bucket)}
+}
+
+func (l *limiter) lock() {
+	if l != nil {
+		l.mu.Lock()
+	}
+}
+
+func (l *limiter) unlock() {
+	if l != nil {
+		l.mu.Unlock()
+	}
+}
+
+// bucket returns the key's bucket, refilled up to now. The caller must hold l.mu.
+func (l *limiter) bucket(key string, now time.Time) *bucket {
+	if l == nil {
+		return nil
+	}
+
+	// A full map is pruned more often, but still rarely enough to keep the cost of a request low
+	if since := now.Sub(l.lastPrune); since > time.Minute || (len(l.buckets) >= maxBuckets && since > time.Second) {
+		l.prune(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxBuckets {
+			key = overflowKey
+			b, ok = l.buckets[key]
+		}
+		if !ok {
+			b = &bucket{tokens: l.burst, last: now}
+			l.buckets[key] = b
+		}
+	}
+	b.tokens = l.refill(b, now)
+	b.last = now
+	return b
+}
+
+// prune drops the buckets that have refilled completely
+func (l *limiter) prune(now time.Time) {
+	for k, b := range l.buckets {
+		if l.refill(b, now) >= l.burst {
+			delete(l.buckets, k)
+		}
+	}
+	l.lastPrune = now
+}
+
+// wait returns zero when b holds a token and otherwise how long it takes until it does
+func (l *limiter) wait(b *bucket) time.Duration {
+	if l == nil || b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+func (l *limiter) refill(b *bucket, now time.Time) float64 {
+	return min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+}
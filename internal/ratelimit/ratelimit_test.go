@@ -0,0 +1,168 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// take removes a token from the key's bucket like Limits.take does for a single limiter
+func (l *limiter) take(key string, now time.Time) time.Duration {
+	l.lock()
+	defer l.unlock()
+	b := l.bucket(key, now)
+	if wait := l.wait(b); wait > 0 {
+		return wait
+	}
+	b.take()
+	return 0
+}
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(2, 3)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if wait := l.take("a", now); wait != 0 {
+			t.Fatalf("take() #%d wait = %v, want the burst to be allowed", i+1, wait)
+		}
+	}
+	if wait := l.take("a", now); wait != 500*time.Millisecond {
+		t.Errorf("take() after the burst wait = %v, want 500ms", wait)
+	}
+	if wait := l.take("b", now); wait != 0 {
+		t.Errorf("take() of another key wait = %v, want its own bucket", wait)
+	}
+	if wait := l.take("a", now.Add(500*time.Millisecond)); wait != 0 {
+		t.Errorf("take() after refilling wait = %v, want 0", wait)
+	}
+
+	// Full buckets are dropped
+	l.take("a", now.Add(time.Hour))
+	if len(l.buckets) != 1 {
+		t.Errorf("buckets = %d, want only the one just used", len(l.buckets))
+	}
+}
+
+func TestLimitsRate(t *testing.T) {
+	l := New(Config{ClientRate: 1, ClientBurst: 1, UserRate: 1, UserBurst: 2})
+	ctx := context.Background()
+
+	release, err := l.Acquire(ctx, "192.0.2.1:1000", "")
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	release()
+
+	// Another connection of the same client shares its limit
+	_, err = l.Acquire(ctx, "192.0.2.1:2000", "")
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || !errors.Is(err, ErrLimited) || limitErr.RetryAfterSeconds() != 1 {
+		t.Fatalf("Acquire() error = %v, want a LimitError", err)
+	}
+
+	// Users are limited across client addresses
+	for i, client := range []string{"192.0.2.2:1", "192.0.2.3:1", "192.0.2.4:1"} {
+		_, err := l.Acquire(ctx, client, "ci")
+		if (err != nil) != (i == 2) {
+			t.Errorf("Acquire() #%d for user error = %v", i+1, err)
+		}
+	}
+}
+
+func TestLimitsRateChecksBothBuckets(t *testing.T) {
+	l := New(Config{ClientRate: 1, ClientBurst: 1, UserRate: 1, UserBurst: 1})
+	ctx := context.Background()
+
+	if _, err := l.Acquire(ctx, "192.0.2.1:1", "ci"); err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	// Rejected by the user limit, so the second client keeps its token
+	if _, err := l.Acquire(ctx, "192.0.2.2:1", "ci"); !errors.Is(err, ErrLimited) {
+		t.Fatalf("Acquire() error = %v, want the user limit", err)
+	}
+	if _, err := l.Acquire(ctx, "192.0.2.2:1", ""); err != nil {
+		t.Errorf("Acquire() error = %v, want the client's token to be left", err)
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"192.0.2.1:443", "192.0.2.1"},
+		{"[2001:db8:1:2:3:4:5:6]:443", "2001:db8:1:2::/64"},
+		{"[2001:db8:1:2::ffff]:443", "2001:db8:1:2::/64"},
+		{"[::ffff:192.0.2.1]:443", "192.0.2.1"},
+		{"pipe", "pipe"},
+	}
+	for _, tt := range tests {
+		if got := clientKey(tt.addr); got != tt.want {
+			t.Errorf("clientKey(%q) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestLimiterOverflow(t *testing.T) {
+	l := newLimiter(1, 1)
+	now := time.Now()
+	for i := 0; i < maxBuckets; i++ {
+		l.take(strconv.Itoa(i), now)
+	}
+
+	// Keys beyond the cap share one bucket instead of growing the map
+	if wait := l.take("new-a", now); wait != 0 {
+		t.Errorf("take() of the first overflowing key wait = %v, want 0", wait)
+	}
+	if wait := l.take("new-b", now); wait == 0 {
+		t.Error("take() of the second overflowing key got a token, want the shared bucket to be empty")
+	}
+	if len(l.buckets) != maxBuckets+1 {
+		t.Errorf("buckets = %d, want %d", len(l.buckets), maxBuckets+1)
+	}
+
+	// Refilled buckets make room again
+	if wait := l.take("new-c", now.Add(2*time.Second)); wait != 0 {
+		t.Errorf("take() after pruning wait = %v, want 0", wait)
+	}
+	if len(l.buckets) != 1 {
+		t.Errorf("buckets = %d after pruning, want 1", len(l.buckets))
+	}
+}
+
+func TestLimitsConcurrency(t *testing.T) {
+	l := New(Config{MaxConcurrent: 1, QueueTimeout: 10 * time.Millisecond})
+	ctx := context.Background()
+
+	release, err := l.Acquire(ctx, "client", "")
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if _, err := l.Acquire(ctx, "client", ""); !errors.Is(err, ErrLimited) {
+		t.Errorf("Acquire() with all slots taken error = %v, want ErrLimited", err)
+	}
+
+	release()
+	release, err = l.Acquire(ctx, "client", "")
+	if err != nil {
+		t.Fatalf("Acquire() after release error = %v", err)
+	}
+	release()
+}
+
+func TestDisabledLimits(t *testing.T) {
+	l := New(Config{})
+	if l != nil {
+		t.Fatalf("New() = %v, want nil without limits", l)
+	}
+	for i := 0; i < 100; i++ {
+		release, err := l.Acquire(context.Background(), "client", "user")
+		if err != nil {
+			t.Fatalf("Acquire() error = %v", err)
+		}
+		release()
+	}
+}
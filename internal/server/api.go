@@ -0,0 +1,238 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pvormste/certgen/certificate"
+	"github.com/pvormste/certgen/internal/audit"
+	"github.com/pvormste/certgen/internal/auth"
+	mcpPkg "github.com/pvormste/certgen/internal/mcp"
+	"github.com/pvormste/certgen/internal/ssh"
+)
+
+const (
+	mediaTypeJSON = "application/json"
+	mediaTypeZIP  = "application/zip"
+)
+
+// APICertRequest is the JSON body of POST /api/v1/cert: the certificate form data plus
+// the PEM encoded CA certificate and private key that sign it
+type APICertRequest struct {
+	FormData
+	CACert string `json:"caCert"`
+	CAKey  string `json:"caKey"`
+}
+
+// APIInspectRequest is the JSON body of POST /api/v1/inspect
+type APIInspectRequest struct {
+	Certificate string `json:"certificate"`
+}
+
+// APIError is the JSON error body returned by the REST API
+type APIError struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+	// Fields lists every invalid field of a rejected configuration
+	Fields []APIFieldError `json:"fields,omitempty"`
+}
+
+// APIFieldError describes a single invalid field
+type APIFieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// handleAPICA generates a CA certificate, returning JSON or, with Accept: application/zip, the ZIP download
+func (s *Server) handleAPICA(w http.ResponseWriter, r *http.Request) {
+	mediaType, ok := apiPreflight(w, r)
+	if !ok {
+		return
+	}
+
+	var formData FormData
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&formData); err != nil {
+		writeAPIError(w, http.StatusBadRequest, errors.New("invalid request body: "+err.Error()))
+		return
+	}
+
+	start := time.Now()
+	bundle, err := certificate.GenerateCA(formData.caConfig(s.defaults))
+	if err != nil {
+		writeAPIError(w, apiErrorStatus(err), err)
+		return
+	}
+	s.metrics.ObserveCertificate(audit.SourceHTTP, "ca", bundle.CertPEM, start)
+	event := audit.CertificateEvent(audit.ActionCreateCA, bundle.CertPEM, nil)
+	if !s.record(w, r, event) {
+		return
+	}
+
+	if mediaType == mediaTypeZIP {
+		writeCAZip(w, bundle)
+		s.recordDownload(w, r, event)
+		return
+	}
+
+	response, err := mcpPkg.NewCAResponse(bundle)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeAPIJSON(w, http.StatusCreated, response)
+}
+
+// handleAPICert generates a client/server/peer certificate signed by the CA in the request body,
+// returning JSON or, with Accept: application/zip, the ZIP download
+func (s *Server) handleAPICert(w http.ResponseWriter, r *http.Request) {
+	mediaType, ok := apiPreflight(w, r)
+	if !ok {
+		return
+	}
+
+	var req APICertRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, errors.New("invalid request body: "+err.Error()))
+		return
+	}
+	if req.CACert == "" || req.CAKey == "" {
+		writeAPIError(w, http.StatusBadRequest, errors.New("caCert and caKey are required"))
+		return
+	}
+	caCertPEM, caKeyPEM := []byte(req.CACert), []byte(req.CAKey)
+	if err := audit.AuthorizeCA(r.Context(), s.auth, s.audit, caCertPEM); err != nil {
+		s.writeAuthError(w, r, err)
+		return
+	}
+
+	config, err := req.certConfig(s.serials, s.defaults)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	start := time.Now()
+	bundle, err := certificate.GenerateCertWithPolicy(config, s.policies.Lookup(caCertPEM), caCertPEM, caKeyPEM)
+	if err != nil {
+		writeAPIError(w, apiErrorStatus(err), err)
+		return
+	}
+	s.metrics.ObserveCertificate(audit.SourceHTTP, string(config.Type), bundle.CertPEM, start)
+	event := audit.CertificateEvent(audit.ActionIssue, bundle.CertPEM, caCertPEM)
+	event.Profile = string(config.Type)
+	if !s.record(w, r, event) {
+		return
+	}
+
+	if mediaType == mediaTypeZIP {
+		writeCertZip(w, string(config.Type), bundle, caCertPEM)
+		s.recordDownload(w, r, event)
+		return
+	}
+
+	response, err := mcpPkg.NewCertResponse(bundle, caCertPEM)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeAPIJSON(w, http.StatusCreated, response)
+}
+
+// handleAPIInspect summarizes the PEM encoded certificate in the request body
+func (s *Server) handleAPIInspect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeAPIError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	var req APIInspectRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, errors.New("invalid request body: "+err.Error()))
+		return
+	}
+
+	info, err := certificate.Inspect([]byte(req.Certificate))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, info)
+}
+
+// apiPreflight checks the method and negotiates the response media type of a generation
+// request. On failure it writes the error response and returns false.
+func apiPreflight(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeAPIError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return "", false
+	}
+
+	mediaType, ok := negotiate(r.Header.Get("Accept"))
+	if !ok {
+		writeAPIError(w, http.StatusNotAcceptable, errors.New("supported media types are "+mediaTypeJSON+" and "+mediaTypeZIP))
+		return "", false
+	}
+	return mediaType, true
+}
+
+// negotiate picks the response media type from an Accept header. The first supported
+// media range wins; quality values are not weighed. JSON is the default.
+func negotiate(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return mediaTypeJSON, true
+	}
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, _, _ := strings.Cut(part, ";")
+		switch strings.ToLower(strings.TrimSpace(mediaRange)) {
+		case mediaTypeJSON, "application/*", "*/*":
+			return mediaTypeJSON, true
+		case mediaTypeZIP:
+			return mediaTypeZIP, true
+		}
+	}
+	return "", false
+}
+
+// apiErrorStatus maps a generation error to its HTTP status code
+func apiErrorStatus(err error) int {
+	var validationErr *certificate.ValidationError
+	switch {
+	case errors.Is(err, certificate.ErrPolicyViolation), errors.Is(err, auth.ErrForbidden):
+		return http.StatusForbidden
+	case errors.As(err, &validationErr), errors.Is(err, certificate.ErrInvalidCA), errors.Is(err, ssh.ErrInvalidCAKey):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeAPIError writes err as an APIError, listing the fields of a validation error
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	apiErr := APIError{Status: status, Message: err.Error()}
+
+	var validationErr *certificate.ValidationError
+	if errors.As(err, &validationErr) {
+		for _, field := range validationErr.Fields {
+			apiErr.Fields = append(apiErr.Fields, APIFieldError{Field: field.Field, Message: field.Err.Error()})
+		}
+	}
+
+	writeAPIJSON(w, status, apiErr)
+}
+
+// writeAPIJSON writes v as a JSON response with the status code
+func writeAPIJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", mediaTypeJSON)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		// The status line is already sent, so the error can only be logged
+		log.Printf("Failed to write API response: %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pvormste/certgen/certificate"
+	mcpPkg "github.com/pvormste/certgen/internal/mcp"
+)
+
+func TestAPIGenerate(t *testing.T) {
+	s, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+
+	rec := apiRequest(t, s.handleAPICA, "", map[string]any{"commonName": "API CA", "expiryDays": 1})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /api/v1/ca status = %d, body %s", rec.Code, rec.Body)
+	}
+	var ca mcpPkg.CAResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &ca); err != nil {
+		t.Fatalf("Failed to decode CA response: %v", err)
+	}
+	if !strings.Contains(ca.Certificate, "BEGIN CERTIFICATE") || ca.PrivateJWK == nil {
+		t.Errorf("Incomplete CA response: %s", rec.Body)
+	}
+
+	certRequest := map[string]any{
+		"commonName": "api.example",
+		"expiryDays": 1,
+		"certType":   "server",
+		"dnsNames":   []string{"api.example"},
+		"caCert":     ca.Certificate,
+		"caKey":      ca.PrivateKey,
+	}
+
+	t.Run("json", func(t *testing.T) {
+		rec := apiRequest(t, s.handleAPICert, "application/json", certRequest)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("POST /api/v1/cert status = %d, body %s", rec.Code, rec.Body)
+		}
+		var cert mcpPkg.CertResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &cert); err != nil {
+			t.Fatalf("Failed to decode certificate response: %v", err)
+		}
+		if !strings.HasSuffix(cert.ChainPEM, ca.Certificate) {
+			t.Error("Expected the chain to end with the CA certificate")
+		}
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		rec := apiRequest(t, s.handleAPICert, "application/zip", certRequest)
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/zip" {
+			t.Fatalf("POST /api/v1/cert status = %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+		}
+		zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+		if err != nil {
+			t.Fatalf("Failed to read ZIP: %v", err)
+		}
+		if len(zr.File) == 0 || zr.File[0].Name != "server.crt" {
+			t.Errorf("Unexpected ZIP contents")
+		}
+	})
+
+	t.Run("not acceptable", func(t *testing.T) {
+		rec := apiRequest(t, s.handleAPICert, "text/html", certRequest)
+		if rec.Code != http.StatusNotAcceptable {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusNotAcceptable)
+		}
+	})
+}
+
+func TestAPIErrors(t *testing.T) {
+	s, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		body       map[string]any
+		wantStatus int
+		wantFields []string
+	}{
+		{
+			name:       "invalid CA config",
+			handler:    s.handleAPICA,
+			body:       map[string]any{"commonName": "API CA", "country": "Germany"},
+			wantStatus: http.StatusBadRequest,
+			wantFields: []string{"country", "expiryDays"},
+		},
+		{
+			name:       "missing CA",
+			handler:    s.handleAPICert,
+			body:       map[string]any{"commonName": "leaf", "expiryDays": 1},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unparsable CA",
+			handler:    s.handleAPICert,
+			body:       map[string]any{"commonName": "leaf", "expiryDays": 1, "caCert": "junk", "caKey": "junk"},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "sequential serials without a serial counter",
+			handler:    s.handleAPICert,
+			body:       map[string]any{"commonName": "leaf", "expiryDays": 1, "serialStrategy": "sequential", "caCert": "junk", "caKey": "junk"},
+			wantStatus: http.StatusBadRequest,
+			wantFields: []string{"serialStrategy"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := apiRequest(t, tt.handler, "", tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+
+			var apiErr APIError
+			if err := json.Unmarshal(rec.Body.Bytes(), &apiErr); err != nil {
+				t.Fatalf("Failed to decode error response: %v", err)
+			}
+			if apiErr.Status != tt.wantStatus || apiErr.Message == "" {
+				t.Errorf("Unexpected error response: %+v", apiErr)
+			}
+			var fields []string
+			for _, f := range apiErr.Fields {
+				fields = append(fields, f.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.wantFields, ",") {
+				t.Errorf("fields = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestAPIDefaults(t *testing.T) {
+	s, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	s.SetDefaults(certificate.Defaults{Organization: "Default Org", Country: "DE", Validity: "2d"})
+
+	rec := apiRequest(t, s.handleAPICA, "", map[string]any{"commonName": "Defaults CA", "organization": "Own Org"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /api/v1/ca status = %d, body %s", rec.Code, rec.Body)
+	}
+	var ca mcpPkg.CAResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &ca); err != nil {
+		t.Fatalf("Failed to decode CA response: %v", err)
+	}
+	block, _ := pem.Decode([]byte(ca.Certificate))
+	if block == nil {
+		t.Fatal("Expected a PEM encoded CA certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse CA certificate: %v", err)
+	}
+	if got := cert.Subject.Organization; len(got) != 1 || got[0] != "Own Org" {
+		t.Errorf("Organization = %v, want the requested one", got)
+	}
+	if got := cert.Subject.Country; len(got) != 1 || got[0] != "DE" {
+		t.Errorf("Country = %v, want the default", got)
+	}
+	if validity := cert.NotAfter.Sub(cert.NotBefore); validity < 47*time.Hour || validity > 49*time.Hour {
+		t.Errorf("Validity = %v, want the default of 2 days", validity)
+	}
+}
+
+func apiRequest(t *testing.T, handler http.HandlerFunc, accept string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("Failed to encode request: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/v1", bytes.NewReader(data))
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
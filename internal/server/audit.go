@@ -0,0 +1,209 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pvormste/certgen/internal/audit"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+
+	// authFailureWindow is how long further authentication failures of a client address are
+	// only counted after one was recorded
+	authFailureWindow = time.Minute
+	// maxAuthFailureClients bounds the client addresses whose failures are counted
+	maxAuthFailureClients = 10000
+)
+
+// AuditResponse is the JSON body of GET /api/v1/audit
+type AuditResponse struct {
+	Events []audit.Event `json:"events"`
+}
+
+// SetAuditLog records CA creation, issuance, downloads and rejected requests of the web UI,
+// the API and MCP in l. By default nothing is recorded.
+func (s *Server) SetAuditLog(l *audit.Log) {
+	s.audit = l
+}
+
+// auditContext stores the client address and the request path in the request context, where
+// audit.Log.Record finds them
+func auditContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := audit.WithRemoteAddr(r.Context(), r.RemoteAddr)
+		ctx = audit.WithOperation(ctx, audit.SourceHTTP, r.URL.Path)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// record writes an audit event for a result not yet sent to the client. When the event cannot
+// be written, the request fails with 500 Internal Server Error, so nothing is handed out
+// unaudited, and record returns false.
+func (s *Server) record(w http.ResponseWriter, r *http.Request, event audit.Event) bool {
+	if err := s.audit.Record(r.Context(), event); err != nil {
+		log.Printf("Failed to write audit event: %v", err)
+		writeError(w, r, http.StatusInternalServerError, errors.New("failed to write the audit event, so the result is withheld"))
+		return false
+	}
+	return true
+}
+
+// recordSent writes an audit event for a response that was already decided or sent, only
+// logging failures
+func (s *Server) recordSent(r *http.Request, event audit.Event) {
+	if err := s.audit.Record(r.Context(), event); err != nil {
+		log.Printf("Failed to write audit event: %v", err)
+	}
+}
+
+// recordAuthFailure records a failed authentication, at most once per client address and
+// authFailureWindow. Each request would otherwise append and sync an event, so clients without
+// credentials (including browsers before they prompt for them) could flood the log and the disk.
+// The next recorded event of an address carries the number of failures left out in between.
+func (s *Server) recordAuthFailure(r *http.Request, err error) {
+	if s.audit == nil {
+		return
+	}
+	host, _, splitErr := net.SplitHostPort(r.RemoteAddr)
+	if splitErr != nil {
+		host = r.RemoteAddr
+	}
+	record, repeated := s.authFailures.add(host, time.Now())
+	if !record {
+		return
+	}
+	event := audit.AuthEvent(err)
+	event.Repeated = repeated
+	s.recordSent(r, event)
+}
+
+// authFailures counts the authentication failures per client address within authFailureWindow
+type authFailures struct {
+	mu      sync.Mutex
+	clients map[string]*authFailureCount
+}
+
+type authFailureCount struct {
+	since   time.Time
+	skipped int
+}
+
+// add counts a failure of the client and reports whether to record it, along with the number
+// of failures skipped since the client's last recorded one
+func (f *authFailures) add(client string, now time.Time) (bool, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	count := f.clients[client]
+	if count != nil && now.Sub(count.since) < authFailureWindow {
+		count.skipped++
+		return false, 0
+	}
+
+	if f.clients == nil {
+		f.clients = make(map[string]*authFailureCount)
+	}
+	if count == nil && len(f.clients) >= maxAuthFailureClients {
+		// Expired entries only hold counts that were never followed by a recorded failure
+		for addr, c := range f.clients {
+			if now.Sub(c.since) >= authFailureWindow {
+				delete(f.clients, addr)
+			}
+		}
+		if len(f.clients) >= maxAuthFailureClients {
+			return false, 0
+		}
+	}
+
+	var skipped int
+	if count != nil {
+		skipped = count.skipped
+	}
+	f.clients[client] = &authFailureCount{since: now}
+	return true, skipped
+}
+
+// recordDownload writes a download event for the archive just written to w, if any. The
+// archive's file name is taken from the Content-Disposition header, which is only set once
+// the archive is complete.
+func (s *Server) recordDownload(w http.ResponseWriter, r *http.Request, event audit.Event) {
+	_, params, err := mime.ParseMediaType(w.Header().Get("Content-Disposition"))
+	if err != nil {
+		return
+	}
+	event.Action = audit.ActionDownload
+	event.File = params["filename"]
+	s.recordSent(r, event)
+}
+
+// handleAPIAudit returns the audit events matching the query parameters action, user, ca,
+// since, until (RFC 3339 timestamps) and limit, oldest first
+func (s *Server) handleAPIAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeAPIError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	if s.audit == nil {
+		writeAPIError(w, http.StatusNotFound, errors.New("audit log is not enabled"))
+		return
+	}
+
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	events, err := s.audit.Query(filter)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if events == nil {
+		events = []audit.Event{}
+	}
+	writeAPIJSON(w, http.StatusOK, AuditResponse{Events: events})
+}
+
+// parseAuditFilter reads the audit query parameters of the request
+func parseAuditFilter(r *http.Request) (audit.Filter, error) {
+	query := r.URL.Query()
+	filter := audit.Filter{
+		Action: audit.Action(query.Get("action")),
+		User:   query.Get("user"),
+		CA:     query.Get("ca"),
+		Limit:  defaultAuditLimit,
+	}
+
+	for name, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, fmt.Errorf("%s: must be an RFC 3339 timestamp", name)
+		}
+		*t = parsed
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxAuditLimit {
+			return filter, fmt.Errorf("limit: must be between 1 and %d", maxAuditLimit)
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
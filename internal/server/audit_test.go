@@ -0,0 +1,197 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/pvormste/certgen/certificate"
+	"github.com/pvormste/certgen/internal/audit"
+	"github.com/pvormste/certgen/internal/auth"
+	mcpPkg "github.com/pvormste/certgen/internal/mcp"
+)
+
+func TestAuditLog(t *testing.T) {
+	auditLog, err := audit.Open(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatalf("audit.Open() error = %v", err)
+	}
+	defer auditLog.Close()
+
+	authenticator, err := auth.New(auth.Config{
+		Tokens: []auth.Token{testToken("admin", "admin-token"), testToken("auditor", "auditor-token")},
+		Roles: map[string]auth.Role{
+			"admin":   {Permissions: []auth.Permission{auth.PermissionCreateCA, auth.PermissionIssue}, CAs: []string{"*"}},
+			"auditor": {Permissions: []auth.Permission{auth.PermissionAudit}},
+		},
+		Users: map[string][]string{"token:admin": {"admin"}, "token:auditor": {"auditor"}},
+	})
+	if err != nil {
+		t.Fatalf("auth.New() error = %v", err)
+	}
+
+	s, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	s.SetAuthenticator(authenticator)
+	s.SetAuditLog(auditLog)
+	handler := s.Handler()
+
+	do := func(method, path, token, accept string, body any) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(data))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	ca, err := certificate.NewCA(certificate.WithCommonName("Audit CA"))
+	if err != nil {
+		t.Fatalf("NewCA() error = %v", err)
+	}
+	certReq := map[string]any{
+		"commonName": "svc.test",
+		"expiryDays": 1,
+		"certType":   "server",
+		"dnsNames":   []string{"svc.test"},
+		"caCert":     string(ca.CertPEM),
+		"caKey":      string(ca.KeyPEM),
+	}
+	if rec := do(http.MethodPost, "/api/v1/cert", "admin-token", mediaTypeZIP, certReq); rec.Code != http.StatusOK {
+		t.Fatalf("POST /api/v1/cert status = %d (body %s)", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPost, "/api/v1/ca", "", mediaTypeJSON, map[string]any{"commonName": "CA", "expiryDays": 1}); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("POST /api/v1/ca without token status = %d", rec.Code)
+	}
+	// Further failures of the same client within a minute are only counted
+	for _, path := range []string{"/", "/metrics", "/api/v1/ca"} {
+		if rec := do(http.MethodGet, path, "wrong-token", mediaTypeJSON, nil); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("GET %s with a wrong token status = %d", path, rec.Code)
+		}
+	}
+	if rec := do(http.MethodPost, "/api/v1/ca", "auditor-token", mediaTypeJSON, map[string]any{"commonName": "CA", "expiryDays": 1}); rec.Code != http.StatusForbidden {
+		t.Fatalf("POST /api/v1/ca as auditor status = %d", rec.Code)
+	}
+
+	mcpSrv := mcpPkg.NewServer(mcpPkg.Options{Serials: s.serials, Auth: authenticator, Audit: auditLog})
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Name: "token:admin", Method: auth.MethodToken, Roles: []string{"admin"}})
+	message := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"generate_ssh_ca","arguments":{"comment":"audit"}}}`
+	mcpSrv.HandleMessage(ctx, json.RawMessage(message))
+
+	if rec := do(http.MethodGet, "/api/v1/audit", "admin-token", mediaTypeJSON, nil); rec.Code != http.StatusForbidden {
+		t.Errorf("GET /api/v1/audit as admin status = %d, want 403", rec.Code)
+	}
+
+	rec := do(http.MethodGet, "/api/v1/audit", "auditor-token", mediaTypeJSON, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/v1/audit status = %d (body %s)", rec.Code, rec.Body)
+	}
+	var resp AuditResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Invalid audit response: %v", err)
+	}
+
+	want := []struct {
+		action audit.Action
+		source string
+		user   string
+	}{
+		{audit.ActionIssue, audit.SourceHTTP, "token:admin"},
+		{audit.ActionDownload, audit.SourceHTTP, "token:admin"},
+		{audit.ActionAuthFailure, audit.SourceHTTP, ""},
+		{audit.ActionAccessDenied, audit.SourceHTTP, "token:auditor"},
+		{audit.ActionCreateSSHCA, audit.SourceMCP, "token:admin"},
+		{audit.ActionAccessDenied, audit.SourceHTTP, "token:admin"},
+	}
+	if len(resp.Events) != len(want) {
+		t.Fatalf("Got %d audit events, want %d: %+v", len(resp.Events), len(want), resp.Events)
+	}
+	for i, w := range want {
+		event := resp.Events[i]
+		if event.Action != w.action || event.Source != w.source || event.User != w.user {
+			t.Errorf("Event %d = %s/%s by %q, want %s/%s by %q", i, event.Action, event.Source, event.User, w.action, w.source, w.user)
+		}
+	}
+	if issued := resp.Events[0]; issued.Subject != "CN=svc.test" || issued.Profile != "server" || issued.Operation != "/api/v1/cert" {
+		t.Errorf("Unexpected issuance event: %+v", issued)
+	}
+	if download := resp.Events[1]; download.File != "server-certificate.zip" || download.Fingerprint != resp.Events[0].Fingerprint {
+		t.Errorf("Unexpected download event: %+v", download)
+	}
+
+	fingerprint, _ := certificate.Fingerprint(ca.CertPEM)
+	rec = do(http.MethodGet, "/api/v1/audit?action=cert.issue&ca="+fingerprint+"&limit=5", "auditor-token", mediaTypeJSON, nil)
+	resp = AuditResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || len(resp.Events) != 1 {
+		t.Errorf("Filtered query returned %s", rec.Body)
+	}
+
+	if rec := do(http.MethodGet, "/api/v1/audit?since=yesterday", "auditor-token", mediaTypeJSON, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("GET /api/v1/audit with invalid since status = %d, want 400", rec.Code)
+	}
+}
+
+func TestAuthFailureThrottle(t *testing.T) {
+	var failures authFailures
+	start := time.Now()
+
+	if record, _ := failures.add("192.0.2.1", start); !record {
+		t.Error("The first failure was not recorded")
+	}
+	for i := 0; i < 3; i++ {
+		if record, _ := failures.add("192.0.2.1", start.Add(time.Second)); record {
+			t.Error("A repeated failure within the window was recorded")
+		}
+	}
+	if record, _ := failures.add("192.0.2.2", start.Add(time.Second)); !record {
+		t.Error("The failure of another client was not recorded")
+	}
+	if record, repeated := failures.add("192.0.2.1", start.Add(authFailureWindow)); !record || repeated != 3 {
+		t.Errorf("add() after the window = %v, %d, want true, 3", record, repeated)
+	}
+}
+
+func TestAuditFailureWithholdsResults(t *testing.T) {
+	auditLog, err := audit.Open(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatalf("audit.Open() error = %v", err)
+	}
+	// A closed log fails every write
+	auditLog.Close()
+
+	s, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	s.SetAuditLog(auditLog)
+
+	data, _ := json.Marshal(map[string]any{"commonName": "CA", "expiryDays": 1})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/ca", bytes.NewReader(data))
+	req.Header.Set("Accept", mediaTypeJSON)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError || bytes.Contains(rec.Body.Bytes(), []byte("PRIVATE KEY")) {
+		t.Errorf("POST /api/v1/ca with a failing audit log = %d %s, want 500 without the key", rec.Code, rec.Body)
+	}
+
+	mcpSrv := mcpPkg.NewServer(mcpPkg.Options{Audit: auditLog})
+	message := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"generate_ca","arguments":{"organization":"O","commonName":"MCP CA","country":"DE","locality":"Berlin"}}}`
+	resp, ok := mcpSrv.HandleMessage(context.Background(), json.RawMessage(message)).(mcp.JSONRPCResponse)
+	if !ok {
+		t.Fatal("Unexpected MCP response")
+	}
+	if result := resp.Result.(mcp.CallToolResult); !result.IsError {
+		t.Errorf("generate_ca with a failing audit log returned %+v, want a tool error", result.Content)
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/pvormste/certgen/internal/audit"
+	"github.com/pvormste/certgen/internal/auth"
+)
+
+// SetAuthenticator requires every request except static assets and health probes to
+// authenticate, and checks the user's permissions per endpoint and CA. By default all
+// requests are allowed.
+func (s *Server) SetAuthenticator(a *auth.Authenticator) {
+	s.auth = a
+}
+
+// authenticate identifies the user of every request and stores them in the request context
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	if s.auth == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Probes of orchestrators carry no credentials
+		if strings.HasPrefix(r.URL.Path, "/static/") || r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		principal, err := s.auth.Authenticate(r)
+		if err != nil {
+			s.recordAuthFailure(r, err)
+			s.writeAuthError(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	})
+}
+
+// require wraps a handler so it is only called for users with the permission
+func (s *Server) require(permission auth.Permission, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := audit.Authorize(r.Context(), s.auth, s.audit, permission); err != nil {
+			s.writeAuthError(w, r, err)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// writeAuthError answers 401 with a challenge for missing credentials and 403 for missing
+// permissions, as JSON for the /api/v1 endpoints
+func (s *Server) writeAuthError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusForbidden
+	if errors.Is(err, auth.ErrUnauthenticated) {
+		status = http.StatusUnauthorized
+		w.Header().Set("WWW-Authenticate", s.auth.Challenge())
+	}
+	writeError(w, r, status, err)
+}
+
+// writeError writes err as JSON for the /api/v1 endpoints and as plain text otherwise
+func writeError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		writeAPIError(w, status, err)
+		return
+	}
+	http.Error(w, err.Error(), status)
+}
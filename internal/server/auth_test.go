@@ -0,0 +1,126 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/pvormste/certgen/certificate"
+	"github.com/pvormste/certgen/internal/auth"
+	mcpPkg "github.com/pvormste/certgen/internal/mcp"
+)
+
+func TestAuthorization(t *testing.T) {
+	teamCA, err := certificate.NewCA(certificate.WithCommonName("Team CA"))
+	if err != nil {
+		t.Fatalf("NewCA() error = %v", err)
+	}
+	otherCA, err := certificate.NewCA(certificate.WithCommonName("Other CA"))
+	if err != nil {
+		t.Fatalf("NewCA() error = %v", err)
+	}
+	teamFingerprint, _ := certificate.Fingerprint(teamCA.CertPEM)
+
+	authenticator, err := auth.New(auth.Config{
+		Tokens: []auth.Token{testToken("admin", "admin-token"), testToken("dev", "dev-token"), testToken("viewer", "viewer-token")},
+		Roles: map[string]auth.Role{
+			"admin":  {Permissions: []auth.Permission{auth.PermissionCreateCA, auth.PermissionIssue, auth.PermissionInspect}, CAs: []string{"*"}},
+			"team":   {Permissions: []auth.Permission{auth.PermissionIssue}, CAs: []string{teamFingerprint}},
+			"viewer": {Permissions: []auth.Permission{auth.PermissionInspect}},
+		},
+		Users: map[string][]string{"token:admin": {"admin"}, "token:dev": {"team"}, "token:viewer": {"viewer"}},
+	})
+	if err != nil {
+		t.Fatalf("auth.New() error = %v", err)
+	}
+
+	s, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	s.SetAuthenticator(authenticator)
+	handler := s.Handler()
+
+	certRequest := func(ca *certificate.Issued) map[string]any {
+		return map[string]any{
+			"commonName": "svc.test",
+			"expiryDays": 1,
+			"certType":   "server",
+			"dnsNames":   []string{"svc.test"},
+			"caCert":     string(ca.CertPEM),
+			"caKey":      string(ca.KeyPEM),
+		}
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		body       any
+		wantStatus int
+	}{
+		{name: "no credentials", path: "/api/v1/ca", body: map[string]any{"commonName": "CA", "expiryDays": 1}, wantStatus: http.StatusUnauthorized},
+		{name: "invalid token", path: "/api/v1/ca", token: "guess", body: map[string]any{"commonName": "CA", "expiryDays": 1}, wantStatus: http.StatusUnauthorized},
+		{name: "admin creates CA", path: "/api/v1/ca", token: "admin-token", body: map[string]any{"commonName": "CA", "expiryDays": 1}, wantStatus: http.StatusCreated},
+		{name: "viewer creates CA", path: "/api/v1/ca", token: "viewer-token", body: map[string]any{"commonName": "CA", "expiryDays": 1}, wantStatus: http.StatusForbidden},
+		{name: "dev issues from team CA", path: "/api/v1/cert", token: "dev-token", body: certRequest(teamCA), wantStatus: http.StatusCreated},
+		{name: "dev issues from other CA", path: "/api/v1/cert", token: "dev-token", body: certRequest(otherCA), wantStatus: http.StatusForbidden},
+		{name: "viewer inspects", path: "/api/v1/inspect", token: "viewer-token", body: map[string]any{"certificate": string(teamCA.CertPEM)}, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := json.Marshal(tt.body)
+			req := httptest.NewRequest(http.MethodPost, tt.path, bytes.NewReader(data))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected a WWW-Authenticate challenge")
+			}
+			if rec.Code >= 400 {
+				var apiErr APIError
+				if err := json.Unmarshal(rec.Body.Bytes(), &apiErr); err != nil || apiErr.Status != tt.wantStatus {
+					t.Errorf("Expected a JSON error, got %s", rec.Body)
+				}
+			}
+		})
+	}
+
+	t.Run("mcp", func(t *testing.T) {
+		mcpSrv := mcpPkg.NewServer(mcpPkg.Options{Serials: certificate.NewMemorySerialCounter(), Auth: authenticator})
+		call := func(user string, roles ...string) mcp.CallToolResult {
+			ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Name: user, Roles: roles})
+			message := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"generate_ca","arguments":{"commonName":"MCP CA"}}}`
+			resp, ok := mcpSrv.HandleMessage(ctx, json.RawMessage(message)).(mcp.JSONRPCResponse)
+			if !ok {
+				t.Fatalf("Unexpected MCP response for %s", user)
+			}
+			return resp.Result.(mcp.CallToolResult)
+		}
+
+		if result := call("viewer", "viewer"); !result.IsError {
+			t.Error("Expected generate_ca to be denied for the viewer")
+		}
+		if result := call("admin", "admin"); result.IsError {
+			t.Errorf("generate_ca failed for the admin: %+v", result.Content)
+		}
+	})
+}
+
+func testToken(user, token string) auth.Token {
+	hash := sha256.Sum256([]byte(token))
+	return auth.Token{User: user, SHA256: hex.EncodeToString(hash[:])}
+}
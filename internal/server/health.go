@@ -0,0 +1,101 @@
+package server
+
+import (
+	"io"
+	"net/http"
+)
+
+// ReadyResponse is the JSON body of GET /readyz
+type ReadyResponse struct {
+	Status string `json:"status"`
+	// Checks maps every check to "ok" or its error
+	Checks map[string]string `json:"checks"`
+}
+
+// handleHealthz reports that the process is alive
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, "ok\n")
+}
+
+// handleReadyz reports whether the server can serve requests: the templates render and the
+// serial store and audit log are accessible
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	checks := map[string]func() error{
+		"templates": func() error { return s.templates.ExecuteTemplate(io.Discard, "index.html", s.defaults) },
+		"audit":     s.audit.Check,
+	}
+	// Stores kept in memory are always ready
+	if checker, ok := s.serials.(interface{ Check() error }); ok {
+		checks["serials"] = checker.Check
+	}
+
+	resp := ReadyResponse{Status: "ok", Checks: make(map[string]string, len(checks))}
+	status := http.StatusOK
+	for name, check := range checks {
+		resp.Checks[name] = "ok"
+		if err := check(); err != nil {
+			resp.Checks[name] = err.Error()
+			resp.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	writeAPIJSON(w, status, resp)
+}
+
+// instrument counts requests and error responses per route of mux, including requests
+// rejected before they reach the mux
+func (s *Server) instrument(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		if pattern == "" {
+			pattern = "unmatched"
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		s.metrics.ObserveRequest(pattern, rec.status)
+	})
+}
+
+// statusRecorder remembers the status code written to a response. It forwards Flush, which
+// the MCP transport relies on for streaming.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pvormste/certgen/certificate"
+	"github.com/pvormste/certgen/internal/auth"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	authenticator, err := auth.New(auth.Config{Tokens: []auth.Token{testToken("admin", "admin-token")}})
+	if err != nil {
+		t.Fatalf("auth.New() error = %v", err)
+	}
+
+	serialFile := filepath.Join(t.TempDir(), "serials.json")
+	s, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	s.SetAuthenticator(authenticator)
+	s.SetSerialCounter(certificate.NewFileSerialCounter(serialFile))
+	handler := s.Handler()
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get("/healthz", ""); rec.Code != http.StatusOK {
+		t.Errorf("GET /healthz status = %d, want 200", rec.Code)
+	}
+
+	rec := get("/readyz", "")
+	var ready ReadyResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &ready); err != nil || rec.Code != http.StatusOK || ready.Checks["serials"] != "ok" {
+		t.Errorf("GET /readyz = %d %s, want ready", rec.Code, rec.Body)
+	}
+
+	if err := os.WriteFile(serialFile, []byte("not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	rec = get("/readyz", "")
+	ready = ReadyResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &ready); err != nil || rec.Code != http.StatusServiceUnavailable || ready.Checks["serials"] == "ok" {
+		t.Errorf("GET /readyz with a corrupt serial store = %d %s, want 503", rec.Code, rec.Body)
+	}
+
+	if rec := get("/metrics", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET /metrics without token status = %d, want 401", rec.Code)
+	}
+	if got := s.metrics.HTTPErrors.Value("/metrics", "401"); got != 1 {
+		t.Errorf("401 responses of /metrics = %v, want 1", got)
+	}
+
+	rec = get("/metrics", "admin-token")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `certgen_http_errors_total{endpoint="/metrics",code="401"}`) {
+		t.Errorf("GET /metrics = %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,256 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	mcpServer "github.com/mark3labs/mcp-go/server"
+	mcpPkg "github.com/pvormste/certgen/internal/mcp"
+)
+
+// HTTPOptions configures the timeouts and size limits of the HTTP server. Zero values
+// disable the respective limit.
+type HTTPOptions struct {
+	// ReadHeaderTimeout and ReadTimeout bound reading the request headers and the whole request
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	// WriteTimeout bounds writing a response; it does not apply to the event stream of GET /mcp
+	WriteTimeout time.Duration
+	// IdleTimeout closes keep-alive connections waiting for the next request
+	IdleTimeout time.Duration
+	// ShutdownTimeout is how long Run waits for running requests once its context is done
+	// before cancelling them
+	ShutdownTimeout time.Duration
+	// MaxHeaderBytes and MaxBodyBytes limit the size of the request headers and body
+	MaxHeaderBytes int
+	MaxBodyBytes   int64
+}
+
+// DefaultHTTPOptions returns the options NewServer starts with
+func DefaultHTTPOptions() HTTPOptions {
+	return HTTPOptions{
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		ShutdownTimeout:   30 * time.Second,
+		MaxHeaderBytes:    1 << 20,
+		MaxBodyBytes:      10 << 20,
+	}
+}
+
+// SetHTTPOptions configures the timeouts and size limits of the HTTP server
+func (s *Server) SetHTTPOptions(opts HTTPOptions) {
+	s.httpOptions = opts
+}
+
+// Endpoints selects the groups of endpoints the server serves. Health probes and metrics
+// are always served.
+type Endpoints struct {
+	// UI is the web UI with its form handlers and static assets
+	UI bool
+	// API is the REST API under /api/v1 and its OpenAPI document
+	API bool
+	// MCP is the MCP server at /mcp
+	MCP bool
+}
+
+// serves reports whether the route with the pattern belongs to an enabled group
+func (e Endpoints) serves(pattern string) bool {
+	switch {
+	case pattern == "/healthz" || pattern == "/readyz" || pattern == "/metrics":
+		return true
+	case strings.HasPrefix(pattern, "/api/") || pattern == "/openapi.json":
+		return e.API
+	case pattern == "/mcp":
+		return e.MCP
+	default:
+		return e.UI
+	}
+}
+
+// SetEndpoints configures which groups of endpoints the server serves. By default it serves all of them.
+func (s *Server) SetEndpoints(endpoints Endpoints) {
+	s.endpoints = endpoints
+}
+
+// Handler returns a handler serving the web UI, the REST API and MCP from a mux of its own,
+// with authentication, size limits and metrics applied. Every call builds a new handler from
+// the current configuration, so it can be embedded in tests and other programs.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	if s.endpoints.UI {
+		mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(s.static))))
+	}
+
+	if s.endpoints.MCP {
+		mcpSrv := mcpPkg.NewServer(mcpPkg.Options{
+			Policies: s.policies,
+			Serials:  s.serials,
+			Defaults: s.defaults,
+			Auth:     s.auth,
+			Audit:    s.audit,
+			Limits:   s.limits,
+			Metrics:  s.metrics,
+		})
+		// Tool calls run in the request context, which carries the caller set up by auditContext
+		mux.Handle("/mcp", streamUntilShutdown(mcpServer.NewStreamableHTTPServer(mcpSrv)))
+	}
+
+	for pattern, handler := range s.handlers() {
+		if s.endpoints.serves(pattern) {
+			mux.HandleFunc(pattern, handler)
+		}
+	}
+
+	handler := auditContext(s.authenticate(mux))
+	// With an authenticator, client certificates are one way to log in among others
+	if s.tlsConfig != nil && s.tlsConfig.ClientCAs != nil && s.auth == nil {
+		handler = requireClientCert(handler)
+	}
+	return s.instrument(mux, limitBody(handler, s.httpOptions.MaxBodyBytes))
+}
+
+// Start serves HTTP, or HTTPS when SetTLS was called, until the process receives SIGINT or
+// SIGTERM, and then shuts down gracefully
+func (s *Server) Start(addr string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return s.Run(ctx, addr)
+}
+
+// Run listens on addr and serves like Serve
+func (s *Server) Run(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve serves HTTP, or HTTPS when SetTLS was called, on ln until ctx is done. It then stops
+// accepting connections and waits up to the shutdown timeout for running requests, whose
+// contexts are cancelled when the timeout expires. It returns nil after a graceful shutdown.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	// Requests carry the values of ctx but are only cancelled when shutting down takes too long
+	baseCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+	// MCP event streams never end by themselves, so they are closed as soon as shutdown starts
+	drainCtx, drain := context.WithCancel(context.Background())
+	defer drain()
+	baseCtx = context.WithValue(baseCtx, drainKey{}, drainCtx)
+
+	srv := s.httpServer(baseCtx, s.Handler())
+	srv.RegisterOnShutdown(drain)
+	servers := []*http.Server{srv}
+
+	errs := make(chan error, 2)
+	if s.tlsConfig == nil {
+		log.Printf("Server starting on %s", ln.Addr())
+		go func() { errs <- srv.Serve(ln) }()
+	} else {
+		srv.TLSConfig = s.tlsConfig
+		log.Printf("Server starting on %s (HTTPS)", ln.Addr())
+		go func() { errs <- srv.ServeTLS(ln, "", "") }()
+
+		if s.redirectAddr != "" {
+			redirect := s.httpServer(baseCtx, redirectHandler(ln.Addr().String()))
+			redirect.Addr = s.redirectAddr
+			servers = append(servers, redirect)
+			log.Printf("Redirecting HTTP on %s to HTTPS", s.redirectAddr)
+			go func() { errs <- redirect.ListenAndServe() }()
+		}
+	}
+
+	select {
+	case err := <-errs:
+		// A server failed, so the others are of no use either
+		for _, other := range servers {
+			other.Close()
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down")
+	shutdownCtx, done := context.WithCancel(context.Background())
+	if s.httpOptions.ShutdownTimeout > 0 {
+		shutdownCtx, done = context.WithTimeout(context.Background(), s.httpOptions.ShutdownTimeout)
+	}
+	defer done()
+
+	var shutdownErrs []error
+	for _, hs := range servers {
+		if err := hs.Shutdown(shutdownCtx); err != nil {
+			// Cancel the requests still running and drop their connections
+			cancel()
+			hs.Close()
+			shutdownErrs = append(shutdownErrs, fmt.Errorf("failed to shut down gracefully: %w", err))
+		}
+	}
+	return errors.Join(shutdownErrs...)
+}
+
+// httpServer creates an http.Server with the configured timeouts
+func (s *Server) httpServer(baseCtx context.Context, handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: s.httpOptions.ReadHeaderTimeout,
+		ReadTimeout:       s.httpOptions.ReadTimeout,
+		WriteTimeout:      s.httpOptions.WriteTimeout,
+		IdleTimeout:       s.httpOptions.IdleTimeout,
+		MaxHeaderBytes:    s.httpOptions.MaxHeaderBytes,
+		BaseContext:       func(net.Listener) context.Context { return baseCtx },
+	}
+}
+
+// drainKey keys the context in request contexts that is done once Serve starts shutting down
+type drainKey struct{}
+
+// streamUntilShutdown lifts the write timeout of the long-lived event stream of GET /mcp and
+// ends the stream when the server shuts down
+func streamUntilShutdown(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Fails for writers without deadlines, which need none lifted
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+		if drainCtx, ok := r.Context().Value(drainKey{}).(context.Context); ok {
+			ctx, cancel := context.WithCancel(r.Context())
+			defer cancel()
+			stop := context.AfterFunc(drainCtx, cancel)
+			defer stop()
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limitBody rejects request bodies larger than limit bytes with 413 Request Entity Too Large.
+// Bodies of unknown length fail while being read instead.
+func limitBody(next http.Handler, limit int64) http.Handler {
+	if limit <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > limit {
+			writeError(w, r, http.StatusRequestEntityTooLarge, fmt.Errorf("request body exceeds %d bytes", limit))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
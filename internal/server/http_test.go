@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeShutdown(t *testing.T) {
+	s, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	// Far longer than the test may take, so only an ended MCP stream lets Serve return in time
+	opts := DefaultHTTPOptions()
+	opts.ShutdownTimeout = time.Minute
+	s.SetHTTPOptions(opts)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(ctx, ln) }()
+
+	// Without keep-alives no idle connection is left for Shutdown to wait for
+	transport := &http.Transport{DisableKeepAlives: true}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+
+	baseURL := "http://" + ln.Addr().String()
+	resp, err := client.Get(baseURL + "/healthz")
+	if err != nil {
+		t.Fatalf("GET /healthz error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /healthz status = %d, want 200", resp.StatusCode)
+	}
+
+	// The MCP event stream stays open until the server shuts down
+	req, _ := http.NewRequest(http.MethodGet, baseURL+"/mcp", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	stream, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("GET /mcp error = %v", err)
+	}
+	defer stream.Body.Close()
+	if stream.StatusCode != http.StatusOK {
+		t.Fatalf("GET /mcp status = %d, want 200", stream.StatusCode)
+	}
+
+	transport.CloseIdleConnections()
+	cancel()
+
+	streamEnded := make(chan error, 1)
+	go func() {
+		_, err := io.ReadAll(stream.Body)
+		streamEnded <- err
+	}()
+	select {
+	case err := <-streamEnded:
+		if err != nil {
+			t.Errorf("Expected the MCP stream to end cleanly: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("The MCP stream did not end when shutdown started")
+	}
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Serve() error = %v, want a graceful shutdown", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Serve() did not return after its context was cancelled")
+	}
+
+	if _, err := client.Get(baseURL + "/healthz"); err == nil {
+		t.Error("Expected the listener to be closed")
+	}
+}
+
+func TestRequestSizeLimit(t *testing.T) {
+	s, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	opts := DefaultHTTPOptions()
+	opts.MaxBodyBytes = 64
+	s.SetHTTPOptions(opts)
+	handler := s.Handler()
+
+	body := `{"certificate":"` + strings.Repeat("A", 100) + `"}`
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/inspect", strings.NewReader(body)))
+	var apiErr APIError
+	if err := json.Unmarshal(rec.Body.Bytes(), &apiErr); err != nil || rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("POST /api/v1/inspect = %d %s, want 413 as JSON", rec.Code, rec.Body)
+	}
+
+	// Bodies without a Content-Length are cut off while being read
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/inspect", io.MultiReader(strings.NewReader(body)))
+	req.ContentLength = -1
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code == http.StatusOK {
+		t.Errorf("POST /api/v1/inspect of unknown length status = %d, want an error", rec.Code)
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	s, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	s.SetEndpoints(Endpoints{API: true})
+	handler := s.Handler()
+
+	for path, want := range map[string]int{
+		"/":             http.StatusNotFound,
+		"/static/x.css": http.StatusNotFound,
+		"/mcp":          http.StatusNotFound,
+		"/openapi.json": http.StatusOK,
+		"/healthz":      http.StatusOK,
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Errorf("GET %s status = %d, want %d", path, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/pvormste/certgen/internal/auth"
+	"github.com/pvormste/certgen/internal/ratelimit"
+)
+
+// SetLimits configures the rate limits and the concurrency limit of certificate and key
+// generation, from the web UI, the REST API and MCP alike. By default generation is not limited.
+func (s *Server) SetLimits(limits *ratelimit.Limits) {
+	s.limits = limits
+}
+
+// limit wraps a generating handler so it only runs within the rate limits of the client and
+// user and the bound on concurrent generations. Other requests are answered with
+// 429 Too Many Requests and a Retry-After header.
+func (s *Server) limit(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		release, err := s.limits.Acquire(r.Context(), r.RemoteAddr, principalName(r))
+		if err != nil {
+			var limitErr *ratelimit.LimitError
+			if errors.As(err, &limitErr) {
+				w.Header().Set("Retry-After", strconv.Itoa(limitErr.RetryAfterSeconds()))
+			}
+			writeError(w, r, http.StatusTooManyRequests, err)
+			return
+		}
+		defer release()
+		handler(w, r)
+	}
+}
+
+// principalName returns the name of the authenticated user, or "" without authentication
+func principalName(r *http.Request) string {
+	if p := auth.PrincipalFromContext(r.Context()); p != nil {
+		return p.Name
+	}
+	return ""
+}
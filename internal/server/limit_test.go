@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/pvormste/certgen/certificate"
+	"github.com/pvormste/certgen/internal/audit"
+	mcpPkg "github.com/pvormste/certgen/internal/mcp"
+	"github.com/pvormste/certgen/internal/ratelimit"
+)
+
+func TestLimits(t *testing.T) {
+	s, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	s.SetLimits(ratelimit.New(ratelimit.Config{ClientRate: 0.1, ClientBurst: 1}))
+	handler := s.Handler()
+
+	post := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"commonName":"Limited CA","expiryDays":1}`))
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post("/api/v1/ca", "192.0.2.1:1000"); rec.Code != http.StatusCreated {
+		t.Fatalf("First POST /api/v1/ca status = %d, body %s", rec.Code, rec.Body)
+	}
+	rec := post("/api/v1/ca", "192.0.2.1:2000")
+	var apiErr APIError
+	if err := json.Unmarshal(rec.Body.Bytes(), &apiErr); err != nil || rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Second POST /api/v1/ca = %d %s, want 429 as JSON", rec.Code, rec.Body)
+	}
+	if retryAfter := rec.Header().Get("Retry-After"); retryAfter != "10" {
+		t.Errorf("Retry-After = %q, want 10", retryAfter)
+	}
+	if rec := post("/api/v1/ca", "192.0.2.2:1000"); rec.Code != http.StatusCreated {
+		t.Errorf("POST /api/v1/ca from another client status = %d, want its own limit", rec.Code)
+	}
+
+	// Inspecting generates no keys and is not limited
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/inspect", strings.NewReader(`{"certificate":""}`))
+	req.RemoteAddr = "192.0.2.1:3000"
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code == http.StatusTooManyRequests {
+		t.Error("Expected inspection not to be rate limited")
+	}
+
+	t.Run("mcp", func(t *testing.T) {
+		mcpSrv := mcpPkg.NewServer(mcpPkg.Options{Serials: certificate.NewMemorySerialCounter(), Limits: s.limits})
+		call := func(tool, remoteAddr string) mcp.CallToolResult {
+			ctx := audit.WithRemoteAddr(context.Background(), remoteAddr)
+			message := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"` + tool + `","arguments":{"commonName":"MCP CA","certificate":""}}}`
+			resp, ok := mcpSrv.HandleMessage(ctx, json.RawMessage(message)).(mcp.JSONRPCResponse)
+			if !ok {
+				t.Fatalf("Unexpected MCP response for %s", tool)
+			}
+			return resp.Result.(mcp.CallToolResult)
+		}
+
+		if result := call("generate_ca", "192.0.2.3:1000"); result.IsError {
+			t.Fatalf("generate_ca failed: %+v", result.Content)
+		}
+		result := call("generate_ca", "192.0.2.3:1000")
+		if !result.IsError || !strings.Contains(mcpText(result), "retry after 10s") {
+			t.Errorf("Second generate_ca = %+v, want a rate limit error", result.Content)
+		}
+		if result := call("inspect_certificate", "192.0.2.3:1000"); strings.Contains(mcpText(result), "rate limit") {
+			t.Error("Expected inspect_certificate not to be rate limited")
+		}
+	})
+}
+
+func mcpText(result mcp.CallToolResult) string {
+	if len(result.Content) == 0 {
+		return ""
+	}
+	text, _ := result.Content[0].(mcp.TextContent)
+	return text.Text
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pvormste/certgen/assets"
+	"github.com/pvormste/certgen/certificate"
+	"github.com/pvormste/certgen/client"
+	"github.com/pvormste/certgen/internal/audit"
+	mcpPkg "github.com/pvormste/certgen/internal/mcp"
+)
+
+type openAPIDocument struct {
+	Paths      map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func TestOpenAPIPaths(t *testing.T) {
+	var doc openAPIDocument
+	if err := json.Unmarshal(assets.OpenAPISpec, &doc); err != nil {
+		t.Fatalf("OpenAPI document is not valid JSON: %v", err)
+	}
+
+	s := &Server{}
+	for pattern := range s.handlers() {
+		if _, ok := doc.Paths[pattern]; !ok {
+			t.Errorf("Route %s is not described in the OpenAPI document", pattern)
+		}
+	}
+	for path := range doc.Paths {
+		if _, ok := s.handlers()[path]; !ok && path != "/mcp" && path != "/static/{file}" {
+			t.Errorf("OpenAPI path %s has no route", path)
+		}
+	}
+}
+
+func TestOpenAPISchemas(t *testing.T) {
+	var doc openAPIDocument
+	if err := json.Unmarshal(assets.OpenAPISpec, &doc); err != nil {
+		t.Fatalf("OpenAPI document is not valid JSON: %v", err)
+	}
+
+	schemas := map[string]any{
+		"FormData":      FormData{},
+		"SSHFormData":   SSHFormData{},
+		"CAResponse":    mcpPkg.CAResponse{},
+		"CertResponse":  mcpPkg.CertResponse{},
+		"CertInfo":      certificate.CertInfo{},
+		"JWK":           certificate.JWK{},
+		"APIError":      APIError{},
+		"AuditEvent":    audit.Event{},
+		"AuditResponse": AuditResponse{},
+		"ReadyResponse": ReadyResponse{},
+	}
+	for name, v := range schemas {
+		var properties []string
+		for property := range doc.Components.Schemas[name].Properties {
+			properties = append(properties, property)
+		}
+		slices.Sort(properties)
+
+		if want := jsonFields(reflect.TypeOf(v)); !slices.Equal(properties, want) {
+			t.Errorf("Schema %s has properties %v, want %v", name, properties, want)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	s, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	ctx := context.Background()
+	c := client.NewClient(ts.URL)
+
+	ca, err := c.GenerateCA(ctx, client.FormData{CommonName: "Client CA", ExpiryDays: 1})
+	if err != nil {
+		t.Fatalf("GenerateCA() error = %v", err)
+	}
+
+	cert, err := c.GenerateCert(ctx, client.CertRequest{
+		FormData: client.FormData{CommonName: "client", ExpiryDays: 1, CertType: "client"},
+		CACert:   ca.Certificate,
+		CAKey:    ca.PrivateKey,
+	})
+	if err != nil {
+		t.Fatalf("GenerateCert() error = %v", err)
+	}
+
+	info, err := c.Inspect(ctx, cert.Certificate)
+	if err != nil {
+		t.Fatalf("Inspect() error = %v", err)
+	}
+	if info.Subject != "CN=client" || info.Issuer != "CN=Client CA" {
+		t.Errorf("Unexpected certificate: subject %q, issuer %q", info.Subject, info.Issuer)
+	}
+	if info.SubjectKeyID == "" || !strings.EqualFold(strings.ReplaceAll(info.SubjectKeyID, ":", ""), cert.PrivateJWK.Kid) {
+		t.Errorf("JWK kid %s does not match the SKI %s", cert.PrivateJWK.Kid, info.SubjectKeyID)
+	}
+
+	jwks, err := c.JWKS(ctx, cert.ChainPEM)
+	if err != nil {
+		t.Fatalf("JWKS() error = %v", err)
+	}
+	if len(jwks.Keys) != 2 {
+		t.Errorf("Expected 2 keys, got %d", len(jwks.Keys))
+	}
+
+	broken, err := c.GenerateBrokenZip(ctx, client.CertRequest{
+		FormData: client.FormData{CommonName: "broken", ExpiryDays: 1, DNSNames: []string{"localhost"}, Defects: []string{"expired", "wrong-ca"}},
+		CACert:   ca.Certificate,
+		CAKey:    ca.PrivateKey,
+	})
+	if err != nil {
+		t.Fatalf("GenerateBrokenZip() error = %v", err)
+	}
+	if files := zipFiles(t, broken); files["expired/cert.crt"] == nil || files["wrong-ca/cert.crt"] == nil || len(files) != 10 {
+		t.Errorf("GenerateBrokenZip() returned %d files, want both defects", len(files))
+	}
+
+	sshCA, err := c.GenerateSSHCAZip(ctx, client.SSHFormData{Comment: "client"})
+	if err != nil {
+		t.Fatalf("GenerateSSHCAZip() error = %v", err)
+	}
+	sshCert, err := c.SignSSHCertZip(ctx, client.SSHCertRequest{
+		SSHFormData: client.SSHFormData{CertType: "user", KeyID: "alice", Principals: []string{"alice"}, Validity: "1h"},
+		CAKey:       string(zipFiles(t, sshCA)["ssh_ca"]),
+	})
+	if err != nil {
+		t.Fatalf("SignSSHCertZip() error = %v", err)
+	}
+	if !slices.ContainsFunc(slices.Collect(maps.Keys(zipFiles(t, sshCert))), func(name string) bool { return strings.HasSuffix(name, "-cert.pub") }) {
+		t.Error("SignSSHCertZip() returned no certificate")
+	}
+
+	if err := c.Health(ctx); err != nil {
+		t.Errorf("Health() error = %v", err)
+	}
+	if ready, err := c.Ready(ctx); err != nil || ready.Status != "ok" {
+		t.Errorf("Ready() = %+v, %v", ready, err)
+	}
+	if _, err := c.RandomData(ctx, "server"); err != nil {
+		t.Errorf("RandomData() error = %v", err)
+	}
+
+	_, err = c.GenerateCA(ctx, client.FormData{CommonName: "Client CA"})
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest || len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "expiryDays" {
+		t.Errorf("GenerateCA() error = %#v, want a 400 naming expiryDays", err)
+	}
+}
+
+// zipFiles returns the contents of the files in a ZIP archive by name
+func zipFiles(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Invalid ZIP archive: %v", err)
+	}
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name], err = io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return files
+}
+
+// jsonFields returns the sorted JSON property names of a struct, including embedded structs
+func jsonFields(typ reflect.Type) []string {
+	var names []string
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Anonymous {
+			names = append(names, jsonFields(field.Type)...)
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
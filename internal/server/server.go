@@ -3,35 +3,98 @@ package server
 import (
 	"archive/zip"
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"html/template"
 	"io"
 	"io/fs"
-	"log"
 	"net/http"
+	"time"
 
-	mcpServer "github.com/mark3labs/mcp-go/server"
 	"github.com/pvormste/certgen/assets"
-	"github.com/pvormste/certgen/internal/certificate"
-	mcpPkg "github.com/pvormste/certgen/internal/mcp"
+	"github.com/pvormste/certgen/certificate"
+	"github.com/pvormste/certgen/internal/audit"
+	"github.com/pvormste/certgen/internal/auth"
+	"github.com/pvormste/certgen/internal/metrics"
 	"github.com/pvormste/certgen/internal/random"
+	"github.com/pvormste/certgen/internal/ratelimit"
 )
 
 // FormData holds the form data for certificate generation
 type FormData struct {
-	Organization string   `json:"organization"`
-	CommonName   string   `json:"commonName"`
-	Country      string   `json:"country"`
-	Locality     string   `json:"locality"`
-	ExpiryDays   int      `json:"expiryDays"`
-	IsClient     bool     `json:"isClient"`
-	DNSNames     []string `json:"dnsNames,omitempty"`
-	IPAddresses  []string `json:"ipAddresses,omitempty"`
+	Organization string `json:"organization"`
+	CommonName   string `json:"commonName"`
+	Country      string `json:"country"`
+	Locality     string `json:"locality"`
+	ExpiryDays   int    `json:"expiryDays"`
+	// Validity (e.g. "15m", "6h", "90d") and an explicit NotBefore/NotAfter window
+	// (RFC 3339 timestamps) override ExpiryDays
+	Validity       string     `json:"validity,omitempty"`
+	NotBefore      *time.Time `json:"notBefore,omitempty"`
+	NotAfter       *time.Time `json:"notAfter,omitempty"`
+	IsClient       bool       `json:"isClient"`
+	CertType       string     `json:"certType,omitempty"`
+	DNSNames       []string   `json:"dnsNames,omitempty"`
+	IPAddresses    []string   `json:"ipAddresses,omitempty"`
+	URIs           []string   `json:"uris,omitempty"`
+	EmailAddresses []string   `json:"emailAddresses,omitempty"`
+	SPIFFEID       string     `json:"spiffeId,omitempty"`
+
+	OrganizationalUnit string                  `json:"organizationalUnit,omitempty"`
+	Province           string                  `json:"province,omitempty"`
+	StreetAddress      string                  `json:"streetAddress,omitempty"`
+	PostalCode         string                  `json:"postalCode,omitempty"`
+	SerialNumber       string                  `json:"serialNumber,omitempty"`
+	ExtraAttributes    []certificate.Attribute `json:"extraAttributes,omitempty"`
+	DN                 string                  `json:"dn,omitempty"`
+
+	// Defects selects the deliberate flaws for /generate/broken, each at most once; empty means all of them
+	Defects []string `json:"defects,omitempty"`
+
+	// NameConstraints only applies to CA certificates
+	NameConstraints certificate.NameConstraints `json:"nameConstraints"`
+
+	// KeyUsage and ExtKeyUsage override the defaults of the certificate type
+	KeyUsage    []string `json:"keyUsage,omitempty"`
+	ExtKeyUsage []string `json:"extKeyUsage,omitempty"`
+
+	// SerialStrategy is "random", "sequential" or "specified"; Serial holds the specified
+	// serial number (CA certificates only support a specified or random serial)
+	SerialStrategy string `json:"serialStrategy,omitempty"`
+	Serial         string `json:"serial,omitempty"`
+
+	Extensions          []certificate.Extension         `json:"extensions,omitempty"`
+	CertificatePolicies []certificate.CertificatePolicy `json:"certificatePolicies,omitempty"`
+	MustStaple          bool                            `json:"mustStaple,omitempty"`
 }
 
 // Server represents the HTTP server for the certificate generator
 type Server struct {
 	templates *template.Template
+	static    fs.FS
+	policies  certificate.PolicySet
+	serials   certificate.SerialCounter
+
+	httpOptions HTTPOptions
+	endpoints   Endpoints
+	// defaults fill in what requests leave empty
+	defaults certificate.Defaults
+
+	// tlsConfig is set by SetTLS; the server speaks plain HTTP when it is nil
+	tlsConfig    *tls.Config
+	redirectAddr string
+
+	// auth is set by SetAuthenticator; every request is allowed when it is nil
+	auth *auth.Authenticator
+	// audit is set by SetAuditLog; nothing is recorded when it is nil
+	audit *audit.Log
+	// authFailures throttles the auth.failure events of each client address
+	authFailures authFailures
+	// limits is set by SetLimits; generation is not limited when it is nil
+	limits *ratelimit.Limits
+	// metrics counts the requests and certificates of this server and its MCP tools
+	metrics *metrics.Registry
 }
 
 // NewServer creates a new Server instance
@@ -41,42 +104,81 @@ func NewServer() (*Server, error) {
 	if err != nil {
 		return nil, err
 	}
+	static, err := fs.Sub(assets.StaticFS, "static")
+	if err != nil {
+		return nil, err
+	}
 
 	return &Server{
-		templates: tmpl,
+		templates:   tmpl,
+		static:      static,
+		httpOptions: DefaultHTTPOptions(),
+		endpoints:   Endpoints{UI: true, API: true, MCP: true},
+		metrics:     metrics.NewRegistry(),
 	}, nil
 }
 
-// Start starts the HTTP server
-func (s *Server) Start(addr string) error {
-	// Create a sub-filesystem for static files
-	staticRoot, err := fs.Sub(assets.StaticFS, "static")
-	if err != nil {
-		return err
-	}
+// SetPolicies configures the issuance policies enforced for certificates signed by matching CAs
+func (s *Server) SetPolicies(policies certificate.PolicySet) {
+	s.policies = policies
+}
 
-	// Static file server using embedded files
-	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(staticRoot))))
+// SetDefaults configures the subject attributes and validity used when a request leaves them
+// empty, from the web UI, the REST API and MCP alike
+func (s *Server) SetDefaults(defaults certificate.Defaults) {
+	s.defaults = defaults
+}
 
-	// MCP server setup
-	mcpSrv := mcpPkg.NewServer()
-	http.Handle("/mcp", mcpServer.NewStreamableHTTPServer(mcpSrv))
+// SetSerialCounter configures the counter used for sequential serial numbers. Without one,
+// requests for sequential serials are rejected, as counting them in memory would repeat
+// serials under the same CA after a restart.
+func (s *Server) SetSerialCounter(serials certificate.SerialCounter) {
+	s.serials = serials
+}
 
-	// Route handlers
-	http.HandleFunc("/", s.handleIndex)
-	http.HandleFunc("/generate/ca", s.handleGenerateCA)
-	http.HandleFunc("/generate/cert", s.handleGenerateCert)
-	http.HandleFunc("/gen/random/ca", s.handleRandomCA)
-	http.HandleFunc("/gen/random/server", s.handleRandomServer)
-	http.HandleFunc("/gen/random/client", s.handleRandomClient)
+// handlers returns the route handlers keyed by path. Every path except "/" is described
+// in the OpenAPI document served at /openapi.json.
+func (s *Server) handlers() map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"/":                  s.handleIndex,
+		"/openapi.json":      s.handleOpenAPI,
+		"/healthz":           s.handleHealthz,
+		"/readyz":            s.handleReadyz,
+		"/metrics":           s.metrics.Handler().ServeHTTP,
+		"/generate/ca":       s.require(auth.PermissionCreateCA, s.limit(s.handleGenerateCA)),
+		"/generate/cert":     s.require(auth.PermissionIssue, s.limit(s.handleGenerateCert)),
+		"/generate/broken":   s.require(auth.PermissionIssue, s.limit(s.handleGenerateBroken)),
+		"/inspect":           s.require(auth.PermissionInspect, s.handleInspect),
+		"/jwks":              s.require(auth.PermissionInspect, s.handleJWKS),
+		"/api/v1/ca":         s.require(auth.PermissionCreateCA, s.limit(s.handleAPICA)),
+		"/api/v1/cert":       s.require(auth.PermissionIssue, s.limit(s.handleAPICert)),
+		"/api/v1/inspect":    s.require(auth.PermissionInspect, s.handleAPIInspect),
+		"/api/v1/audit":      s.require(auth.PermissionAudit, s.handleAPIAudit),
+		"/generate/ssh/ca":   s.require(auth.PermissionCreateCA, s.limit(s.handleGenerateSSHCA)),
+		"/generate/ssh/cert": s.require(auth.PermissionIssue, s.limit(s.handleSignSSHCert)),
+		"/gen/random/ca":     s.require(auth.PermissionInspect, s.limit(s.handleRandomCA)),
+		"/gen/random/server": s.require(auth.PermissionInspect, s.limit(s.handleRandomServer)),
+		"/gen/random/client": s.require(auth.PermissionInspect, s.limit(s.handleRandomClient)),
+	}
+}
+
+// handleOpenAPI serves the OpenAPI document
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-	log.Printf("Server starting on %s", addr)
-	return http.ListenAndServe(addr, nil)
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(assets.OpenAPISpec); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 }
 
 // handleIndex renders the main page
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
-	if err := s.templates.ExecuteTemplate(w, "index.html", nil); err != nil {
+	if err := s.templates.ExecuteTemplate(w, "index.html", s.defaults); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -95,20 +197,31 @@ func (s *Server) handleGenerateCA(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	config := certificate.CAConfig{
-		Organization: formData.Organization,
-		CommonName:   formData.CommonName,
-		Country:      formData.Country,
-		Locality:     formData.Locality,
-		ExpiryDays:   formData.ExpiryDays,
+	config := formData.caConfig(s.defaults)
+
+	if err := config.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
+	start := time.Now()
 	bundle, err := certificate.GenerateCA(config)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	s.metrics.ObserveCertificate(audit.SourceHTTP, "ca", bundle.CertPEM, start)
+	event := audit.CertificateEvent(audit.ActionCreateCA, bundle.CertPEM, nil)
+	if !s.record(w, r, event) {
+		return
+	}
 
+	writeCAZip(w, bundle)
+	s.recordDownload(w, r, event)
+}
+
+// writeCAZip writes the CA certificate bundle as a ZIP download
+func writeCAZip(w http.ResponseWriter, bundle *certificate.CertBundle) {
 	// Create ZIP file
 	buf := new(bytes.Buffer)
 	zipWriter := zip.NewWriter(buf)
@@ -146,6 +259,12 @@ func (s *Server) handleGenerateCA(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Add JWK (with private key) and public JWKS to ZIP
+	if err := writeJWKFiles(zipWriter, "ca", bundle, nil); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	if err := zipWriter.Close(); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -159,83 +278,67 @@ func (s *Server) handleGenerateCA(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// handleGenerateCert handles client/server certificate generation
+// handleGenerateCert handles client/server/peer certificate generation
 func (s *Server) handleGenerateCert(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Parse multipart form
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+	formData, caCertPEM, caKeyPEM, ok := parseCertRequest(w, r)
+	if !ok {
 		return
 	}
-
-	// Get CA files
-	caCertFile, _, err := r.FormFile("caCert")
-	if err != nil {
-		http.Error(w, "CA certificate file required", http.StatusBadRequest)
+	if err := audit.AuthorizeCA(r.Context(), s.auth, s.audit, caCertPEM); err != nil {
+		s.writeAuthError(w, r, err)
 		return
 	}
-	defer caCertFile.Close()
 
-	caKeyFile, _, err := r.FormFile("caKey")
+	// Generate certificate
+	config, err := formData.certConfig(s.serials, s.defaults)
 	if err != nil {
-		http.Error(w, "CA private key file required", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	defer caKeyFile.Close()
 
-	// Read CA files
-	caCertPEM, err := io.ReadAll(caCertFile)
-	if err != nil {
-		http.Error(w, "Failed to read CA certificate", http.StatusInternalServerError)
+	if err := config.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	caKeyPEM, err := io.ReadAll(caKeyFile)
-	if err != nil {
-		http.Error(w, "Failed to read CA private key", http.StatusInternalServerError)
+	start := time.Now()
+	bundle, err := certificate.GenerateCertWithPolicy(config, s.policies.Lookup(caCertPEM), caCertPEM, caKeyPEM)
+	if errors.Is(err, certificate.ErrPolicyViolation) {
+		http.Error(w, err.Error(), http.StatusForbidden)
 		return
 	}
-
-	// Parse form data
-	var formData FormData
-	formDataStr := r.FormValue("formData")
-	if err := json.Unmarshal([]byte(formDataStr), &formData); err != nil {
-		http.Error(w, "Invalid form data", http.StatusBadRequest)
+	var validationErr *certificate.ValidationError
+	if errors.As(err, &validationErr) {
+		// e.g. a name rejected by the CA's name constraints
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	// Generate certificate
-	config := certificate.CertConfig{
-		Organization: formData.Organization,
-		CommonName:   formData.CommonName,
-		Country:      formData.Country,
-		Locality:     formData.Locality,
-		ExpiryDays:   formData.ExpiryDays,
-		IsClient:     formData.IsClient,
-		DNSNames:     formData.DNSNames,
-		IPAddresses:  formData.IPAddresses,
-	}
-
-	bundle, err := certificate.GenerateCert(config, caCertPEM, caKeyPEM)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	s.metrics.ObserveCertificate(audit.SourceHTTP, string(config.Type), bundle.CertPEM, start)
+	event := audit.CertificateEvent(audit.ActionIssue, bundle.CertPEM, caCertPEM)
+	event.Profile = string(config.Type)
+	if !s.record(w, r, event) {
+		return
+	}
+
+	writeCertZip(w, string(config.Type), bundle, caCertPEM)
+	s.recordDownload(w, r, event)
+}
 
+// writeCertZip writes a leaf certificate bundle as a ZIP download, naming the files after prefix
+func writeCertZip(w http.ResponseWriter, prefix string, bundle *certificate.CertBundle, caCertPEM []byte) {
 	// Create ZIP file
 	buf := new(bytes.Buffer)
 	zipWriter := zip.NewWriter(buf)
 
-	// Determine file prefix based on certificate type
-	prefix := "server"
-	if formData.IsClient {
-		prefix = "client"
-	}
-
 	// Add certificate to ZIP
 	certWriter, err := zipWriter.Create(prefix + ".crt")
 	if err != nil {
@@ -291,6 +394,12 @@ func (s *Server) handleGenerateCert(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Add JWK (with private key and x5c chain) and public JWKS to ZIP
+	if err := writeJWKFiles(zipWriter, prefix, bundle, caCertPEM); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	if err := zipWriter.Close(); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -304,6 +413,321 @@ func (s *Server) handleGenerateCert(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// parseCertRequest reads the CA files and form data of a multipart certificate request.
+// On failure it writes the error response and returns false.
+func parseCertRequest(w http.ResponseWriter, r *http.Request) (FormData, []byte, []byte, bool) {
+	var formData FormData
+
+	// Parse multipart form
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return formData, nil, nil, false
+	}
+
+	// Get CA files
+	caCertFile, _, err := r.FormFile("caCert")
+	if err != nil {
+		http.Error(w, "CA certificate file required", http.StatusBadRequest)
+		return formData, nil, nil, false
+	}
+	defer caCertFile.Close()
+
+	caKeyFile, _, err := r.FormFile("caKey")
+	if err != nil {
+		http.Error(w, "CA private key file required", http.StatusBadRequest)
+		return formData, nil, nil, false
+	}
+	defer caKeyFile.Close()
+
+	// Read CA files
+	caCertPEM, err := io.ReadAll(caCertFile)
+	if err != nil {
+		http.Error(w, "Failed to read CA certificate", http.StatusInternalServerError)
+		return formData, nil, nil, false
+	}
+
+	caKeyPEM, err := io.ReadAll(caKeyFile)
+	if err != nil {
+		http.Error(w, "Failed to read CA private key", http.StatusInternalServerError)
+		return formData, nil, nil, false
+	}
+
+	// Parse form data
+	formDataStr := r.FormValue("formData")
+	if err := json.Unmarshal([]byte(formDataStr), &formData); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return formData, nil, nil, false
+	}
+
+	return formData, caCertPEM, caKeyPEM, true
+}
+
+// caConfig converts the form data into a CA certificate config, filling in empty fields from defaults
+func (f FormData) caConfig(defaults certificate.Defaults) certificate.CAConfig {
+	config := certificate.CAConfig{
+		Organization:        f.Organization,
+		CommonName:          f.CommonName,
+		Country:             f.Country,
+		Locality:            f.Locality,
+		ExpiryDays:          f.ExpiryDays,
+		Validity:            f.Validity,
+		NotBefore:           timeValue(f.NotBefore),
+		NotAfter:            timeValue(f.NotAfter),
+		OrganizationalUnit:  f.OrganizationalUnit,
+		Province:            f.Province,
+		StreetAddress:       f.StreetAddress,
+		PostalCode:          f.PostalCode,
+		SerialNumber:        f.SerialNumber,
+		ExtraAttributes:     f.ExtraAttributes,
+		DN:                  f.DN,
+		NameConstraints:     f.NameConstraints,
+		Serial:              f.Serial,
+		Extensions:          f.Extensions,
+		CertificatePolicies: f.CertificatePolicies,
+		MustStaple:          f.MustStaple,
+	}
+	defaults.ApplyCA(&config)
+	return config
+}
+
+// certConfig converts the form data into a client/server/peer certificate config,
+// counting sequential serial numbers with serials and filling in empty fields from defaults
+func (f FormData) certConfig(serials certificate.SerialCounter, defaults certificate.Defaults) (certificate.CertConfig, error) {
+	// Determine certificate type, falling back to the legacy isClient flag
+	certType, err := certificate.ParseCertType(f.CertType)
+	if err != nil {
+		return certificate.CertConfig{}, err
+	}
+	if f.CertType == "" && f.IsClient {
+		certType = certificate.CertTypeClient
+	}
+
+	config := certificate.CertConfig{
+		Organization:        f.Organization,
+		CommonName:          f.CommonName,
+		Country:             f.Country,
+		Locality:            f.Locality,
+		ExpiryDays:          f.ExpiryDays,
+		Validity:            f.Validity,
+		NotBefore:           timeValue(f.NotBefore),
+		NotAfter:            timeValue(f.NotAfter),
+		Type:                certType,
+		DNSNames:            f.DNSNames,
+		IPAddresses:         f.IPAddresses,
+		URIs:                f.URIs,
+		EmailAddresses:      f.EmailAddresses,
+		SPIFFEID:            f.SPIFFEID,
+		OrganizationalUnit:  f.OrganizationalUnit,
+		Province:            f.Province,
+		StreetAddress:       f.StreetAddress,
+		PostalCode:          f.PostalCode,
+		SerialNumber:        f.SerialNumber,
+		ExtraAttributes:     f.ExtraAttributes,
+		DN:                  f.DN,
+		KeyUsage:            f.KeyUsage,
+		ExtKeyUsage:         f.ExtKeyUsage,
+		SerialStrategy:      certificate.SerialStrategy(f.SerialStrategy),
+		Serial:              f.Serial,
+		SerialCounter:       serials,
+		Extensions:          f.Extensions,
+		CertificatePolicies: f.CertificatePolicies,
+		MustStaple:          f.MustStaple,
+	}
+	defaults.ApplyCert(&config)
+	return config, nil
+}
+
+// handleGenerateBroken handles generation of deliberately invalid certificates for negative tests
+func (s *Server) handleGenerateBroken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	formData, caCertPEM, caKeyPEM, ok := parseCertRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := audit.AuthorizeCA(r.Context(), s.auth, s.audit, caCertPEM); err != nil {
+		s.writeAuthError(w, r, err)
+		return
+	}
+
+	config, err := formData.certConfig(s.serials, s.defaults)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	defects, err := certificate.ParseDefects(formData.Defects)
+	if err != nil {
+		http.Error(w, "defects: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.policies.Lookup(caCertPEM).Apply(&config); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	start := time.Now()
+	certs, err := certificate.GenerateBrokenCerts(config, defects, caCertPEM, caKeyPEM)
+	var validationErr *certificate.ValidationError
+	if errors.As(err, &validationErr) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for _, broken := range certs {
+		s.metrics.ObserveCertificate(audit.SourceHTTP, "broken", broken.Bundle.CertPEM, start)
+		event := audit.CertificateEvent(audit.ActionIssue, broken.Bundle.CertPEM, caCertPEM)
+		event.Profile = string(config.Type)
+		event.Defect = string(broken.Defect)
+		if !s.record(w, r, event) {
+			return
+		}
+	}
+
+	// Create ZIP file with one directory per defect
+	buf := new(bytes.Buffer)
+	zipWriter := zip.NewWriter(buf)
+
+	for _, broken := range certs {
+		dir := string(broken.Defect) + "/"
+		files := []struct {
+			name string
+			data []byte
+		}{
+			{dir + "cert.crt", broken.Bundle.CertPEM},
+			{dir + "cert.key", broken.Bundle.KeyPEM},
+			{dir + "cert.pem", broken.Bundle.UnifiedPEM()},
+			{dir + "cert-chain.pem", broken.Bundle.ChainPEM(caCertPEM)},
+			{dir + "README.txt", []byte(broken.Defect.Description() + "\n")},
+		}
+		for _, file := range files {
+			fileWriter, err := zipWriter.Create(file.name)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if _, err := fileWriter.Write(file.data); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", "attachment; filename=broken-certificates.zip")
+	if _, err := io.Copy(w, buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.recordDownload(w, r, audit.CertificateEvent(audit.ActionDownload, nil, caCertPEM))
+}
+
+// handleInspect summarizes the PEM encoded certificate in the request body as JSON
+func (s *Server) handleInspect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	certPEM, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "Failed to read certificate", http.StatusBadRequest)
+		return
+	}
+
+	info, err := certificate.Inspect(certPEM)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(info); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleJWKS converts the PEM encoded certificates in the request body into a JSON Web Key Set
+func (s *Server) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	certsPEM, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "Failed to read certificates", http.StatusBadRequest)
+		return
+	}
+
+	jwks, err := certificate.ParseJWKS(certsPEM)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	if err := json.NewEncoder(w).Encode(jwks); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// writeJWKFiles adds <prefix>-jwk.json with the key pair and <prefix>-jwks.json with the
+// public key set to the ZIP
+func writeJWKFiles(zipWriter *zip.Writer, prefix string, bundle *certificate.CertBundle, caCertPEM []byte) error {
+	privateJWK, err := bundle.PrivateJWK(caCertPEM)
+	if err != nil {
+		return err
+	}
+	publicJWKS, err := bundle.PublicJWKS(caCertPEM)
+	if err != nil {
+		return err
+	}
+
+	files := []struct {
+		name string
+		v    any
+	}{
+		{prefix + "-jwk.json", privateJWK},
+		{prefix + "-jwks.json", publicJWKS},
+	}
+	for _, file := range files {
+		fileWriter, err := zipWriter.Create(file.name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(fileWriter)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// timeValue dereferences an optional timestamp, returning the zero time when unset
+func timeValue(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
 // handleRandomCA handles random CA data generation
 func (s *Server) handleRandomCA(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
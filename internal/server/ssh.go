@@ -0,0 +1,210 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pvormste/certgen/certificate"
+	"github.com/pvormste/certgen/internal/audit"
+	"github.com/pvormste/certgen/internal/ssh"
+)
+
+// SSHFormData holds the form data for SSH CA generation and certificate signing
+type SSHFormData struct {
+	// Comment is stored in the generated public key
+	Comment string `json:"comment,omitempty"`
+	// HostPatterns restricts the known_hosts line of a new CA
+	HostPatterns []string `json:"hostPatterns,omitempty"`
+
+	CertType   string   `json:"certType,omitempty"`
+	KeyID      string   `json:"keyId,omitempty"`
+	Principals []string `json:"principals,omitempty"`
+	// Validity (e.g. "8h", "30d") and an explicit ValidAfter/ValidBefore window (RFC 3339 timestamps)
+	Validity        string            `json:"validity,omitempty"`
+	ValidAfter      *time.Time        `json:"validAfter,omitempty"`
+	ValidBefore     *time.Time        `json:"validBefore,omitempty"`
+	CriticalOptions map[string]string `json:"criticalOptions,omitempty"`
+	// Extensions of user certificates; the ssh-keygen defaults are used when omitted
+	Extensions map[string]string `json:"extensions"`
+	Serial     uint64            `json:"serial,omitempty"`
+	// PublicKey is an existing authorized_keys encoded key to certify; a key pair is generated when empty
+	PublicKey string `json:"publicKey,omitempty"`
+}
+
+// handleGenerateSSHCA handles SSH CA key generation
+func (s *Server) handleGenerateSSHCA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var formData SSHFormData
+	if err := json.NewDecoder(r.Body).Decode(&formData); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	start := time.Now()
+	bundle, err := ssh.GenerateCA(ssh.CAConfig{
+		Comment:      formData.Comment,
+		HostPatterns: formData.HostPatterns,
+	})
+	var validationErr *certificate.ValidationError
+	if errors.As(err, &validationErr) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.metrics.ObserveSSH(audit.SourceHTTP, "ssh-ca", bundle.PublicKey, start)
+	fingerprint, _ := ssh.CAFingerprint(bundle.PrivateKey)
+	event := audit.Event{Action: audit.ActionCreateSSHCA, Fingerprint: fingerprint, Subject: formData.Comment}
+	if !s.record(w, r, event) {
+		return
+	}
+
+	writeSSHZip(w, "ssh-ca.zip", []sshFile{
+		{name: "ssh_ca", data: bundle.PrivateKey, private: true},
+		{name: "ssh_ca.pub", data: bundle.PublicKey},
+		{name: "authorized_keys", data: bundle.AuthorizedKeysLine},
+		{name: "known_hosts", data: bundle.KnownHostsLine},
+	})
+	s.recordDownload(w, r, event)
+}
+
+// handleSignSSHCert handles signing of SSH user and host certificates. Errors are answered
+// with an APIError, which lists the invalid fields of a rejected configuration.
+func (s *Server) handleSignSSHCert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		writeAPIError(w, http.StatusBadRequest, errors.New("failed to parse form"))
+		return
+	}
+
+	caKeyFile, _, err := r.FormFile("caKey")
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, errors.New("SSH CA private key file required"))
+		return
+	}
+	defer caKeyFile.Close()
+
+	caKey, err := io.ReadAll(caKeyFile)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, errors.New("failed to read SSH CA private key"))
+		return
+	}
+	fingerprint, _ := ssh.CAFingerprint(caKey)
+	if err := audit.AuthorizeFingerprint(r.Context(), s.auth, s.audit, fingerprint); err != nil {
+		s.writeAuthError(w, r, err)
+		return
+	}
+
+	var formData SSHFormData
+	if err := json.Unmarshal([]byte(r.FormValue("formData")), &formData); err != nil {
+		writeAPIError(w, http.StatusBadRequest, errors.New("invalid form data"))
+		return
+	}
+
+	certType, err := ssh.ParseCertType(formData.CertType)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	config := ssh.CertConfig{
+		Type:            certType,
+		KeyID:           formData.KeyID,
+		Principals:      formData.Principals,
+		Validity:        formData.Validity,
+		ValidAfter:      timeValue(formData.ValidAfter),
+		ValidBefore:     timeValue(formData.ValidBefore),
+		CriticalOptions: formData.CriticalOptions,
+		Extensions:      formData.Extensions,
+		Serial:          formData.Serial,
+		PublicKey:       []byte(formData.PublicKey),
+		Comment:         formData.Comment,
+	}
+
+	if err := config.Validate(); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	start := time.Now()
+	bundle, err := ssh.SignCert(config, caKey)
+	if err != nil {
+		writeAPIError(w, apiErrorStatus(err), err)
+		return
+	}
+	s.metrics.ObserveSSH(audit.SourceHTTP, "ssh-"+string(certType), bundle.Certificate, start)
+	event := audit.SSHCertificateEvent(bundle.Certificate, fingerprint)
+	if !s.record(w, r, event) {
+		return
+	}
+
+	name := bundle.KeyFileName(certType)
+	files := []sshFile{
+		{name: name + "-cert.pub", data: bundle.Certificate},
+		{name: name + ".pub", data: bundle.PublicKey},
+	}
+	if len(bundle.PrivateKey) > 0 {
+		files = append(files, sshFile{name: name, data: bundle.PrivateKey, private: true})
+	}
+	writeSSHZip(w, "ssh-"+string(certType)+"-certificate.zip", files)
+	s.recordDownload(w, r, event)
+}
+
+// sshFile is a single file of an SSH download
+type sshFile struct {
+	name    string
+	data    []byte
+	private bool
+}
+
+// writeSSHZip writes the files as a ZIP download. Private keys are stored with 0600
+// permissions, as ssh refuses to use keys readable by others.
+func writeSSHZip(w http.ResponseWriter, filename string, files []sshFile) {
+	buf := new(bytes.Buffer)
+	zipWriter := zip.NewWriter(buf)
+
+	for _, file := range files {
+		header := &zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: time.Now()}
+		header.SetMode(0o644)
+		if file.private {
+			header.SetMode(0o600)
+		}
+
+		fileWriter, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if _, err := fileWriter.Write(file.data); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", "attachment; filename="+filename)
+	if _, err := io.Copy(w, buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
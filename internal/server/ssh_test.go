@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pvormste/certgen/client"
+	"github.com/pvormste/certgen/internal/ssh"
+)
+
+func TestSignSSHCertErrors(t *testing.T) {
+	s, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	ca, err := ssh.GenerateCA(ssh.CAConfig{})
+	if err != nil {
+		t.Fatalf("GenerateCA() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		req         client.SSHCertRequest
+		wantMessage string
+		wantField   string
+	}{
+		{
+			name:        "invalid CA key",
+			req:         client.SSHCertRequest{SSHFormData: client.SSHFormData{CertType: "user", Principals: []string{"alice"}, Validity: "1h"}, CAKey: "not a key"},
+			wantMessage: "invalid SSH CA key",
+		},
+		{
+			name:        "invalid configuration",
+			req:         client.SSHCertRequest{SSHFormData: client.SSHFormData{CertType: "user", Validity: "1h"}, CAKey: string(ca.PrivateKey)},
+			wantMessage: "at least one principal is required",
+			wantField:   "principals",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.NewClient(ts.URL).SignSSHCertZip(context.Background(), tt.req)
+			var apiErr *client.Error
+			if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest {
+				t.Fatalf("SignSSHCertZip() error = %v, want 400 Bad Request", err)
+			}
+			if !strings.Contains(apiErr.Message, tt.wantMessage) {
+				t.Errorf("Message = %q, want it to contain %q", apiErr.Message, tt.wantMessage)
+			}
+			if tt.wantField != "" && (len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != tt.wantField) {
+				t.Errorf("Fields = %+v, want %s", apiErr.Fields, tt.wantField)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pvormste/certgen/certificate"
+)
+
+// SelfIssuedCACommonName is the Common Name of the CA bootstrapped for serving HTTPS
+const SelfIssuedCACommonName = "Certgen Server CA"
+
+// selfIssuedCAValidity is long enough that a trusted bootstrapped CA does not need replacing
+const selfIssuedCAValidity = 10 * 365 * 24 * time.Hour
+
+// TLSOptions configures HTTPS. Without CertFile and KeyFile the server issues its own
+// certificates from a bootstrapped CA.
+type TLSOptions struct {
+	// CertFile and KeyFile are a PEM encoded certificate (optionally followed by its chain) and key
+	CertFile string
+	KeyFile  string
+	// Hostnames restricts the names self-issued certificates are issued for ('*' wildcards allowed).
+	// When empty, only the host of ListenAddr is allowed, or, when listening on all interfaces,
+	// localhost, the loopback addresses and the machine's host name.
+	Hostnames []string
+	// ListenAddr is the address the server listens on
+	ListenAddr string
+	// CADir keeps the bootstrapped CA (ca.crt, ca.key) across restarts; the CA only lives in memory when empty
+	CADir string
+	// RedirectAddr is an optional plain HTTP address that redirects every request to HTTPS
+	RedirectAddr string
+	// ClientCAFile holds PEM encoded CA certificates; when set, every request needs a client
+	// certificate issued by one of them. With an authenticator (see SetAuthenticator) client
+	// certificates are only verified, and the authenticator decides whether they suffice.
+	ClientCAFile string
+}
+
+// SetTLS makes Start serve HTTPS. Certificates and the client CAs are loaded immediately so
+// configuration errors surface before the server starts.
+func (s *Server) SetTLS(opts TLSOptions) error {
+	config, err := opts.tlsConfig()
+	if err != nil {
+		return err
+	}
+	s.tlsConfig = config
+	s.redirectAddr = opts.RedirectAddr
+	return nil
+}
+
+func (opts TLSOptions) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	switch {
+	case opts.CertFile != "" || opts.KeyFile != "":
+		if opts.CertFile == "" || opts.KeyFile == "" {
+			return nil, fmt.Errorf("both a TLS certificate and key file are required")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	default:
+		ca, err := loadOrCreateCA(opts.CADir)
+		if err != nil {
+			return nil, err
+		}
+		hostnames := opts.Hostnames
+		if len(hostnames) == 0 {
+			hostnames = defaultHostnames(opts.ListenAddr)
+		}
+		issuer := certificate.NewDynamicIssuer(ca, certificate.WithAllowedNames(hostnames...))
+		config.GetCertificate = issuer.GetCertificate
+	}
+
+	if opts.ClientCAFile != "" {
+		pemData, err := os.ReadFile(opts.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pemData) {
+			return nil, fmt.Errorf("no certificates found in client CA file %s", opts.ClientCAFile)
+		}
+		config.ClientCAs = pool
+		// Verified when presented; requireClientCert answers requests without one with a
+		// readable error instead of a failed handshake
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return config, nil
+}
+
+// defaultHostnames returns the names self-issued certificates are limited to without explicit
+// Hostnames, so clients cannot make the server generate keys for any name they send
+func defaultHostnames(listenAddr string) []string {
+	host, _, err := net.SplitHostPort(listenAddr)
+	if err == nil && host != "" {
+		if ip := net.ParseIP(host); ip == nil || !ip.IsUnspecified() {
+			return []string{host}
+		}
+	}
+
+	hostnames := []string{"localhost", "127.0.0.1", "::1"}
+	if name, err := os.Hostname(); err == nil && name != "" {
+		hostnames = append(hostnames, name)
+	}
+	return hostnames
+}
+
+// loadOrCreateCA loads the bootstrapped CA from dir, or creates it and writes it there.
+// With an empty dir a new in-memory CA is created.
+func loadOrCreateCA(dir string) (*certificate.Issued, error) {
+	if dir == "" {
+		ca, err := newSelfIssuedCA()
+		if err != nil {
+			return nil, err
+		}
+		logSelfIssuedCA(ca, "")
+		return ca, nil
+	}
+
+	certPath := filepath.Join(dir, "ca.crt")
+	keyPath := filepath.Join(dir, "ca.key")
+
+	certPEM, certErr := os.ReadFile(certPath)
+	keyPEM, keyErr := os.ReadFile(keyPath)
+	if certErr == nil && keyErr == nil {
+		ca, err := certificate.ParseIssued(&certificate.CertBundle{CertPEM: certPEM, KeyPEM: keyPEM})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS CA from %s: %w", dir, err)
+		}
+		logSelfIssuedCA(ca, certPath)
+		return ca, nil
+	}
+	if !errors.Is(certErr, fs.ErrNotExist) || !errors.Is(keyErr, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load TLS CA from %s: %w", dir, errors.Join(certErr, keyErr))
+	}
+
+	ca, err := newSelfIssuedCA()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create TLS CA directory: %w", err)
+	}
+	if err := os.WriteFile(keyPath, ca.KeyPEM, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write TLS CA key: %w", err)
+	}
+	if err := os.WriteFile(certPath, ca.CertPEM, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write TLS CA certificate: %w", err)
+	}
+	logSelfIssuedCA(ca, certPath)
+	return ca, nil
+}
+
+func newSelfIssuedCA() (*certificate.Issued, error) {
+	ca, err := certificate.NewCA(certificate.WithCommonName(SelfIssuedCACommonName), certificate.WithValidity(selfIssuedCAValidity))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create TLS CA: %w", err)
+	}
+	return ca, nil
+}
+
+func logSelfIssuedCA(ca *certificate.Issued, certPath string) {
+	fingerprint, _ := certificate.Fingerprint(ca.CertPEM)
+	if certPath == "" {
+		log.Printf("Serving HTTPS with certificates from an in-memory CA (SHA-256 fingerprint %s)", fingerprint)
+		return
+	}
+	log.Printf("Serving HTTPS with certificates from the CA in %s (SHA-256 fingerprint %s); trust it to avoid browser warnings", certPath, fingerprint)
+}
+
+// requireClientCert rejects requests without a verified client certificate
+func requireClientCert(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			http.Error(w, "A client certificate issued by a trusted CA is required", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// redirectHandler redirects plain HTTP requests to the same URL on the HTTPS address
+func redirectHandler(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		host = strings.Trim(host, "[]")
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
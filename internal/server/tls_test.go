@@ -0,0 +1,158 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/pvormste/certgen/certgentest"
+	"github.com/pvormste/certgen/certificate"
+)
+
+func TestSelfIssuedTLS(t *testing.T) {
+	dir := t.TempDir()
+
+	config, err := TLSOptions{CADir: dir, Hostnames: []string{"localhost", "127.0.0.1"}}.tlsConfig()
+	if err != nil {
+		t.Fatalf("tlsConfig() error = %v", err)
+	}
+	caPEM, err := os.ReadFile(filepath.Join(dir, "ca.crt"))
+	if err != nil {
+		t.Fatalf("Expected the CA certificate to be written: %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "ca.key")); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("Expected the CA key to be written with mode 0600: %v", err)
+	}
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	// StartTLS adds its own certificate, which crypto/tls prefers for handshakes without SNI
+	ts.TLS = config
+	ts.StartTLS()
+	defer ts.Close()
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(caPEM)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, ServerName: "localhost"}}}
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	resp.Body.Close()
+
+	// A restart reuses the CA clients already trust
+	if _, err := (TLSOptions{CADir: dir}).tlsConfig(); err != nil {
+		t.Fatalf("tlsConfig() error = %v", err)
+	}
+	reloaded, _ := os.ReadFile(filepath.Join(dir, "ca.crt"))
+	if !bytes.Equal(reloaded, caPEM) {
+		t.Error("Expected the existing CA to be reused")
+	}
+}
+
+func TestSelfIssuedTLSDefaultHostnames(t *testing.T) {
+	config, err := TLSOptions{ListenAddr: "127.0.0.1:8443"}.tlsConfig()
+	if err != nil {
+		t.Fatalf("tlsConfig() error = %v", err)
+	}
+	if _, err := config.GetCertificate(&tls.ClientHelloInfo{ServerName: "127.0.0.1"}); err != nil {
+		t.Errorf("GetCertificate() for the listen host error = %v", err)
+	}
+	if _, err := config.GetCertificate(&tls.ClientHelloInfo{ServerName: "attacker.example"}); !errors.Is(err, certificate.ErrNameNotAllowed) {
+		t.Errorf("GetCertificate() for another name error = %v, want ErrNameNotAllowed", err)
+	}
+
+	if got := defaultHostnames(":8443"); !slices.Contains(got, "localhost") || slices.Contains(got, "") {
+		t.Errorf("defaultHostnames(\":8443\") = %v, want localhost and the machine's names", got)
+	}
+}
+
+func TestTLSOptionsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts TLSOptions
+	}{
+		{name: "key without certificate", opts: TLSOptions{KeyFile: "server.key"}},
+		{name: "missing files", opts: TLSOptions{CertFile: "missing.crt", KeyFile: "missing.key"}},
+		{name: "missing client CA file", opts: TLSOptions{ClientCAFile: "missing.crt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.opts.tlsConfig(); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestRequireClientCert(t *testing.T) {
+	pki := certgentest.New(t)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "server.crt")
+	keyFile := filepath.Join(dir, "server.key")
+	clientCAFile := filepath.Join(dir, "client-ca.crt")
+	for path, data := range map[string][]byte{certFile: pki.Server.CertPEM, keyFile: pki.Server.KeyPEM, clientCAFile: pki.CA.CertPEM} {
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config, err := TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: clientCAFile}.tlsConfig()
+	if err != nil {
+		t.Fatalf("tlsConfig() error = %v", err)
+	}
+	ts := pki.NewServerWith(t, requireClientCert(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})), config, pki.ClientTLSConfig(pki.Client))
+
+	tests := []struct {
+		name       string
+		clientCert *certificate.Issued
+		wantStatus int
+	}{
+		{name: "with client certificate", clientCert: pki.Client, wantStatus: http.StatusOK},
+		{name: "without client certificate", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: pki.ClientTLSConfig(tt.clientCert)}}
+			resp, err := client.Get(ts.URL)
+			if err != nil {
+				t.Fatalf("GET error = %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		httpsAddr string
+		host      string
+		want      string
+	}{
+		{httpsAddr: ":443", host: "certgen.test", want: "https://certgen.test/generate/ca?x=1"},
+		{httpsAddr: ":8443", host: "certgen.test:8080", want: "https://certgen.test:8443/generate/ca?x=1"},
+		{httpsAddr: ":443", host: "[::1]:80", want: "https://[::1]/generate/ca?x=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/generate/ca?x=1", nil)
+			req.Host = tt.host
+			rec := httptest.NewRecorder()
+			redirectHandler(tt.httpsAddr).ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != tt.want {
+				t.Errorf("redirect = %d %q, want %q", rec.Code, rec.Header().Get("Location"), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,411 @@
+// Package ssh implements an OpenSSH certificate authority: it generates Ed25519 CA keys,
+// signs user and host certificates and builds the authorized_keys and known_hosts lines
+// that trust the CA
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pvormste/certgen/certificate"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// CertType identifies whether an SSH certificate authenticates a user or a host
+type CertType string
+
+const (
+	// CertTypeUser is a certificate a user presents to log in to a host
+	CertTypeUser CertType = "user"
+	// CertTypeHost is a certificate a host presents to prove its identity to clients
+	CertTypeHost CertType = "host"
+)
+
+// ParseCertType converts a string into a CertType, defaulting to CertTypeUser when empty
+func ParseCertType(s string) (CertType, error) {
+	switch CertType(s) {
+	case "", CertTypeUser:
+		return CertTypeUser, nil
+	case CertTypeHost:
+		return CertTypeHost, nil
+	default:
+		return "", fmt.Errorf("unknown SSH certificate type %q", s)
+	}
+}
+
+// ErrInvalidCAKey is returned when the SSH CA private key used for signing cannot be parsed
+var ErrInvalidCAKey = errors.New("invalid SSH CA key")
+
+// DefaultUserExtensions are the extensions ssh-keygen grants user certificates by default
+var DefaultUserExtensions = map[string]string{
+	"permit-X11-forwarding":   "",
+	"permit-agent-forwarding": "",
+	"permit-port-forwarding":  "",
+	"permit-pty":              "",
+	"permit-user-rc":          "",
+}
+
+// knownCriticalOptions lists the critical options understood by OpenSSH
+var knownCriticalOptions = map[string]bool{
+	"force-command":   true,
+	"source-address":  true,
+	"verify-required": true,
+}
+
+// CAConfig holds configuration for SSH CA key generation
+type CAConfig struct {
+	// Comment is stored in the public key, e.g. "dev-ssh-ca"
+	Comment string
+	// HostPatterns restricts the hosts the CA is trusted for in the known_hosts line, "*" when empty
+	HostPatterns []string
+}
+
+// CABundle contains the SSH CA key pair and the lines needed to trust it
+type CABundle struct {
+	// PrivateKey is the OpenSSH encoded private key
+	PrivateKey []byte
+	// PublicKey is the public key in authorized_keys format
+	PublicKey []byte
+	// AuthorizedKeysLine trusts the CA for user certificates (in ~/.ssh/authorized_keys)
+	AuthorizedKeysLine []byte
+	// KnownHostsLine trusts the CA for host certificates (in ~/.ssh/known_hosts)
+	KnownHostsLine []byte
+}
+
+// CertConfig holds configuration for signing a user or host certificate
+type CertConfig struct {
+	Type CertType
+	// KeyID identifies the certificate in server logs, defaults to the first principal
+	KeyID string
+	// Principals are the user names or host names the certificate is valid for
+	Principals []string
+	// Validity is a duration such as "8h" or "30d" (see certificate.ParseValidity).
+	// ValidAfter and ValidBefore pin the window explicitly; without ValidAfter the window
+	// starts now, backdated by certificate.DefaultBackdate to tolerate clock skew.
+	Validity    string
+	ValidAfter  time.Time
+	ValidBefore time.Time
+	// CriticalOptions such as force-command or source-address, only allowed for user certificates
+	CriticalOptions map[string]string
+	// Extensions of user certificates; DefaultUserExtensions are used when nil
+	Extensions map[string]string
+	// Serial is the certificate serial number, random when zero
+	Serial uint64
+	// PublicKey is the authorized_keys encoded key to certify. When empty, a new
+	// Ed25519 key pair is generated and returned with the certificate.
+	PublicKey []byte
+	// Comment is stored with a generated key pair
+	Comment string
+}
+
+// CertBundle contains a signed SSH certificate and, if one was generated, its key pair
+type CertBundle struct {
+	// Certificate is the certificate in authorized_keys format (the "-cert.pub" file)
+	Certificate []byte
+	// PrivateKey is the OpenSSH encoded private key, empty when an existing public key was signed
+	PrivateKey []byte
+	// PublicKey is the certified public key in authorized_keys format
+	PublicKey []byte
+}
+
+// KeyFileName returns the file name OpenSSH uses by default for the certified key, e.g.
+// "id_ed25519" for a user or "ssh_host_rsa_key" for a host. The public key and certificate
+// go into the same name with ".pub" and "-cert.pub" appended.
+func (b *CertBundle) KeyFileName(certType CertType) string {
+	algorithm := "ed25519"
+	if pub, _, _, _, err := gossh.ParseAuthorizedKey(b.PublicKey); err == nil {
+		switch pub.Type() {
+		case gossh.KeyAlgoRSA:
+			algorithm = "rsa"
+		case gossh.KeyAlgoECDSA256, gossh.KeyAlgoECDSA384, gossh.KeyAlgoECDSA521:
+			algorithm = "ecdsa"
+		case gossh.KeyAlgoSKECDSA256:
+			algorithm = "ecdsa_sk"
+		case gossh.KeyAlgoSKED25519:
+			algorithm = "ed25519_sk"
+		}
+	}
+
+	if certType == CertTypeHost {
+		return "ssh_host_" + algorithm + "_key"
+	}
+	return "id_" + algorithm
+}
+
+// GenerateCA creates a new Ed25519 SSH CA key pair
+func GenerateCA(config CAConfig) (*CABundle, error) {
+	var fields []*certificate.FieldError
+	for i, pattern := range config.HostPatterns {
+		if strings.TrimSpace(pattern) == "" || strings.ContainsAny(pattern, " \t\r\n,") {
+			fields = append(fields, &certificate.FieldError{Field: fmt.Sprintf("hostPatterns[%d]", i), Err: fmt.Errorf("invalid host pattern %q", pattern)})
+		}
+	}
+	if err := checkComment(config.Comment); err != nil {
+		fields = append(fields, &certificate.FieldError{Field: "comment", Err: err})
+	}
+	if len(fields) > 0 {
+		return nil, &certificate.ValidationError{Fields: fields}
+	}
+
+	privateKey, publicKey, err := generateKey(config.Comment)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, _, _, _, err := gossh.ParseAuthorizedKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse generated public key: %w", err)
+	}
+
+	return &CABundle{
+		PrivateKey:         privateKey,
+		PublicKey:          publicKey,
+		AuthorizedKeysLine: AuthorizedKeysLine(pub),
+		KnownHostsLine:     KnownHostsLine(pub, config.HostPatterns),
+	}, nil
+}
+
+// AuthorizedKeysLine returns the authorized_keys line that trusts the CA to certify users
+func AuthorizedKeysLine(caKey gossh.PublicKey) []byte {
+	return append([]byte("cert-authority "), gossh.MarshalAuthorizedKey(caKey)...)
+}
+
+// KnownHostsLine returns the known_hosts line that trusts the CA to certify the hosts
+// matching the patterns (all hosts when empty)
+func KnownHostsLine(caKey gossh.PublicKey, hostPatterns []string) []byte {
+	patterns := "*"
+	if len(hostPatterns) > 0 {
+		patterns = strings.Join(hostPatterns, ",")
+	}
+	return append([]byte("@cert-authority "+patterns+" "), gossh.MarshalAuthorizedKey(caKey)...)
+}
+
+// Validate checks the certificate configuration and returns a *certificate.ValidationError
+// naming every invalid field
+func (c CertConfig) Validate() error {
+	var fields []*certificate.FieldError
+	add := func(field string, err error) {
+		fields = append(fields, &certificate.FieldError{Field: field, Err: err})
+	}
+
+	certType, err := ParseCertType(string(c.Type))
+	if err != nil {
+		add("type", err)
+	}
+
+	if len(c.Principals) == 0 {
+		// OpenSSH treats a certificate without principals as valid for every user or host
+		add("principals", errors.New("at least one principal is required"))
+	}
+	for i, principal := range c.Principals {
+		if strings.TrimSpace(principal) == "" || strings.ContainsAny(principal, " \t\r\n,") {
+			add(fmt.Sprintf("principals[%d]", i), fmt.Errorf("invalid principal %q", principal))
+		}
+	}
+
+	if _, _, err := c.window(time.Now()); err != nil {
+		field := "validity"
+		if !c.ValidBefore.IsZero() {
+			field = "validBefore"
+		}
+		add(field, err)
+	}
+
+	if certType == CertTypeHost {
+		if len(c.CriticalOptions) > 0 {
+			add("criticalOptions", errors.New("host certificates do not support critical options"))
+		}
+		if len(c.Extensions) > 0 {
+			add("extensions", errors.New("host certificates do not support extensions"))
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.CriticalOptions)) {
+		value := c.CriticalOptions[name]
+		if !knownCriticalOptions[name] {
+			add("criticalOptions."+name, errors.New("unknown critical option"))
+			continue
+		}
+		if name == "source-address" {
+			for _, addr := range strings.Split(value, ",") {
+				addr = strings.TrimSpace(addr)
+				if _, _, err := net.ParseCIDR(addr); err != nil && net.ParseIP(addr) == nil {
+					add("criticalOptions.source-address", fmt.Errorf("invalid address or CIDR %q", addr))
+				}
+			}
+		}
+	}
+
+	if err := checkComment(c.Comment); err != nil {
+		add("comment", err)
+	}
+
+	if len(c.PublicKey) > 0 {
+		if _, _, _, _, err := gossh.ParseAuthorizedKey(c.PublicKey); err != nil {
+			add("publicKey", fmt.Errorf("invalid public key: %w", err))
+		}
+	}
+
+	if len(fields) > 0 {
+		return &certificate.ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// window computes the ValidAfter/ValidBefore pair relative to now
+func (c CertConfig) window(now time.Time) (time.Time, time.Time, error) {
+	validAfter := c.ValidAfter
+	start := c.ValidAfter
+	if validAfter.IsZero() {
+		start = now
+		validAfter = now.Add(-certificate.DefaultBackdate)
+	}
+
+	validBefore := c.ValidBefore
+	if validBefore.IsZero() {
+		if c.Validity == "" {
+			return time.Time{}, time.Time{}, errors.New("is required")
+		}
+		d, err := certificate.ParseValidity(c.Validity)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		validBefore = start.Add(d)
+	}
+
+	if !validBefore.After(validAfter) {
+		return time.Time{}, time.Time{}, errors.New("validBefore must be after validAfter")
+	}
+	return validAfter, validBefore, nil
+}
+
+// CAFingerprint returns the SHA256 fingerprint of the CA's public key in the format printed by
+// ssh-keygen -l, e.g. "SHA256:uN0D..."
+func CAFingerprint(caKeyPEM []byte) (string, error) {
+	caSigner, err := gossh.ParsePrivateKey(caKeyPEM)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to parse CA private key: %w", ErrInvalidCAKey, err)
+	}
+	return gossh.FingerprintSHA256(caSigner.PublicKey()), nil
+}
+
+// SignCert signs a user or host certificate with the OpenSSH encoded CA private key
+func SignCert(config CertConfig, caKeyPEM []byte) (*CertBundle, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	certType, _ := ParseCertType(string(config.Type))
+
+	caKey, err := gossh.ParseRawPrivateKey(caKeyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to parse CA private key: %w", ErrInvalidCAKey, err)
+	}
+	caSigner, err := gossh.NewSignerFromKey(caKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to use CA private key: %w", ErrInvalidCAKey, err)
+	}
+
+	bundle := &CertBundle{PublicKey: config.PublicKey}
+	if len(config.PublicKey) == 0 {
+		bundle.PrivateKey, bundle.PublicKey, err = generateKey(config.Comment)
+		if err != nil {
+			return nil, err
+		}
+	}
+	pub, _, _, _, err := gossh.ParseAuthorizedKey(bundle.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+
+	validAfter, validBefore, err := config.window(time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("invalid validity: %w", err)
+	}
+
+	serial := config.Serial
+	if serial == 0 {
+		var b [8]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return nil, fmt.Errorf("failed to generate serial number: %w", err)
+		}
+		serial = binary.BigEndian.Uint64(b[:])
+	}
+
+	keyID := config.KeyID
+	if keyID == "" {
+		keyID = config.Principals[0]
+	}
+
+	cert := &gossh.Certificate{
+		Key:             pub,
+		Serial:          serial,
+		CertType:        gossh.UserCert,
+		KeyId:           keyID,
+		ValidPrincipals: config.Principals,
+		ValidAfter:      uint64(validAfter.Unix()),
+		ValidBefore:     uint64(validBefore.Unix()),
+	}
+	if certType == CertTypeHost {
+		cert.CertType = gossh.HostCert
+	} else {
+		cert.CriticalOptions = config.CriticalOptions
+		cert.Extensions = config.Extensions
+		if cert.Extensions == nil {
+			cert.Extensions = DefaultUserExtensions
+		}
+	}
+
+	if err := cert.SignCert(rand.Reader, caSigner); err != nil {
+		return nil, fmt.Errorf("failed to sign certificate: %w", err)
+	}
+
+	bundle.Certificate = gossh.MarshalAuthorizedKey(cert)
+	return bundle, nil
+}
+
+// generateKey creates an Ed25519 key pair and returns the OpenSSH encoded private key
+// and the authorized_keys encoded public key
+func generateKey(comment string) ([]byte, []byte, error) {
+	if err := checkComment(comment); err != nil {
+		return nil, nil, err
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate private key: %w", err)
+	}
+
+	block, err := gossh.MarshalPrivateKey(priv, comment)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal private key: %w", err)
+	}
+
+	sshPub, err := gossh.NewPublicKey(pub)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+
+	publicKey := gossh.MarshalAuthorizedKey(sshPub)
+	if comment != "" {
+		publicKey = append(publicKey[:len(publicKey)-1], []byte(" "+comment+"\n")...)
+	}
+
+	return pem.EncodeToMemory(block), publicKey, nil
+}
+
+// checkComment rejects key comments spanning several lines, which would add lines of their
+// own to authorized_keys and known_hosts files
+func checkComment(comment string) error {
+	if strings.ContainsAny(comment, "\r\n") {
+		return errors.New("must not contain line breaks")
+	}
+	return nil
+}
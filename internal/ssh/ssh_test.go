@@ -0,0 +1,214 @@
+package ssh
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pvormste/certgen/certificate"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestGenerateCA(t *testing.T) {
+	ca, err := GenerateCA(CAConfig{Comment: "dev-ssh-ca", HostPatterns: []string{"*.dev.example"}})
+	if err != nil {
+		t.Fatalf("GenerateCA() error = %v", err)
+	}
+
+	if _, err := gossh.ParseRawPrivateKey(ca.PrivateKey); err != nil {
+		t.Errorf("Failed to parse CA private key: %v", err)
+	}
+
+	pub, comment, _, _, err := gossh.ParseAuthorizedKey(ca.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to parse CA public key: %v", err)
+	}
+	if comment != "dev-ssh-ca" {
+		t.Errorf("Expected comment dev-ssh-ca, got %q", comment)
+	}
+
+	_, _, options, _, err := gossh.ParseAuthorizedKey(ca.AuthorizedKeysLine)
+	if err != nil {
+		t.Fatalf("Failed to parse authorized_keys line: %v", err)
+	}
+	if len(options) != 1 || options[0] != "cert-authority" {
+		t.Errorf("Expected the cert-authority option, got %v", options)
+	}
+
+	marker, hosts, key, _, _, err := gossh.ParseKnownHosts(ca.KnownHostsLine)
+	if err != nil {
+		t.Fatalf("Failed to parse known_hosts line: %v", err)
+	}
+	if marker != "cert-authority" || len(hosts) != 1 || hosts[0] != "*.dev.example" {
+		t.Errorf("Unexpected known_hosts entry: marker %q, hosts %v", marker, hosts)
+	}
+	if !bytes.Equal(key.Marshal(), pub.Marshal()) {
+		t.Error("known_hosts line does not contain the CA public key")
+	}
+
+	fingerprint, err := CAFingerprint(ca.PrivateKey)
+	if err != nil || fingerprint != gossh.FingerprintSHA256(pub) {
+		t.Errorf("CAFingerprint() = %q, %v, want %q", fingerprint, err, gossh.FingerprintSHA256(pub))
+	}
+}
+
+func TestSignCert(t *testing.T) {
+	ca, err := GenerateCA(CAConfig{})
+	if err != nil {
+		t.Fatalf("GenerateCA() error = %v", err)
+	}
+	caPub, _, _, _, err := gossh.ParseAuthorizedKey(ca.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to parse CA public key: %v", err)
+	}
+
+	t.Run("user certificate with generated key", func(t *testing.T) {
+		config := CertConfig{
+			Type:            CertTypeUser,
+			Principals:      []string{"alice", "deploy"},
+			Validity:        "8h",
+			CriticalOptions: map[string]string{"source-address": "10.0.0.0/8,192.168.1.10"},
+			Serial:          42,
+		}
+		bundle, err := SignCert(config, ca.PrivateKey)
+		if err != nil {
+			t.Fatalf("SignCert() error = %v", err)
+		}
+		if len(bundle.PrivateKey) == 0 {
+			t.Error("Expected a generated private key")
+		}
+
+		cert := parseCert(t, bundle.Certificate)
+		if cert.CertType != gossh.UserCert || cert.KeyId != "alice" || cert.Serial != 42 {
+			t.Errorf("Unexpected certificate: type %d, key ID %q, serial %d", cert.CertType, cert.KeyId, cert.Serial)
+		}
+		if got := cert.CriticalOptions["source-address"]; got != "10.0.0.0/8,192.168.1.10" {
+			t.Errorf("Expected the source-address critical option, got %q", got)
+		}
+		if got := bundle.KeyFileName(config.Type); got != "id_ed25519" {
+			t.Errorf("KeyFileName() = %q, want id_ed25519", got)
+		}
+		if _, ok := cert.Extensions["permit-pty"]; !ok {
+			t.Error("Expected the default user extensions")
+		}
+		validity := time.Unix(int64(cert.ValidBefore), 0).Sub(time.Now())
+		if validity < 7*time.Hour || validity > 8*time.Hour {
+			t.Errorf("Expected the certificate to expire in 8 hours, got %v", validity)
+		}
+
+		checker := gossh.CertChecker{
+			IsUserAuthority: func(auth gossh.PublicKey) bool {
+				return bytes.Equal(auth.Marshal(), caPub.Marshal())
+			},
+		}
+		if _, err := checker.Authenticate(connMetadata{user: "deploy", addr: "10.1.2.3"}, cert); err != nil {
+			t.Errorf("Authenticate() error = %v", err)
+		}
+		if _, err := checker.Authenticate(connMetadata{user: "root", addr: "10.1.2.3"}, cert); err == nil {
+			t.Error("Expected authentication as an unlisted principal to fail")
+		}
+	})
+
+	t.Run("host certificate for existing key", func(t *testing.T) {
+		_, hostKey, err := generateKey("")
+		if err != nil {
+			t.Fatalf("generateKey() error = %v", err)
+		}
+
+		config := CertConfig{
+			Type:       CertTypeHost,
+			KeyID:      "web-1",
+			Principals: []string{"web-1.dev.example"},
+			Validity:   "30d",
+			PublicKey:  hostKey,
+		}
+		bundle, err := SignCert(config, ca.PrivateKey)
+		if err != nil {
+			t.Fatalf("SignCert() error = %v", err)
+		}
+		if len(bundle.PrivateKey) != 0 {
+			t.Error("Expected no private key when signing an existing public key")
+		}
+
+		if got := bundle.KeyFileName(config.Type); got != "ssh_host_ed25519_key" {
+			t.Errorf("KeyFileName() = %q, want ssh_host_ed25519_key", got)
+		}
+
+		cert := parseCert(t, bundle.Certificate)
+		if cert.CertType != gossh.HostCert || len(cert.Extensions) != 0 {
+			t.Errorf("Unexpected host certificate: type %d, extensions %v", cert.CertType, cert.Extensions)
+		}
+
+		checker := gossh.CertChecker{
+			IsHostAuthority: func(auth gossh.PublicKey, address string) bool {
+				return bytes.Equal(auth.Marshal(), caPub.Marshal())
+			},
+		}
+		if err := checker.CheckHostKey("web-1.dev.example:22", &net.TCPAddr{}, cert); err != nil {
+			t.Errorf("CheckHostKey() error = %v", err)
+		}
+	})
+}
+
+func TestCertConfigValidate(t *testing.T) {
+	config := CertConfig{
+		Type:            CertTypeHost,
+		Principals:      []string{"bad host"},
+		CriticalOptions: map[string]string{"force-command": "/bin/true", "permit-everything": ""},
+		PublicKey:       []byte("not a key"),
+		Comment:         "alice\nssh-ed25519 AAAA... mallory",
+	}
+
+	err := config.Validate()
+	var validationErr *certificate.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Validate() error = %v, want *certificate.ValidationError", err)
+	}
+
+	var fields []string
+	for _, f := range validationErr.Fields {
+		fields = append(fields, f.Field)
+	}
+	want := "principals[0],validity,criticalOptions,criticalOptions.permit-everything,comment,publicKey"
+	if got := strings.Join(fields, ","); got != want {
+		t.Errorf("Validate() fields = %s, want %s", got, want)
+	}
+}
+
+func TestGenerateCAInvalid(t *testing.T) {
+	_, err := GenerateCA(CAConfig{Comment: "ca\r\ncert-authority ssh-ed25519 AAAA...", HostPatterns: []string{"*.example\n@cert-authority *"}})
+	var validationErr *certificate.ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Fields) != 2 {
+		t.Fatalf("GenerateCA() error = %v, want errors for the host pattern and the comment", err)
+	}
+}
+
+func parseCert(t *testing.T, line []byte) *gossh.Certificate {
+	t.Helper()
+
+	pub, _, _, _, err := gossh.ParseAuthorizedKey(line)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	cert, ok := pub.(*gossh.Certificate)
+	if !ok {
+		t.Fatalf("Expected an SSH certificate, got %T", pub)
+	}
+	return cert
+}
+
+// connMetadata is a minimal gossh.ConnMetadata for CertChecker.Authenticate
+type connMetadata struct {
+	user string
+	addr string
+}
+
+func (m connMetadata) User() string          { return m.user }
+func (m connMetadata) SessionID() []byte     { return nil }
+func (m connMetadata) ClientVersion() []byte { return nil }
+func (m connMetadata) ServerVersion() []byte { return nil }
+func (m connMetadata) RemoteAddr() net.Addr  { return &net.TCPAddr{IP: net.ParseIP(m.addr), Port: 2222} }
+func (m connMetadata) LocalAddr() net.Addr   { return &net.TCPAddr{} }
@@ -1,32 +1,81 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/pvormste/certgen/certificate"
+	"github.com/pvormste/certgen/internal/audit"
+	"github.com/pvormste/certgen/internal/config"
 	"github.com/pvormste/certgen/internal/server"
 )
 
 func main() {
-	// Default to port 80, but allow override via flag or environment variable
-	defaultAddr := ":80"
-	if port := os.Getenv("PORT"); port != "" {
-		defaultAddr = ":" + port
+	// Settings come from an optional config file, overridden by environment variables and flags
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:], os.LookupEnv)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	// Parse command line flags
-	addr := flag.String("addr", defaultAddr, "HTTP service address")
-	flag.Parse()
-
 	// Create and start server
 	srv, err := server.NewServer()
 	if err != nil {
 		log.Fatalf("Failed to create server: %v", err)
 	}
+	srv.SetHTTPOptions(cfg.HTTPOptions())
+	srv.SetEndpoints(cfg.ServerEndpoints())
+	srv.SetDefaults(cfg.CertificateDefaults())
+	srv.SetLimits(cfg.RateLimits())
+
+	policies, err := cfg.PolicySet()
+	if err != nil {
+		log.Fatalf("Failed to load policies: %v", err)
+	}
+	if policies != nil {
+		srv.SetPolicies(policies)
+		log.Printf("Loaded %d issuance policies", len(policies))
+	}
+
+	authenticator, err := cfg.Authenticator()
+	if err != nil {
+		log.Fatalf("Failed to load authentication config: %v", err)
+	}
+	if authenticator != nil {
+		srv.SetAuthenticator(authenticator)
+		log.Printf("Authentication enabled from %s", cfg.AuthFile)
+	}
+
+	if cfg.Storage.AuditLog != "" {
+		l, err := audit.Open(cfg.Storage.AuditLog)
+		if err != nil {
+			log.Fatalf("Failed to open audit log: %v", err)
+		}
+		defer l.Close()
+		srv.SetAuditLog(l)
+		log.Printf("Recording audit events in %s", cfg.Storage.AuditLog)
+	}
+
+	if cfg.Storage.SerialFile != "" {
+		srv.SetSerialCounter(certificate.NewFileSerialCounter(cfg.Storage.SerialFile))
+	}
+
+	if cfg.TLSEnabled() {
+		if err := srv.SetTLS(cfg.TLSOptions()); err != nil {
+			log.Fatalf("Failed to configure TLS: %v", err)
+		}
+	}
+
+	// Shut down gracefully on Ctrl+C and when an orchestrator stops the container
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	log.Printf("Starting certificate generator service on %s", *addr)
-	if err := srv.Start(*addr); err != nil {
+	log.Printf("Starting certificate generator service on %s", cfg.Addr)
+	if err := srv.Run(ctx, cfg.Addr); err != nil {
 		log.Fatalf("Server error: %v", err)
 	}
+	log.Printf("Server stopped")
 }